@@ -9,89 +9,180 @@ import (
 	ssotypes "github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
 )
 
+// ErrMutuallyExclusiveFilters is returned by the deprecated FilterAccounts
+// and FilterPermissionSets when both an include and an exclude list are
+// given.
+//
+// Deprecated: include and exclude lists can now be combined; the pattern
+// based filters never return it.
 var ErrMutuallyExclusiveFilters = errors.New("include and exclude filters are mutually exclusive")
 
-// ParseAccountIdList parses a comma-delimited string of AWS account IDs
-// into a slice of AWSAccountId. Each ID is validated against the expected
-// 12-digit format.
-func ParseAccountIdList(s string) ([]AWSAccountId, error) {
+// ParseAccountPatterns parses a comma-delimited string of AWS account ID
+// patterns into a PatternList. Entries may be exact account IDs, globs
+// such as "1234*", regular expressions prefixed with "re:", or "@file"
+// references holding one entry per line. Exact IDs are validated against
+// the expected 12-digit format.
+func ParseAccountPatterns(s string) (PatternList, error) {
 	if len(s) == 0 {
 		return nil, nil
 	}
 
-	tokens := strings.Split(s, ",")
-	var result []AWSAccountId
+	entries, err := expandPatternEntries(s)
+	if err != nil {
+		return nil, err
+	}
 
-	for i, token := range tokens {
-		token = strings.TrimSpace(token)
-		if token == "" {
-			continue
+	var result PatternList
+	for _, e := range entries {
+		p, err := ParsePattern(e.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account ID pattern at %s: %w", e.location, err)
 		}
 
-		if !AccountIdPattern.MatchString(token) {
-			return nil, fmt.Errorf("invalid account ID at position %d: %q", i+1, token)
+		if p.IsLiteral() && !AccountIdPattern.MatchString(e.value) {
+			return nil, fmt.Errorf("invalid account ID at %s: %q", e.location, e.value)
 		}
 
-		result = append(result, AWSAccountId(token))
+		result = append(result, p)
 	}
 
 	return result, nil
 }
 
-// FilterAccounts filters a list of AWS accounts based on include and exclude
-// lists. If include is non-empty, only accounts in the include list are
-// returned. If exclude is non-empty, accounts in the exclude list are removed.
-// Setting both include and exclude is an error.
-func FilterAccounts(accounts []orgtypes.Account, include, exclude []AWSAccountId) ([]orgtypes.Account, error) {
-	if len(include) > 0 && len(exclude) > 0 {
-		return nil, ErrMutuallyExclusiveFilters
+// FilterAccountsByPatterns filters a list of AWS accounts based on include
+// and exclude pattern lists. If include is non-empty, only accounts
+// matching it are kept. Accounts matching exclude are then removed, so
+// exclude always wins over include.
+func FilterAccountsByPatterns(accounts []orgtypes.Account, include, exclude PatternList) []orgtypes.Account {
+	if len(include) == 0 && len(exclude) == 0 {
+		return accounts
 	}
 
-	if len(include) == 0 && len(exclude) == 0 {
-		return accounts, nil
+	var filtered []orgtypes.Account
+	for _, a := range accounts {
+		if a.Id == nil {
+			continue
+		}
+
+		if len(include) > 0 && !include.Match(*a.Id) {
+			continue
+		}
+
+		if exclude.Match(*a.Id) {
+			continue
+		}
+
+		filtered = append(filtered, a)
 	}
+	return filtered
+}
 
-	if len(include) > 0 {
-		includeSet := make(map[AWSAccountId]bool, len(include))
-		for _, id := range include {
-			includeSet[id] = true
+// ParsePermissionSetPatterns parses a comma-delimited string of permission
+// set name patterns into a PatternList. Entries may be exact names, globs
+// such as "ReadOnly*", regular expressions prefixed with "re:", or "@file"
+// references holding one entry per line. Empty tokens are skipped and
+// whitespace is trimmed.
+func ParsePermissionSetPatterns(s string) (PatternList, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	entries, err := expandPatternEntries(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var result PatternList
+	for _, e := range entries {
+		p, err := ParsePattern(e.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid permission set pattern at %s: %w", e.location, err)
 		}
 
-		var filtered []orgtypes.Account
-		for _, a := range accounts {
-			if a.Id != nil && includeSet[AWSAccountId(*a.Id)] {
-				filtered = append(filtered, a)
-			}
+		if !strings.HasPrefix(e.value, PatternRegexPrefix) && strings.ContainsAny(e.value, " \t") {
+			return nil, fmt.Errorf("invalid permission set name at %s: %q (must not contain whitespace)", e.location, e.value)
 		}
-		return filtered, nil
+
+		result = append(result, p)
 	}
 
-	excludeSet := make(map[AWSAccountId]bool, len(exclude))
-	for _, id := range exclude {
-		excludeSet[id] = true
+	return result, nil
+}
+
+// FilterPermissionSetsByPatterns filters a list of permission sets based on
+// include and exclude pattern lists. If include is non-empty, only
+// permission sets whose name matches it are kept. Permission sets whose
+// name matches exclude are then removed, so exclude always wins over
+// include.
+func FilterPermissionSetsByPatterns(permissionSets []ssotypes.PermissionSet, include, exclude PatternList) []ssotypes.PermissionSet {
+	if len(include) == 0 && len(exclude) == 0 {
+		return permissionSets
 	}
 
-	var filtered []orgtypes.Account
-	for _, a := range accounts {
-		if a.Id != nil && !excludeSet[AWSAccountId(*a.Id)] {
-			filtered = append(filtered, a)
+	var filtered []ssotypes.PermissionSet
+	for _, p := range permissionSets {
+		if p.Name == nil {
+			continue
+		}
+
+		if len(include) > 0 && !include.Match(*p.Name) {
+			continue
 		}
+
+		if exclude.Match(*p.Name) {
+			continue
+		}
+
+		filtered = append(filtered, p)
 	}
-	return filtered, nil
+	return filtered
+}
+
+// ParseAccountIdList parses a comma-delimited string of AWS account IDs
+// into a slice of AWSAccountId. Each ID is validated against the expected
+// 12-digit format.
+//
+// Deprecated: use ParseAccountPatterns, which also accepts globs, regular
+// expressions and @file references.
+func ParseAccountIdList(s string) ([]AWSAccountId, error) {
+	var result []AWSAccountId
+	for i, token := range strings.Split(s, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+
+		if !AccountIdPattern.MatchString(token) {
+			return nil, fmt.Errorf("invalid account ID at position %d: %q", i+1, token)
+		}
+
+		result = append(result, AWSAccountId(token))
+	}
+
+	return result, nil
+}
+
+// FilterAccounts filters a list of AWS accounts based on include and exclude
+// lists of exact account IDs. Setting both include and exclude is an error.
+//
+// Deprecated: use FilterAccountsByPatterns, which allows include and
+// exclude to be combined.
+func FilterAccounts(accounts []orgtypes.Account, include, exclude []AWSAccountId) ([]orgtypes.Account, error) {
+	if len(include) > 0 && len(exclude) > 0 {
+		return nil, ErrMutuallyExclusiveFilters
+	}
+	return FilterAccountsByPatterns(accounts, literalPatterns(include), literalPatterns(exclude)), nil
 }
 
 // ParsePermissionSetList parses a comma-delimited string of permission set
 // names into a slice of strings. Empty tokens are skipped and whitespace
 // is trimmed.
+//
+// Deprecated: use ParsePermissionSetPatterns, which also accepts globs,
+// regular expressions and @file references.
 func ParsePermissionSetList(s string) ([]string, error) {
-	if len(s) == 0 {
-		return nil, nil
-	}
-
-	tokens := strings.Split(s, ",")
 	var result []string
-
-	for i, token := range tokens {
+	for i, token := range strings.Split(s, ",") {
 		token = strings.TrimSpace(token)
 		if token == "" {
 			continue
@@ -108,44 +199,24 @@ func ParsePermissionSetList(s string) ([]string, error) {
 }
 
 // FilterPermissionSets filters a list of permission sets based on include
-// and exclude lists. If include is non-empty, only permission sets whose
-// name appears in the include list are returned. If exclude is non-empty,
-// permission sets whose name appears in the exclude list are removed.
-// Setting both include and exclude is an error.
+// and exclude lists of exact names. Setting both include and exclude is an
+// error.
+//
+// Deprecated: use FilterPermissionSetsByPatterns, which allows include and
+// exclude to be combined.
 func FilterPermissionSets(permissionSets []ssotypes.PermissionSet, include, exclude []string) ([]ssotypes.PermissionSet, error) {
 	if len(include) > 0 && len(exclude) > 0 {
 		return nil, ErrMutuallyExclusiveFilters
 	}
+	return FilterPermissionSetsByPatterns(permissionSets, literalPatterns(include), literalPatterns(exclude)), nil
+}
 
-	if len(include) == 0 && len(exclude) == 0 {
-		return permissionSets, nil
-	}
-
-	if len(include) > 0 {
-		includeSet := make(map[string]bool, len(include))
-		for _, name := range include {
-			includeSet[name] = true
-		}
-
-		var filtered []ssotypes.PermissionSet
-		for _, p := range permissionSets {
-			if p.Name != nil && includeSet[*p.Name] {
-				filtered = append(filtered, p)
-			}
-		}
-		return filtered, nil
-	}
-
-	excludeSet := make(map[string]bool, len(exclude))
-	for _, name := range exclude {
-		excludeSet[name] = true
-	}
-
-	var filtered []ssotypes.PermissionSet
-	for _, p := range permissionSets {
-		if p.Name != nil && !excludeSet[*p.Name] {
-			filtered = append(filtered, p)
-		}
+// literalPatterns returns a pattern matching each value exactly, even one
+// that looks like a glob.
+func literalPatterns[T ~string](values []T) PatternList {
+	var patterns PatternList
+	for _, v := range values {
+		patterns = append(patterns, Pattern{raw: string(v)})
 	}
-	return filtered, nil
+	return patterns
 }
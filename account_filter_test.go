@@ -9,6 +9,432 @@ import (
 	ssotypes "github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
 )
 
+func TestParseAccountPatterns(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:     "single valid ID",
+			input:    "123456789012",
+			expected: []string{"123456789012"},
+		},
+		{
+			name:     "multiple valid IDs",
+			input:    "123456789012,234567890123,345678901234",
+			expected: []string{"123456789012", "234567890123", "345678901234"},
+		},
+		{
+			name:     "empty string",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:     "trailing comma",
+			input:    "123456789012,",
+			expected: []string{"123456789012"},
+		},
+		{
+			name:        "too short ID",
+			input:       "12345",
+			wantErr:     true,
+			errContains: "invalid account ID",
+		},
+		{
+			name:        "non-numeric ID",
+			input:       "12345678901a",
+			wantErr:     true,
+			errContains: "invalid account ID",
+		},
+		{
+			name:        "too long ID",
+			input:       "1234567890123",
+			wantErr:     true,
+			errContains: "invalid account ID",
+		},
+		{
+			name:     "whitespace around IDs",
+			input:    " 123456789012 , 234567890123 ",
+			expected: []string{"123456789012", "234567890123"},
+		},
+		{
+			name:     "glob and regex patterns",
+			input:    "1234*,re:^9{12}$",
+			expected: []string{"1234*", "re:^9{12}$"},
+		},
+		{
+			name:        "invalid regex",
+			input:       "re:([",
+			wantErr:     true,
+			errContains: "invalid account ID pattern",
+		},
+		{
+			name:        "one valid one invalid",
+			input:       "123456789012,bad",
+			wantErr:     true,
+			errContains: "invalid account ID at position 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseAccountPatterns(tt.input)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseAccountPatterns() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && err != nil && tt.errContains != "" {
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("Error message %q should contain %q", err.Error(), tt.errContains)
+				}
+				return
+			}
+
+			if len(result) != len(tt.expected) {
+				t.Errorf("Expected %d IDs, got %d", len(tt.expected), len(result))
+				return
+			}
+
+			for i, id := range result {
+				if id.String() != tt.expected[i] {
+					t.Errorf("ID at position %d: expected %q, got %q", i, tt.expected[i], id)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterAccountsByPatterns(t *testing.T) {
+	accounts := []orgtypes.Account{
+		{Id: aws.String("111111111111"), Name: aws.String("Account One")},
+		{Id: aws.String("222222222222"), Name: aws.String("Account Two")},
+		{Id: aws.String("333333333333"), Name: aws.String("Account Three")},
+	}
+
+	tests := []struct {
+		name        string
+		accounts    []orgtypes.Account
+		include     string
+		exclude     string
+		expectedIDs []string
+	}{
+		{
+			name:        "no filters returns all accounts",
+			accounts:    accounts,
+			include:     "",
+			exclude:     "",
+			expectedIDs: []string{"111111111111", "222222222222", "333333333333"},
+		},
+		{
+			name:        "include filter",
+			accounts:    accounts,
+			include:     "111111111111,333333333333",
+			exclude:     "",
+			expectedIDs: []string{"111111111111", "333333333333"},
+		},
+		{
+			name:        "exclude filter",
+			accounts:    accounts,
+			include:     "",
+			exclude:     "222222222222",
+			expectedIDs: []string{"111111111111", "333333333333"},
+		},
+		{
+			name:        "exclude applied after include",
+			accounts:    accounts,
+			include:     "111111111111,222222222222",
+			exclude:     "222222222222",
+			expectedIDs: []string{"111111111111"},
+		},
+		{
+			name:        "glob include",
+			accounts:    accounts,
+			include:     "[12]*",
+			expectedIDs: []string{"111111111111", "222222222222"},
+		},
+		{
+			name:        "regex exclude",
+			accounts:    accounts,
+			exclude:     "re:^3+$",
+			expectedIDs: []string{"111111111111", "222222222222"},
+		},
+		{
+			name:        "include with no matching accounts",
+			accounts:    accounts,
+			include:     "999999999999",
+			exclude:     "",
+			expectedIDs: nil,
+		},
+		{
+			name:        "exclude with no matching accounts",
+			accounts:    accounts,
+			include:     "",
+			exclude:     "999999999999",
+			expectedIDs: []string{"111111111111", "222222222222", "333333333333"},
+		},
+		{
+			name:        "empty account list",
+			accounts:    []orgtypes.Account{},
+			include:     "111111111111",
+			exclude:     "",
+			expectedIDs: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FilterAccountsByPatterns(tt.accounts, mustParseAccountPatterns(t, tt.include), mustParseAccountPatterns(t, tt.exclude))
+
+			if len(result) != len(tt.expectedIDs) {
+				t.Errorf("Expected %d accounts, got %d", len(tt.expectedIDs), len(result))
+				return
+			}
+
+			for i, a := range result {
+				if *a.Id != tt.expectedIDs[i] {
+					t.Errorf("Account at position %d: expected ID %q, got %q", i, tt.expectedIDs[i], *a.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePermissionSetPatterns(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:     "single name",
+			input:    "AdminAccess",
+			expected: []string{"AdminAccess"},
+		},
+		{
+			name:     "multiple names",
+			input:    "AdminAccess,ReadOnly,PowerUser",
+			expected: []string{"AdminAccess", "ReadOnly", "PowerUser"},
+		},
+		{
+			name:     "empty string",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:     "trailing comma",
+			input:    "AdminAccess,",
+			expected: []string{"AdminAccess"},
+		},
+		{
+			name:     "whitespace around names",
+			input:    " AdminAccess , ReadOnly ",
+			expected: []string{"AdminAccess", "ReadOnly"},
+		},
+		{
+			name:     "glob and regex patterns",
+			input:    "ReadOnly*,re:^Platform-.*",
+			expected: []string{"ReadOnly*", "re:^Platform-.*"},
+		},
+		{
+			name:        "invalid glob",
+			input:       "Read[",
+			wantErr:     true,
+			errContains: "invalid permission set pattern",
+		},
+		{
+			name:        "name with internal whitespace",
+			input:       "Admin Access",
+			wantErr:     true,
+			errContains: "invalid permission set name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParsePermissionSetPatterns(tt.input)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePermissionSetPatterns() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && err != nil && tt.errContains != "" {
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("Error message %q should contain %q", err.Error(), tt.errContains)
+				}
+				return
+			}
+
+			if len(result) != len(tt.expected) {
+				t.Errorf("Expected %d names, got %d", len(tt.expected), len(result))
+				return
+			}
+
+			for i, name := range result {
+				if name.String() != tt.expected[i] {
+					t.Errorf("Name at position %d: expected %q, got %q", i, tt.expected[i], name)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterPermissionSetsByPatterns(t *testing.T) {
+	permissionSets := []ssotypes.PermissionSet{
+		{Name: aws.String("AdminAccess"), Description: aws.String("Admin"), SessionDuration: aws.String("PT1H")},
+		{Name: aws.String("ReadOnly"), Description: aws.String("Read"), SessionDuration: aws.String("PT2H")},
+		{Name: aws.String("PowerUser"), Description: aws.String("Power"), SessionDuration: aws.String("PT1H")},
+	}
+
+	tests := []struct {
+		name          string
+		permSets      []ssotypes.PermissionSet
+		include       string
+		exclude       string
+		expectedNames []string
+	}{
+		{
+			name:          "no filters returns all",
+			permSets:      permissionSets,
+			include:       "",
+			exclude:       "",
+			expectedNames: []string{"AdminAccess", "ReadOnly", "PowerUser"},
+		},
+		{
+			name:          "include filter",
+			permSets:      permissionSets,
+			include:       "AdminAccess,PowerUser",
+			exclude:       "",
+			expectedNames: []string{"AdminAccess", "PowerUser"},
+		},
+		{
+			name:          "exclude filter",
+			permSets:      permissionSets,
+			include:       "",
+			exclude:       "ReadOnly",
+			expectedNames: []string{"AdminAccess", "PowerUser"},
+		},
+		{
+			name:          "exclude applied after include",
+			permSets:      permissionSets,
+			include:       "re:^(Admin|Read)",
+			exclude:       "ReadOnly",
+			expectedNames: []string{"AdminAccess"},
+		},
+		{
+			name:          "glob include",
+			permSets:      permissionSets,
+			include:       "*Access,Power*",
+			expectedNames: []string{"AdminAccess", "PowerUser"},
+		},
+		{
+			name:          "include with no matches",
+			permSets:      permissionSets,
+			include:       "NonExistent",
+			exclude:       "",
+			expectedNames: nil,
+		},
+		{
+			name:          "exclude with no matches",
+			permSets:      permissionSets,
+			include:       "",
+			exclude:       "NonExistent",
+			expectedNames: []string{"AdminAccess", "ReadOnly", "PowerUser"},
+		},
+		{
+			name:          "empty permission sets list",
+			permSets:      []ssotypes.PermissionSet{},
+			include:       "AdminAccess",
+			exclude:       "",
+			expectedNames: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FilterPermissionSetsByPatterns(tt.permSets, mustParsePermissionSetPatterns(t, tt.include), mustParsePermissionSetPatterns(t, tt.exclude))
+
+			if len(result) != len(tt.expectedNames) {
+				t.Errorf("Expected %d permission sets, got %d", len(tt.expectedNames), len(result))
+				return
+			}
+
+			for i, p := range result {
+				if *p.Name != tt.expectedNames[i] {
+					t.Errorf("Permission set at position %d: expected name %q, got %q", i, tt.expectedNames[i], *p.Name)
+				}
+			}
+		})
+	}
+}
+
+func mustParseAccountPatterns(t *testing.T, s string) PatternList {
+	t.Helper()
+	l, err := ParseAccountPatterns(s)
+	if err != nil {
+		t.Fatalf("ParseAccountPatterns(%q) error: %v", s, err)
+	}
+	return l
+}
+
+func mustParsePermissionSetPatterns(t *testing.T, s string) PatternList {
+	t.Helper()
+	l, err := ParsePermissionSetPatterns(s)
+	if err != nil {
+		t.Fatalf("ParsePermissionSetPatterns(%q) error: %v", s, err)
+	}
+	return l
+}
+
+func TestDeprecatedFilters(t *testing.T) {
+	accounts := []orgtypes.Account{
+		{Id: aws.String("123456789012")},
+		{Id: aws.String("234567890123")},
+	}
+	permSets := []ssotypes.PermissionSet{
+		{Name: aws.String("AdminAccess")},
+		{Name: aws.String("ReadOnly*")},
+	}
+
+	ids, err := ParseAccountIdList("123456789012, ")
+	if err != nil || len(ids) != 1 || ids[0] != "123456789012" {
+		t.Fatalf("ParseAccountIdList() = %v, %v", ids, err)
+	}
+	if _, err := ParseAccountIdList("1234*"); err == nil {
+		t.Error("ParseAccountIdList() accepted a glob")
+	}
+
+	gotAccounts, err := FilterAccounts(accounts, nil, ids)
+	if err != nil || len(gotAccounts) != 1 || *gotAccounts[0].Id != "234567890123" {
+		t.Errorf("FilterAccounts() = %v, %v", gotAccounts, err)
+	}
+	if _, err := FilterAccounts(accounts, ids, ids); err != ErrMutuallyExclusiveFilters {
+		t.Errorf("FilterAccounts() error = %v, want ErrMutuallyExclusiveFilters", err)
+	}
+
+	names, err := ParsePermissionSetList("ReadOnly*")
+	if err != nil || len(names) != 1 {
+		t.Fatalf("ParsePermissionSetList() = %v, %v", names, err)
+	}
+
+	// Names are matched exactly, as they always were, even when they look
+	// like a glob.
+	gotPermSets, err := FilterPermissionSets(permSets, names, nil)
+	if err != nil || len(gotPermSets) != 1 || *gotPermSets[0].Name != "ReadOnly*" {
+		t.Errorf("FilterPermissionSets() = %v, %v", gotPermSets, err)
+	}
+	if _, err := FilterPermissionSets(permSets, names, names); err != ErrMutuallyExclusiveFilters {
+		t.Errorf("FilterPermissionSets() error = %v, want ErrMutuallyExclusiveFilters", err)
+	}
+}
+
 func TestParseAccountIdList(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -0,0 +1,76 @@
+package setlist
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+// DefaultRoleSessionName is the role session name used when AssumeRoleInput
+// does not set one.
+const DefaultRoleSessionName = "setlist"
+
+// AssumeRoleInput describes a role to assume before calling AWS, e.g. in
+// the management or delegated-admin account.
+type AssumeRoleInput struct {
+	RoleArn         string
+	ExternalId      string
+	RoleSessionName string
+	MFASerial       string                 // ARN or serial number of the MFA device, if the role requires MFA
+	TokenProvider   func() (string, error) // Supplies the MFA code; required with MFASerial
+}
+
+// ValidateRoleArn checks that roleArn is an IAM role ARN.
+func ValidateRoleArn(roleArn string) error {
+	if roleArn == "" {
+		return ErrEmptyString
+	}
+
+	parsed, err := arn.Parse(roleArn)
+	if err != nil {
+		return fmt.Errorf("invalid role ARN %q: %w", roleArn, err)
+	}
+	if parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "role/") {
+		return fmt.Errorf("invalid role ARN %q: not an IAM role", roleArn)
+	}
+
+	return nil
+}
+
+// AssumeRole returns a copy of cfg whose credentials come from assuming
+// input.RoleArn with client, which should be an STS client built from the
+// original credentials. The assumed credentials are cached and refreshed
+// before they expire.
+func AssumeRole(cfg aws.Config, client stscreds.AssumeRoleAPIClient, input AssumeRoleInput) (aws.Config, error) {
+	if err := ValidateRoleArn(input.RoleArn); err != nil {
+		return aws.Config{}, err
+	}
+
+	if input.MFASerial != "" && input.TokenProvider == nil {
+		return aws.Config{}, errors.New("an MFA token provider is required when an MFA serial is set")
+	}
+
+	sessionName := input.RoleSessionName
+	if sessionName == "" {
+		sessionName = DefaultRoleSessionName
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(client, input.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = sessionName
+		if input.ExternalId != "" {
+			o.ExternalID = aws.String(input.ExternalId)
+		}
+		if input.MFASerial != "" {
+			o.SerialNumber = aws.String(input.MFASerial)
+			o.TokenProvider = input.TokenProvider
+		}
+	})
+
+	assumed := cfg.Copy()
+	assumed.Credentials = aws.NewCredentialsCache(provider)
+	return assumed, nil
+}
@@ -0,0 +1,138 @@
+package setlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// stubSTSClient records the AssumeRole request and returns fixed
+// credentials.
+type stubSTSClient struct {
+	input *sts.AssumeRoleInput
+}
+
+func (s *stubSTSClient) AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	s.input = params
+	return &sts.AssumeRoleOutput{
+		Credentials: &ststypes.Credentials{
+			AccessKeyId:     aws.String("AKIAASSUMED"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+func TestValidateRoleArn(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"role", "arn:aws:iam::123456789012:role/SetlistReader", false},
+		{"role with path", "arn:aws-us-gov:iam::123456789012:role/tools/SetlistReader", false},
+		{"empty", "", true},
+		{"not an arn", "SetlistReader", true},
+		{"user", "arn:aws:iam::123456789012:user/alice", true},
+		{"other service", "arn:aws:s3:::bucket", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateRoleArn(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidateRoleArn(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssumeRole(t *testing.T) {
+	const roleArn = "arn:aws:iam::123456789012:role/SetlistReader"
+
+	tests := []struct {
+		name            string
+		input           AssumeRoleInput
+		wantSessionName string
+		wantExternalId  string
+		wantMFA         bool
+		errContains     string
+	}{
+		{
+			name:            "defaults",
+			input:           AssumeRoleInput{RoleArn: roleArn},
+			wantSessionName: DefaultRoleSessionName,
+		},
+		{
+			name:            "external id and session name",
+			input:           AssumeRoleInput{RoleArn: roleArn, ExternalId: "ext-1", RoleSessionName: "ci"},
+			wantSessionName: "ci",
+			wantExternalId:  "ext-1",
+		},
+		{
+			name: "mfa",
+			input: AssumeRoleInput{
+				RoleArn:       roleArn,
+				MFASerial:     "arn:aws:iam::123456789012:mfa/alice",
+				TokenProvider: func() (string, error) { return "123456", nil },
+			},
+			wantSessionName: DefaultRoleSessionName,
+			wantMFA:         true,
+		},
+		{
+			name:        "mfa without token provider",
+			input:       AssumeRoleInput{RoleArn: roleArn, MFASerial: "arn:aws:iam::123456789012:mfa/alice"},
+			errContains: "MFA token provider is required",
+		},
+		{
+			name:        "invalid role",
+			input:       AssumeRoleInput{RoleArn: "SetlistReader"},
+			errContains: "invalid role ARN",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &stubSTSClient{}
+
+			cfg, err := AssumeRole(aws.Config{Region: "us-east-1"}, client, tc.input)
+			if tc.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errContains) {
+					t.Errorf("error = %v, should contain %q", err, tc.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			creds, err := cfg.Credentials.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("unable to retrieve credentials: %v", err)
+			}
+			if creds.AccessKeyID != "AKIAASSUMED" {
+				t.Errorf("AccessKeyID = %q, want the assumed credentials", creds.AccessKeyID)
+			}
+
+			in := client.input
+			if aws.ToString(in.RoleArn) != roleArn {
+				t.Errorf("RoleArn = %q", aws.ToString(in.RoleArn))
+			}
+			if aws.ToString(in.RoleSessionName) != tc.wantSessionName {
+				t.Errorf("RoleSessionName = %q, want %q", aws.ToString(in.RoleSessionName), tc.wantSessionName)
+			}
+			if aws.ToString(in.ExternalId) != tc.wantExternalId {
+				t.Errorf("ExternalId = %q, want %q", aws.ToString(in.ExternalId), tc.wantExternalId)
+			}
+			if tc.wantMFA && (aws.ToString(in.SerialNumber) == "" || aws.ToString(in.TokenCode) != "123456") {
+				t.Errorf("MFA not passed: serial %q, token %q", aws.ToString(in.SerialNumber), aws.ToString(in.TokenCode))
+			}
+		})
+	}
+}
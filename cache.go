@@ -0,0 +1,393 @@
+package setlist
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+)
+
+// DefaultCacheTTL is how long cached API responses remain valid when no
+// explicit TTL is configured.
+const DefaultCacheTTL = 15 * time.Minute
+
+// cacheDirName is the directory created under the user cache directory.
+const cacheDirName = "setlist"
+
+// Cache persists AWS API responses on disk so that repeated runs can skip
+// calls whose results are unlikely to have changed. Entries are grouped by
+// scope, which should identify the credentials account and region so that
+// responses from different organizations never mix.
+type Cache struct {
+	Dir     string        // Root directory holding cache entries
+	Scope   string        // Namespace for entries, see CacheScope
+	TTL     time.Duration // How long an entry remains valid
+	Refresh bool          // Ignore existing entries but still store new ones
+
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	StoredAt time.Time       `json:"stored_at"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// NewCache creates a Cache rooted at dir for the given scope. A zero ttl
+// falls back to DefaultCacheTTL.
+func NewCache(dir, scope string, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	return &Cache{
+		Dir:   dir,
+		Scope: scope,
+		TTL:   ttl,
+		now:   time.Now,
+	}
+}
+
+// DefaultCacheDir returns the directory used for cache entries when none
+// is specified, located under the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine user cache directory: %w", err)
+	}
+
+	return filepath.Join(dir, cacheDirName), nil
+}
+
+// CacheScope builds the scope for cache entries from the account that owns
+// the credentials and the region the clients talk to.
+func CacheScope(accountId, region string) string {
+	return fmt.Sprintf("%s-%s", accountId, region)
+}
+
+// get loads the entry for key into v. It reports whether a valid, unexpired
+// entry was found. Unreadable entries are treated as misses.
+func (c *Cache) get(key string, v any) bool {
+	if c.Refresh {
+		return false
+	}
+
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		slog.Warn("Ignoring unreadable cache entry", "key", key, "error", err.Error())
+		return false
+	}
+
+	if c.clock().Sub(entry.StoredAt) > c.TTL {
+		return false
+	}
+
+	if err := json.Unmarshal(entry.Data, v); err != nil {
+		slog.Warn("Ignoring unreadable cache entry", "key", key, "error", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// put stores v under key. The entry is written to a temporary file and
+// renamed into place so concurrent readers never see a partial entry.
+func (c *Cache) put(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(cacheEntry{StoredAt: c.clock(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //#nosec: G104
+
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close() //#nosec: G104
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// store writes an entry and logs, rather than returns, any failure since a
+// cache that cannot be written should never fail the run.
+func (c *Cache) store(key string, v any) {
+	if err := c.put(key, v); err != nil {
+		slog.Warn("Unable to write cache entry", "key", key, "error", err.Error())
+	}
+}
+
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	operation, _, _ := strings.Cut(key, "|")
+
+	return filepath.Join(c.Dir, c.Scope, operation, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cache) clock() time.Time {
+	if c.now == nil {
+		return time.Now()
+	}
+	return c.now()
+}
+
+// cacheKey joins an operation name and its distinguishing parameters.
+func cacheKey(operation string, parts ...string) string {
+	return strings.Join(append([]string{operation}, parts...), "|")
+}
+
+// CachedOrganizationsClient decorates an OrganizationsClient so that
+// ListAccounts responses, and the tag and OU lookups used by filters, are
+// served from a Cache when possible.
+type CachedOrganizationsClient struct {
+	client OrganizationsClient
+	cache  *Cache
+}
+
+// NewCachedOrganizationsClient wraps client with cache.
+func NewCachedOrganizationsClient(client OrganizationsClient, cache *Cache) *CachedOrganizationsClient {
+	return &CachedOrganizationsClient{client: client, cache: cache}
+}
+
+// ListAccounts returns a cached page of accounts, calling the wrapped client
+// on a miss.
+func (c *CachedOrganizationsClient) ListAccounts(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	var token string
+	var maxResults int32
+	if params != nil {
+		token = aws.ToString(params.NextToken)
+		maxResults = aws.ToInt32(params.MaxResults)
+	}
+	key := cacheKey("ListAccounts", token, fmt.Sprint(maxResults))
+
+	var out organizations.ListAccountsOutput
+	if c.cache.get(key, &out) {
+		return &out, nil
+	}
+
+	resp, err := c.client.ListAccounts(ctx, params, optFns...)
+	if err != nil {
+		return resp, err
+	}
+
+	c.cache.store(key, resp)
+	return resp, nil
+}
+
+// ListTagsForResource returns cached account tags. It fails with
+// ErrMetadataUnsupported when the wrapped client cannot look up tags.
+func (c *CachedOrganizationsClient) ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	meta, ok := c.client.(OrganizationsMetadataClient)
+	if !ok {
+		return nil, ErrMetadataUnsupported
+	}
+
+	key := cacheKey("OrgListTagsForResource", aws.ToString(params.ResourceId), aws.ToString(params.NextToken))
+
+	var out organizations.ListTagsForResourceOutput
+	if c.cache.get(key, &out) {
+		return &out, nil
+	}
+
+	resp, err := meta.ListTagsForResource(ctx, params, optFns...)
+	if err != nil {
+		return resp, err
+	}
+
+	c.cache.store(key, resp)
+	return resp, nil
+}
+
+// ListParents returns the cached parents of an account or OU. It fails with
+// ErrMetadataUnsupported when the wrapped client cannot look up parents.
+func (c *CachedOrganizationsClient) ListParents(ctx context.Context, params *organizations.ListParentsInput, optFns ...func(*organizations.Options)) (*organizations.ListParentsOutput, error) {
+	meta, ok := c.client.(OrganizationsMetadataClient)
+	if !ok {
+		return nil, ErrMetadataUnsupported
+	}
+
+	key := cacheKey("ListParents", aws.ToString(params.ChildId), aws.ToString(params.NextToken))
+
+	var out organizations.ListParentsOutput
+	if c.cache.get(key, &out) {
+		return &out, nil
+	}
+
+	resp, err := meta.ListParents(ctx, params, optFns...)
+	if err != nil {
+		return resp, err
+	}
+
+	c.cache.store(key, resp)
+	return resp, nil
+}
+
+// DescribeOrganizationalUnit returns a cached OU description. It fails with
+// ErrMetadataUnsupported when the wrapped client cannot describe OUs.
+func (c *CachedOrganizationsClient) DescribeOrganizationalUnit(ctx context.Context, params *organizations.DescribeOrganizationalUnitInput, optFns ...func(*organizations.Options)) (*organizations.DescribeOrganizationalUnitOutput, error) {
+	meta, ok := c.client.(OrganizationsMetadataClient)
+	if !ok {
+		return nil, ErrMetadataUnsupported
+	}
+
+	key := cacheKey("DescribeOrganizationalUnit", aws.ToString(params.OrganizationalUnitId))
+
+	var out organizations.DescribeOrganizationalUnitOutput
+	if c.cache.get(key, &out) {
+		return &out, nil
+	}
+
+	resp, err := meta.DescribeOrganizationalUnit(ctx, params, optFns...)
+	if err != nil {
+		return resp, err
+	}
+
+	c.cache.store(key, resp)
+	return resp, nil
+}
+
+// CachedSSOAdminClient decorates an SSOAdminClient so that ListInstances,
+// ListPermissionSetsProvisionedToAccount, DescribePermissionSet and tag
+// lookups are served from a Cache when possible. ListPermissionSets is passed
+// through unchanged.
+type CachedSSOAdminClient struct {
+	client SSOAdminClient
+	cache  *Cache
+}
+
+// NewCachedSSOAdminClient wraps client with cache.
+func NewCachedSSOAdminClient(client SSOAdminClient, cache *Cache) *CachedSSOAdminClient {
+	return &CachedSSOAdminClient{client: client, cache: cache}
+}
+
+// ListInstances returns a cached page of SSO instances, calling the wrapped
+// client on a miss.
+func (c *CachedSSOAdminClient) ListInstances(ctx context.Context, params *ssoadmin.ListInstancesInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListInstancesOutput, error) {
+	var token string
+	if params != nil {
+		token = aws.ToString(params.NextToken)
+	}
+	key := cacheKey("ListInstances", token)
+
+	var out ssoadmin.ListInstancesOutput
+	if c.cache.get(key, &out) {
+		return &out, nil
+	}
+
+	resp, err := c.client.ListInstances(ctx, params, optFns...)
+	if err != nil {
+		return resp, err
+	}
+
+	c.cache.store(key, resp)
+	return resp, nil
+}
+
+// ListPermissionSets calls the wrapped client directly.
+func (c *CachedSSOAdminClient) ListPermissionSets(ctx context.Context, params *ssoadmin.ListPermissionSetsInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsOutput, error) {
+	return c.client.ListPermissionSets(ctx, params, optFns...)
+}
+
+// ListPermissionSetsProvisionedToAccount returns a cached page of permission
+// set ARNs for an account, calling the wrapped client on a miss.
+func (c *CachedSSOAdminClient) ListPermissionSetsProvisionedToAccount(ctx context.Context, params *ssoadmin.ListPermissionSetsProvisionedToAccountInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsProvisionedToAccountOutput, error) {
+	key := cacheKey("ListPermissionSetsProvisionedToAccount",
+		aws.ToString(params.InstanceArn),
+		aws.ToString(params.AccountId),
+		string(params.ProvisioningStatus),
+		aws.ToString(params.NextToken),
+	)
+
+	var out ssoadmin.ListPermissionSetsProvisionedToAccountOutput
+	if c.cache.get(key, &out) {
+		return &out, nil
+	}
+
+	resp, err := c.client.ListPermissionSetsProvisionedToAccount(ctx, params, optFns...)
+	if err != nil {
+		return resp, err
+	}
+
+	c.cache.store(key, resp)
+	return resp, nil
+}
+
+// DescribePermissionSet returns a cached permission set description,
+// calling the wrapped client on a miss.
+func (c *CachedSSOAdminClient) DescribePermissionSet(ctx context.Context, params *ssoadmin.DescribePermissionSetInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error) {
+	key := cacheKey("DescribePermissionSet",
+		aws.ToString(params.InstanceArn),
+		aws.ToString(params.PermissionSetArn),
+	)
+
+	var out ssoadmin.DescribePermissionSetOutput
+	if c.cache.get(key, &out) {
+		return &out, nil
+	}
+
+	resp, err := c.client.DescribePermissionSet(ctx, params, optFns...)
+	if err != nil {
+		return resp, err
+	}
+
+	c.cache.store(key, resp)
+	return resp, nil
+}
+
+// ListTagsForResource returns cached permission set tags. It fails with
+// ErrMetadataUnsupported when the wrapped client cannot look up tags.
+func (c *CachedSSOAdminClient) ListTagsForResource(ctx context.Context, params *ssoadmin.ListTagsForResourceInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListTagsForResourceOutput, error) {
+	tagsClient, ok := c.client.(SSOAdminTagsClient)
+	if !ok {
+		return nil, ErrMetadataUnsupported
+	}
+
+	key := cacheKey("SSOListTagsForResource",
+		aws.ToString(params.InstanceArn),
+		aws.ToString(params.ResourceArn),
+		aws.ToString(params.NextToken),
+	)
+
+	var out ssoadmin.ListTagsForResourceOutput
+	if c.cache.get(key, &out) {
+		return &out, nil
+	}
+
+	resp, err := tagsClient.ListTagsForResource(ctx, params, optFns...)
+	if err != nil {
+		return resp, err
+	}
+
+	c.cache.store(key, resp)
+	return resp, nil
+}
@@ -0,0 +1,242 @@
+package setlist
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
+)
+
+func newTestCache(t *testing.T, now *time.Time) *Cache {
+	t.Helper()
+	c := NewCache(t.TempDir(), CacheScope("123456789012", "us-east-1"), time.Hour)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestNewCache_DefaultTTL(t *testing.T) {
+	c := NewCache(t.TempDir(), "scope", 0)
+	if c.TTL != DefaultCacheTTL {
+		t.Errorf("TTL = %v, want %v", c.TTL, DefaultCacheTTL)
+	}
+}
+
+func TestCacheScope(t *testing.T) {
+	if got := CacheScope("123456789012", "us-east-1"); got != "123456789012-us-east-1" {
+		t.Errorf("CacheScope() = %q", got)
+	}
+}
+
+func TestCachedOrganizationsClient_ListAccounts(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newTestCache(t, &now)
+
+	calls := 0
+	inner := &mockOrganizationsClient{
+		ListAccountsFunc: func(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+			calls++
+			return &organizations.ListAccountsOutput{
+				Accounts: []orgtypes.Account{
+					{Id: aws.String("111111111111"), Name: aws.String("Dev"), Status: orgtypes.AccountStatusActive},
+				},
+			}, nil
+		},
+	}
+	client := NewCachedOrganizationsClient(inner, cache)
+
+	for i := 0; i < 2; i++ {
+		accounts, err := ListAccounts(context.Background(), client)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(accounts) != 1 || *accounts[0].Name != "Dev" || accounts[0].Status != orgtypes.AccountStatusActive {
+			t.Fatalf("unexpected accounts: %+v", accounts)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("wrapped client called %d times, want 1", calls)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := ListAccounts(context.Background(), client); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expired entry should be refetched, calls = %d", calls)
+	}
+}
+
+func TestCachedOrganizationsClient_Refresh(t *testing.T) {
+	now := time.Now()
+	cache := newTestCache(t, &now)
+
+	calls := 0
+	inner := &mockOrganizationsClient{
+		ListAccountsFunc: func(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+			calls++
+			return &organizations.ListAccountsOutput{}, nil
+		},
+	}
+	client := NewCachedOrganizationsClient(inner, cache)
+
+	if _, err := ListAccounts(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Refresh = true
+	if _, err := ListAccounts(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("refresh should bypass cached entries, calls = %d", calls)
+	}
+
+	cache.Refresh = false
+	if _, err := ListAccounts(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("refreshed entry should be reused, calls = %d", calls)
+	}
+}
+
+func TestCachedOrganizationsClient_ErrorsNotCached(t *testing.T) {
+	now := time.Now()
+	cache := newTestCache(t, &now)
+
+	calls := 0
+	inner := &mockOrganizationsClient{
+		ListAccountsFunc: func(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+			calls++
+			return nil, errors.New("access denied")
+		},
+	}
+	client := NewCachedOrganizationsClient(inner, cache)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListAccounts(context.Background(), &organizations.ListAccountsInput{}); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("errors should not be cached, calls = %d", calls)
+	}
+}
+
+func TestCachedOrganizationsClient_ScopeIsolation(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	inner := &mockOrganizationsClient{
+		ListAccountsFunc: func(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+			calls++
+			return &organizations.ListAccountsOutput{}, nil
+		},
+	}
+
+	a := NewCachedOrganizationsClient(inner, NewCache(dir, CacheScope("111111111111", "us-east-1"), time.Hour))
+	b := NewCachedOrganizationsClient(inner, NewCache(dir, CacheScope("222222222222", "us-east-1"), time.Hour))
+
+	if _, err := ListAccounts(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListAccounts(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("different scopes should not share entries, calls = %d", calls)
+	}
+}
+
+type countingSSOAdminClient struct {
+	listInstances int
+	listProv      int
+	describe      int
+	listAll       int
+}
+
+func (c *countingSSOAdminClient) ListInstances(ctx context.Context, params *ssoadmin.ListInstancesInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListInstancesOutput, error) {
+	c.listInstances++
+	return &ssoadmin.ListInstancesOutput{
+		Instances: []types.InstanceMetadata{
+			{InstanceArn: aws.String("arn:aws:sso:::instance/ssoins-1"), IdentityStoreId: aws.String("d-1234567890")},
+		},
+	}, nil
+}
+
+func (c *countingSSOAdminClient) ListPermissionSets(ctx context.Context, params *ssoadmin.ListPermissionSetsInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsOutput, error) {
+	c.listAll++
+	return &ssoadmin.ListPermissionSetsOutput{}, nil
+}
+
+func (c *countingSSOAdminClient) ListPermissionSetsProvisionedToAccount(ctx context.Context, params *ssoadmin.ListPermissionSetsProvisionedToAccountInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsProvisionedToAccountOutput, error) {
+	c.listProv++
+	return &ssoadmin.ListPermissionSetsProvisionedToAccountOutput{
+		PermissionSets: []string{"arn:ps-1", "arn:ps-2"},
+	}, nil
+}
+
+func (c *countingSSOAdminClient) DescribePermissionSet(ctx context.Context, params *ssoadmin.DescribePermissionSetInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error) {
+	c.describe++
+	return &ssoadmin.DescribePermissionSetOutput{
+		PermissionSet: &types.PermissionSet{
+			Name:             aws.String("Role-" + aws.ToString(params.PermissionSetArn)),
+			PermissionSetArn: params.PermissionSetArn,
+		},
+	}, nil
+}
+
+func TestCachedSSOAdminClient(t *testing.T) {
+	now := time.Now()
+	cache := newTestCache(t, &now)
+	inner := &countingSSOAdminClient{}
+	client := NewCachedSSOAdminClient(inner, cache)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		instance, err := SsoInstance(ctx, client)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *instance.IdentityStoreId != "d-1234567890" {
+			t.Errorf("IdentityStoreId = %q", *instance.IdentityStoreId)
+		}
+
+		sets, err := PermissionSets(ctx, client, *instance.InstanceArn, "111111111111")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(sets) != 2 || *sets[0].Name != "Role-arn:ps-1" || *sets[1].Name != "Role-arn:ps-2" {
+			t.Errorf("unexpected permission sets: %+v", sets)
+		}
+
+		if _, err := AllPermissionSets(ctx, client, *instance.InstanceArn); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if inner.listInstances != 1 {
+		t.Errorf("ListInstances called %d times, want 1", inner.listInstances)
+	}
+	if inner.listProv != 1 {
+		t.Errorf("ListPermissionSetsProvisionedToAccount called %d times, want 1", inner.listProv)
+	}
+	if inner.describe != 2 {
+		t.Errorf("DescribePermissionSet called %d times, want 2", inner.describe)
+	}
+	if inner.listAll != 2 {
+		t.Errorf("ListPermissionSets should pass through, called %d times, want 2", inner.listAll)
+	}
+
+	if _, err := PermissionSets(ctx, client, "arn:aws:sso:::instance/ssoins-1", "222222222222"); err != nil {
+		t.Fatal(err)
+	}
+	if inner.listProv != 2 {
+		t.Errorf("different account should miss the cache, calls = %d", inner.listProv)
+	}
+}
@@ -0,0 +1,141 @@
+package setlist
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Endpoints overrides the endpoints of the services setlist calls, e.g. to
+// run against LocalStack or a local fake. Empty fields leave the SDK's own
+// resolution alone, which honours AWS_ENDPOINT_URL and
+// AWS_ENDPOINT_URL_<SERVICE>. A service-specific field takes precedence
+// over Default.
+type Endpoints struct {
+	Default       string
+	Organizations string
+	SSOAdmin      string
+	S3            string
+	STS           string
+}
+
+// ValidateEndpointURL checks that endpoint is an absolute http:// or
+// https:// URL.
+func ValidateEndpointURL(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q: must be an http:// or https:// URL", endpoint)
+	}
+	return nil
+}
+
+// Validate checks every endpoint that is set with ValidateEndpointURL.
+func (e Endpoints) Validate() error {
+	for _, endpoint := range []struct{ name, url string }{
+		{"default", e.Default},
+		{"organizations", e.Organizations},
+		{"sso-admin", e.SSOAdmin},
+		{"s3", e.S3},
+		{"sts", e.STS},
+	} {
+		if endpoint.url == "" {
+			continue
+		}
+		if err := ValidateEndpointURL(endpoint.url); err != nil {
+			return fmt.Errorf("%s: %w", endpoint.name, err)
+		}
+	}
+	return nil
+}
+
+// endpoint returns the override for a service, falling back to Default.
+func (e Endpoints) endpoint(service string) *string {
+	if service != "" {
+		return aws.String(service)
+	}
+	if e.Default != "" {
+		return aws.String(e.Default)
+	}
+	return nil
+}
+
+// Clients holds the AWS API clients setlist uses. SNS and EventBridge are
+// only used by the Lambda's change notifications, SSM by its config
+// document and output parameters, SecretsManager by its output secrets
+// and git credentials, and KMS by its output signatures; all five follow
+// Default.
+type Clients struct {
+	SSOAdmin       *ssoadmin.Client
+	Organizations  *organizations.Client
+	S3             *s3.Client
+	STS            *sts.Client
+	SNS            *sns.Client
+	EventBridge    *eventbridge.Client
+	SSM            *ssm.Client
+	SecretsManager *secretsmanager.Client
+	KMS            *kms.Client
+}
+
+// NewClients builds every AWS API client setlist uses from cfg, applying
+// the endpoint overrides. S3 uses path-style addressing when its endpoint is
+// overridden, since local fakes rarely serve bucket subdomains.
+func NewClients(cfg aws.Config, endpoints Endpoints) Clients {
+	return Clients{
+		SSOAdmin: ssoadmin.NewFromConfig(cfg, func(o *ssoadmin.Options) {
+			if ep := endpoints.endpoint(endpoints.SSOAdmin); ep != nil {
+				o.BaseEndpoint = ep
+			}
+		}),
+		Organizations: organizations.NewFromConfig(cfg, func(o *organizations.Options) {
+			if ep := endpoints.endpoint(endpoints.Organizations); ep != nil {
+				o.BaseEndpoint = ep
+			}
+		}),
+		S3: s3.NewFromConfig(cfg, func(o *s3.Options) {
+			if ep := endpoints.endpoint(endpoints.S3); ep != nil {
+				o.BaseEndpoint = ep
+				o.UsePathStyle = true
+			}
+		}),
+		STS: sts.NewFromConfig(cfg, func(o *sts.Options) {
+			if ep := endpoints.endpoint(endpoints.STS); ep != nil {
+				o.BaseEndpoint = ep
+			}
+		}),
+		SNS: sns.NewFromConfig(cfg, func(o *sns.Options) {
+			if ep := endpoints.endpoint(""); ep != nil {
+				o.BaseEndpoint = ep
+			}
+		}),
+		EventBridge: eventbridge.NewFromConfig(cfg, func(o *eventbridge.Options) {
+			if ep := endpoints.endpoint(""); ep != nil {
+				o.BaseEndpoint = ep
+			}
+		}),
+		SSM: ssm.NewFromConfig(cfg, func(o *ssm.Options) {
+			if ep := endpoints.endpoint(""); ep != nil {
+				o.BaseEndpoint = ep
+			}
+		}),
+		SecretsManager: secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+			if ep := endpoints.endpoint(""); ep != nil {
+				o.BaseEndpoint = ep
+			}
+		}),
+		KMS: kms.NewFromConfig(cfg, func(o *kms.Options) {
+			if ep := endpoints.endpoint(""); ep != nil {
+				o.BaseEndpoint = ep
+			}
+		}),
+	}
+}
@@ -0,0 +1,80 @@
+package setlist
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestEndpointsValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints Endpoints
+		wantErr   bool
+	}{
+		{name: "none"},
+		{name: "localstack", endpoints: Endpoints{Default: "http://localhost:4566"}},
+		{name: "per service", endpoints: Endpoints{SSOAdmin: "https://sso.internal.example.com"}},
+		{name: "no scheme", endpoints: Endpoints{Default: "localhost:4566"}, wantErr: true},
+		{name: "bad scheme", endpoints: Endpoints{S3: "ftp://localhost"}, wantErr: true},
+		{name: "no host", endpoints: Endpoints{STS: "http://"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.endpoints.Validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClients(t *testing.T) {
+	cfg := aws.Config{Region: "us-east-1"}
+
+	t.Run("no overrides", func(t *testing.T) {
+		c := NewClients(cfg, Endpoints{})
+		if ep := c.SSOAdmin.Options().BaseEndpoint; ep != nil {
+			t.Errorf("SSOAdmin BaseEndpoint = %q, want nil", *ep)
+		}
+		if c.S3.Options().UsePathStyle {
+			t.Error("S3 should keep virtual-hosted addressing without an override")
+		}
+	})
+
+	t.Run("default and per service", func(t *testing.T) {
+		c := NewClients(cfg, Endpoints{Default: "http://localhost:4566", Organizations: "http://localhost:9000"})
+
+		want := map[string]*string{
+			"sso-admin":      c.SSOAdmin.Options().BaseEndpoint,
+			"organizations":  c.Organizations.Options().BaseEndpoint,
+			"s3":             c.S3.Options().BaseEndpoint,
+			"sts":            c.STS.Options().BaseEndpoint,
+			"sns":            c.SNS.Options().BaseEndpoint,
+			"eventbridge":    c.EventBridge.Options().BaseEndpoint,
+			"ssm":            c.SSM.Options().BaseEndpoint,
+			"secretsmanager": c.SecretsManager.Options().BaseEndpoint,
+			"kms":            c.KMS.Options().BaseEndpoint,
+		}
+		expected := map[string]string{
+			"sso-admin":      "http://localhost:4566",
+			"organizations":  "http://localhost:9000",
+			"s3":             "http://localhost:4566",
+			"sts":            "http://localhost:4566",
+			"sns":            "http://localhost:4566",
+			"eventbridge":    "http://localhost:4566",
+			"ssm":            "http://localhost:4566",
+			"secretsmanager": "http://localhost:4566",
+			"kms":            "http://localhost:4566",
+		}
+		for service, ep := range want {
+			if aws.ToString(ep) != expected[service] {
+				t.Errorf("%s BaseEndpoint = %q, want %q", service, aws.ToString(ep), expected[service])
+			}
+		}
+		if !c.S3.Options().UsePathStyle {
+			t.Error("S3 should use path-style addressing with an override")
+		}
+	})
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/scottbrown/setlist"
 
-	"github.com/aws/aws-sdk-go-v2/service/organizations"
 	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
 	"github.com/spf13/cobra"
 )
@@ -23,8 +22,8 @@ var accountsCmd = &cobra.Command{
 }
 
 func init() {
-	accountsCmd.Flags().StringVar(&includeAccounts, FlagIncludeAccounts, "", "Comma-delimited list of account IDs to include (mutually exclusive with --exclude-accounts)")
-	accountsCmd.Flags().StringVar(&excludeAccounts, FlagExcludeAccounts, "", "Comma-delimited list of account IDs to exclude (mutually exclusive with --include-accounts)")
+	accountsCmd.Flags().StringVar(&includeAccounts, FlagIncludeAccounts, "", "Comma-delimited account IDs, globs, re:<regex> or @file to include")
+	accountsCmd.Flags().StringVar(&excludeAccounts, FlagExcludeAccounts, "", "Comma-delimited account IDs, globs, re:<regex> or @file to exclude (applied after include)")
 
 	rootCmd.AddCommand(accountsCmd)
 }
@@ -39,7 +38,10 @@ func handleAccounts(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	orgClient := organizations.NewFromConfig(cfg)
+	_, orgClient, err := newAPIClients(ctx, cfg)
+	if err != nil {
+		return err
+	}
 
 	return handleListAccountsFlow(ctx, orgClient)
 }
@@ -51,22 +53,17 @@ func handleListAccountsFlow(ctx context.Context, orgClient setlist.Organizations
 		return fmt.Errorf("failed to list AWS accounts: %w", err)
 	}
 
-	includeList, err := setlist.ParseAccountIdList(includeAccounts)
+	includeList, err := setlist.ParseAccountPatterns(includeAccounts)
 	if err != nil {
 		return fmt.Errorf("invalid include-accounts: %w", err)
 	}
 
-	excludeList, err := setlist.ParseAccountIdList(excludeAccounts)
+	excludeList, err := setlist.ParseAccountPatterns(excludeAccounts)
 	if err != nil {
 		return fmt.Errorf("invalid exclude-accounts: %w", err)
 	}
 
-	accounts, err = setlist.FilterAccounts(accounts, includeList, excludeList)
-	if err != nil {
-		return fmt.Errorf("account filter error: %w", err)
-	}
-
-	return displayAccounts(accounts)
+	return displayAccounts(setlist.FilterAccountsByPatterns(accounts, includeList, excludeList))
 }
 
 func displayAccounts(accounts []orgtypes.Account) error {
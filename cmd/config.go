@@ -1,109 +1,256 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"strings"
 
+	"github.com/scottbrown/setlist"
+
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
+	"github.com/spf13/pflag"
 )
 
-type SetlistConfig struct {
-	SSOSession            string `yaml:"sso-session"`
-	SSORegion             string `yaml:"sso-region"`
-	Profile               string `yaml:"profile"`
-	Mapping               string `yaml:"mapping"`
-	Output                string `yaml:"output"`
-	Stdout                *bool  `yaml:"stdout"`
-	SSOFriendlyName       string `yaml:"sso-friendly-name"`
-	Verbose               *bool  `yaml:"verbose"`
-	LogFormat             string `yaml:"log-format"`
-	IncludeAccounts       string `yaml:"include-accounts"`
-	ExcludeAccounts       string `yaml:"exclude-accounts"`
-	IncludePermissionSets string `yaml:"include-permission-sets"`
-	ExcludePermissionSets string `yaml:"exclude-permission-sets"`
+func defaultConfigPath() (string, error) {
+	return settings.DefaultPath()
 }
 
-func defaultConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("unable to determine home directory: %w", err)
+// configFilePath returns the config file path from --config or
+// SETLIST_CONFIG, falling back to the default path. explicit reports
+// whether the user named the file, in which case it must exist.
+func configFilePath() (path string, explicit bool, err error) {
+	if configFile != "" {
+		return configFile, true, nil
 	}
-	return filepath.Join(home, ".setlist.yaml"), nil
+	if env := os.Getenv(settings.EnvName(FlagConfig)); env != "" {
+		return env, true, nil
+	}
+
+	path, err = defaultConfigPath()
+	return path, false, err
 }
 
-func loadConfigFile(cmd *cobra.Command) error {
-	configExplicit := cmd.Flags().Changed(FlagConfig)
+// readConfig reads the config file at path along with any base files it
+// extends.
+func readConfig(path string) (*settings.File, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DEFAULT_TIMEOUT)
+	defer cancel()
 
-	path := configFile
-	if path == "" {
-		defaultPath, err := defaultConfigPath()
+	loader := &settings.Loader{S3Client: &lazyS3Client{}}
+	return loader.Load(ctx, path)
+}
+
+// lazyS3Client builds an S3 client on first use, so AWS configuration is
+// only loaded when a config file extends a base stored in S3.
+type lazyS3Client struct {
+	client *s3.Client
+}
+
+func (c *lazyS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if c.client == nil {
+		var opts []func(*config.LoadOptions) error
+		if profile != "" {
+			opts = append(opts, config.WithSharedConfigProfile(profile))
+		}
+
+		cfg, err := config.LoadDefaultConfig(ctx, opts...)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
 		}
-		path = defaultPath
+		c.client = setlist.NewClients(cfg, endpoints()).S3
+	}
+	return c.client.GetObject(ctx, params, optFns...)
+}
+
+// selectedContext returns the context requested via --context or the
+// SETLIST_CONTEXT environment variable, in that order. An empty result
+// means the config file's current-context applies.
+func selectedContext(cmd *cobra.Command) string {
+	if flagExists(cmd, FlagContext) && cmd.Flags().Changed(FlagContext) {
+		return contextName
 	}
+	return os.Getenv(settings.EnvName(FlagContext))
+}
 
-	data, err := os.ReadFile(path)
+// configFileLayers returns the settings layers contributed by the config
+// file: the selected context followed by the top-level values. A missing
+// default config file contributes nothing.
+func configFileLayers(cmd *cobra.Command) ([]settings.Layer, error) {
+	path, explicit, err := configFilePath()
 	if err != nil {
-		if os.IsNotExist(err) && !configExplicit {
-			return nil
+		return nil, err
+	}
+
+	cfg, err := readConfig(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			if name := selectedContext(cmd); name != "" {
+				return nil, fmt.Errorf("context %q requested but no config file exists at %s", name, path)
+			}
+			return nil, nil
 		}
-		return fmt.Errorf("unable to read config file %s: %w", path, err)
+		return nil, err
 	}
 
-	var cfg SetlistConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return fmt.Errorf("unable to parse config file %s: %w", path, err)
+	layers, err := cfg.Layers(selectedContext(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
 	}
+	return layers, nil
+}
 
-	applyConfig(cmd, &cfg)
-	return nil
+// settingKeys returns every option key that applies to cmd: its flags plus
+// the keys the config file accepts.
+func settingKeys(cmd *cobra.Command) []string {
+	seen := map[string]bool{}
+	var keys []string
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Name != "help" && f.Name != "version" {
+			add(f.Name)
+		}
+	})
+	for _, key := range settings.Keys() {
+		add(key)
+	}
+	return keys
 }
 
-func flagExists(cmd *cobra.Command, name string) bool {
-	return cmd.Flags().Lookup(name) != nil
+// flagLayer returns the flags the user set explicitly on the command line.
+func flagLayer(cmd *cobra.Command) settings.Layer {
+	values := map[string]string{}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return settings.Layer{Source: settings.SourceFlag, Values: values}
 }
 
-func applyConfig(cmd *cobra.Command, cfg *SetlistConfig) {
-	if !cmd.Flags().Changed(FlagSSOSession) && cfg.SSOSession != "" {
-		ssoSession = cfg.SSOSession
-	}
-	if !cmd.Flags().Changed(FlagSSORegion) && cfg.SSORegion != "" {
-		ssoRegion = cfg.SSORegion
+// defaultLayer returns the non-empty default values of the flags in sets.
+// Earlier sets win when a flag is registered more than once.
+func defaultLayer(sets ...*pflag.FlagSet) settings.Layer {
+	values := map[string]string{}
+	for _, set := range sets {
+		set.VisitAll(func(f *pflag.Flag) {
+			if _, ok := values[f.Name]; !ok && f.DefValue != "" && f.Name != "help" && f.Name != "version" {
+				values[f.Name] = f.DefValue
+			}
+		})
 	}
-	if !cmd.Flags().Changed(FlagProfile) && cfg.Profile != "" {
-		profile = cfg.Profile
-	}
-	if flagExists(cmd, FlagMapping) && !cmd.Flags().Changed(FlagMapping) && cfg.Mapping != "" {
-		mapping = cfg.Mapping
+	return settings.Layer{Source: settings.SourceDefault, Values: values}
+}
+
+// resolveSettings resolves every option that applies to cmd. Precedence is
+// flag > SETLIST_* environment variable > --from-sso-session > selected
+// context > top-level config file value > flag default.
+func resolveSettings(cmd *cobra.Command, defaults ...*pflag.FlagSet) (settings.Resolved, error) {
+	fileLayers, err := configFileLayers(cmd)
+	if err != nil {
+		return nil, err
 	}
-	if flagExists(cmd, FlagOutput) && !cmd.Flags().Changed(FlagOutput) && cfg.Output != "" {
-		filename = cfg.Output
+
+	layers := []settings.Layer{
+		flagLayer(cmd),
+		settings.EnvLayer(settingKeys(cmd), os.LookupEnv),
 	}
-	if flagExists(cmd, FlagStdout) && !cmd.Flags().Changed(FlagStdout) && cfg.Stdout != nil {
-		stdout = *cfg.Stdout
+	layers = append(layers, fileLayers...)
+	layers = append(layers, defaultLayer(append([]*pflag.FlagSet{cmd.Flags()}, defaults...)...))
+
+	resolved := settings.Resolve(layers...)
+
+	// --from-sso-session can itself come from any layer, so its values are
+	// slotted in below the environment once it is known.
+	if name := resolved.Get(FlagFromSSOSession); name != "" {
+		session, err := sharedSessionLayer(name)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers[:2], append([]settings.Layer{session}, layers[2:]...)...)
+		resolved = settings.Resolve(layers...)
 	}
-	if flagExists(cmd, FlagSSOFriendlyName) && !cmd.Flags().Changed(FlagSSOFriendlyName) && cfg.SSOFriendlyName != "" {
-		ssoFriendlyName = cfg.SSOFriendlyName
+
+	return resolved, nil
+}
+
+// sharedSessionLayer returns the session name, region and start URL of an
+// [sso-session] section in the shared AWS config file.
+func sharedSessionLayer(name string) (settings.Layer, error) {
+	path, err := setlist.DefaultSharedConfigPath()
+	if err != nil {
+		return settings.Layer{}, err
 	}
-	if !cmd.Flags().Changed(FlagVerbose) && cfg.Verbose != nil {
-		verbose = *cfg.Verbose
+
+	shared, err := setlist.LoadSharedConfig(path)
+	if err != nil {
+		return settings.Layer{}, err
 	}
-	if !cmd.Flags().Changed(FlagLogFormat) && cfg.LogFormat != "" {
-		logFormat = cfg.LogFormat
+
+	session, ok := shared.Session(name)
+	if !ok {
+		names := make([]string, 0, len(shared.Sessions))
+		for _, s := range shared.Sessions {
+			names = append(names, s.Name)
+		}
+		if len(names) == 0 {
+			return settings.Layer{}, fmt.Errorf("sso-session %q not found in %s: no sso-session sections are defined", name, path)
+		}
+		return settings.Layer{}, fmt.Errorf("sso-session %q not found in %s (available: %s)", name, path, strings.Join(names, ", "))
 	}
-	if flagExists(cmd, FlagIncludeAccounts) && !cmd.Flags().Changed(FlagIncludeAccounts) && cfg.IncludeAccounts != "" {
-		includeAccounts = cfg.IncludeAccounts
+
+	values := map[string]string{FlagSSOSession: session.Name}
+	if session.Region != "" {
+		values[FlagSSORegion] = session.Region
 	}
-	if flagExists(cmd, FlagExcludeAccounts) && !cmd.Flags().Changed(FlagExcludeAccounts) && cfg.ExcludeAccounts != "" {
-		excludeAccounts = cfg.ExcludeAccounts
+	if session.StartURL != "" {
+		values[FlagSSOStartURL] = session.StartURL
 	}
-	if flagExists(cmd, FlagIncludePermissionSets) && !cmd.Flags().Changed(FlagIncludePermissionSets) && cfg.IncludePermissionSets != "" {
-		includePermissionSets = cfg.IncludePermissionSets
+
+	return settings.Layer{Source: fmt.Sprintf("sso-session %s in %s", name, path), Values: values}, nil
+}
+
+func loadConfigFile(cmd *cobra.Command) error {
+	resolved, err := resolveSettings(cmd)
+	if err != nil {
+		return err
 	}
-	if flagExists(cmd, FlagExcludePermissionSets) && !cmd.Flags().Changed(FlagExcludePermissionSets) && cfg.ExcludePermissionSets != "" {
-		excludePermissionSets = cfg.ExcludePermissionSets
+	return applySettings(cmd, resolved)
+}
+
+func flagExists(cmd *cobra.Command, name string) bool {
+	return cmd.Flags().Lookup(name) != nil
+}
+
+// applySettings copies resolved values into the flag variables for every
+// flag the user did not set explicitly. Keys without a matching flag on
+// cmd are ignored. Setting the value directly leaves the flag unmarked as
+// changed, so later Changed checks still mean "set on the command line".
+func applySettings(cmd *cobra.Command, resolved settings.Resolved) error {
+	for _, key := range resolved.Keys() {
+		setting := resolved[key]
+		if setting.Source == settings.SourceFlag || setting.Source == settings.SourceDefault {
+			continue
+		}
+
+		f := cmd.Flags().Lookup(key)
+		if f == nil {
+			continue
+		}
+
+		if err := f.Value.Set(setting.Value); err != nil {
+			return fmt.Errorf("invalid value %q for %s from %s: %w", setting.Value, key, setting.Source, err)
+		}
 	}
+	return nil
 }
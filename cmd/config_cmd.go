@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// FlagSources is local to `config show`, so it lives here rather than with
+// the shared flag names.
+const FlagSources = "sources"
+
+var showSources bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspecting and validating the configuration",
+	Long:  "Inspecting the configuration resolved from flags, SETLIST_* environment variables, the config file and defaults, and validating the config file",
+	// A broken config file is what validate is for, so don't apply it
+	// before running.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return configureLogging()
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Showing the effective configuration",
+	Long:  "Showing the effective value of every option as YAML, after flags, SETLIST_* environment variables, the selected context, the config file and defaults are merged",
+	Args:  cobra.NoArgs,
+	RunE:  handleConfigShowCommand,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validating the configuration file",
+	Long:  "Validating the configuration file, reporting unknown keys, malformed values, invalid patterns, filters and regions, and undefined contexts all at once",
+	Args:  cobra.NoArgs,
+	RunE:  handleConfigValidateCommand,
+	// The problems are the useful output; usage text would bury them.
+	SilenceUsage: true,
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&showSources, FlagSources, false, "Annotate each value with where it came from")
+
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func handleConfigShowCommand(cmd *cobra.Command, args []string) error {
+	// Options such as output and mapping belong to generate, so include its
+	// defaults to show the whole configuration.
+	resolved, err := resolveSettings(cmd, generateCmd.LocalFlags())
+	if err != nil {
+		return err
+	}
+
+	// Drop the flags of this command itself, which are not configuration.
+	cmd.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		delete(resolved, f.Name)
+	})
+
+	return showSettings(cmd.OutOrStdout(), resolved, showSources)
+}
+
+// showSettings writes resolved settings as a YAML map in key order. With
+// sources set, each value carries a comment naming where it came from.
+func showSettings(w io.Writer, resolved settings.Resolved, sources bool) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range resolved.Keys() {
+		setting := resolved[key]
+
+		k := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+		v := &yaml.Node{Kind: yaml.ScalarNode, Value: setting.Value}
+		if sources {
+			v.LineComment = setting.Source
+		}
+		doc.Content = append(doc.Content, k, v)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("unable to encode configuration: %w", err)
+	}
+	return enc.Close()
+}
+
+func handleConfigValidateCommand(cmd *cobra.Command, args []string) error {
+	path, _, err := configFilePath()
+	if err != nil {
+		return err
+	}
+
+	return validateConfigFile(cmd.OutOrStdout(), path)
+}
+
+// validateConfigFile writes every problem found in the config file at path
+// and returns an error if there were any.
+func validateConfigFile(w io.Writer, path string) error {
+	data, err := os.ReadFile(path) //#nosec: G304
+	if err != nil {
+		return fmt.Errorf("unable to read config file %s: %w", path, err)
+	}
+
+	problems := settings.Validate(data)
+	if len(problems) == 0 {
+		// The file is fine on its own; make sure its bases load and merge.
+		if _, err := readConfig(path); err != nil {
+			problems = append(problems, settings.Problem{Message: err.Error()})
+		}
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s is valid\n", path)
+		return nil
+	}
+
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s: %s\n", path, p)
+	}
+	return fmt.Errorf("config file %s has %d problem(s)", path, len(problems))
+}
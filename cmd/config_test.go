@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
 
 	"github.com/spf13/cobra"
 )
@@ -13,7 +18,18 @@ func newTestCommand() *cobra.Command {
 	cmd := &cobra.Command{Use: "test"}
 	cmd.Flags().StringVar(&ssoSession, FlagSSOSession, "", "")
 	cmd.Flags().StringVar(&ssoRegion, FlagSSORegion, "", "")
+	cmd.Flags().BoolVar(&allowUnknownRegion, FlagAllowUnknownRegion, false, "")
 	cmd.Flags().StringVar(&profile, FlagProfile, "", "")
+	cmd.Flags().StringVar(&assumeRoleArn, FlagAssumeRoleArn, "", "")
+	cmd.Flags().StringVar(&externalId, FlagExternalId, "", "")
+	cmd.Flags().StringVar(&roleSessionName, FlagRoleSessionName, setlist.DefaultRoleSessionName, "")
+	cmd.Flags().StringVar(&mfaSerial, FlagMFASerial, "", "")
+	cmd.Flags().StringVar(&mfaToken, FlagMFAToken, "", "")
+	cmd.Flags().StringVar(&endpointURL, FlagEndpointURL, "", "")
+	cmd.Flags().StringVar(&orgEndpointURL, FlagOrgEndpointURL, "", "")
+	cmd.Flags().StringVar(&ssoAdminEndpointURL, FlagSSOAdminEndpointURL, "", "")
+	cmd.Flags().StringVar(&s3EndpointURL, FlagS3EndpointURL, "", "")
+	cmd.Flags().StringVar(&stsEndpointURL, FlagSTSEndpointURL, "", "")
 	cmd.Flags().StringVar(&mapping, FlagMapping, "", "")
 	cmd.Flags().StringVar(&filename, FlagOutput, DEFAULT_FILENAME, "")
 	cmd.Flags().BoolVar(&stdout, FlagStdout, false, "")
@@ -24,14 +40,34 @@ func newTestCommand() *cobra.Command {
 	cmd.Flags().StringVar(&excludeAccounts, FlagExcludeAccounts, "", "")
 	cmd.Flags().StringVar(&includePermissionSets, FlagIncludePermissionSets, "", "")
 	cmd.Flags().StringVar(&excludePermissionSets, FlagExcludePermissionSets, "", "")
+	cmd.Flags().StringVar(&filter, FlagFilter, "", "")
+	cmd.Flags().StringVar(&fromSSOSession, FlagFromSSOSession, "", "")
+	cmd.Flags().StringVar(&ssoStartURL, FlagSSOStartURL, "", "")
+	cmd.Flags().StringVar(&instanceArn, FlagInstanceArn, "", "")
+	cmd.Flags().StringVar(&identityStoreId, FlagIdentityStoreId, "", "")
 	cmd.Flags().StringVar(&configFile, FlagConfig, "", "")
+	cmd.Flags().StringVar(&contextName, FlagContext, "", "")
+	cmd.Flags().BoolVar(&noCache, FlagNoCache, false, "")
+	cmd.Flags().BoolVar(&refreshCache, FlagRefresh, false, "")
+	cmd.Flags().DurationVar(&cacheTTL, FlagCacheTTL, setlist.DefaultCacheTTL, "")
 	return cmd
 }
 
 func resetGlobals() {
 	ssoSession = ""
 	ssoRegion = ""
+	allowUnknownRegion = false
 	profile = ""
+	assumeRoleArn = ""
+	externalId = ""
+	roleSessionName = setlist.DefaultRoleSessionName
+	mfaSerial = ""
+	mfaToken = ""
+	endpointURL = ""
+	orgEndpointURL = ""
+	ssoAdminEndpointURL = ""
+	s3EndpointURL = ""
+	stsEndpointURL = ""
 	mapping = ""
 	filename = DEFAULT_FILENAME
 	stdout = false
@@ -42,7 +78,20 @@ func resetGlobals() {
 	excludeAccounts = ""
 	includePermissionSets = ""
 	excludePermissionSets = ""
+	filter = ""
+	fromSSOSession = ""
+	ssoStartURL = ""
+	instanceArn = ""
+	identityStoreId = ""
 	configFile = ""
+	contextName = ""
+	noCache = false
+	refreshCache = false
+	cacheTTL = setlist.DefaultCacheTTL
+	signKey = ""
+	verifyKey = ""
+	signatureFile = ""
+	syncFrom = ""
 }
 
 func TestLoadConfigFile_ValidConfig(t *testing.T) {
@@ -63,6 +112,7 @@ include-accounts: "111111111111"
 exclude-accounts: "222222222222"
 include-permission-sets: AdminAccess
 exclude-permission-sets: ReadOnly
+filter: ps.name != "Break"
 `
 	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
 		t.Fatal(err)
@@ -115,6 +165,9 @@ exclude-permission-sets: ReadOnly
 	if excludePermissionSets != "ReadOnly" {
 		t.Errorf("excludePermissionSets = %q, want %q", excludePermissionSets, "ReadOnly")
 	}
+	if filter != `ps.name != "Break"` {
+		t.Errorf("filter = %q, want %q", filter, `ps.name != "Break"`)
+	}
 }
 
 func TestLoadConfigFile_FlagOverridesConfig(t *testing.T) {
@@ -304,6 +357,100 @@ stdout: true
 	}
 }
 
+func TestLoadConfigFile_CacheSettings(t *testing.T) {
+	resetGlobals()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	content := `no-cache: true
+cache-ttl: 2h
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newTestCommand()
+	configFile = cfgPath
+	cmd.Flags().Set(FlagConfig, cfgPath)
+
+	if err := loadConfigFile(cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if noCache != true {
+		t.Errorf("noCache = %v, want true", noCache)
+	}
+	if cacheTTL != 2*time.Hour {
+		t.Errorf("cacheTTL = %v, want %v", cacheTTL, 2*time.Hour)
+	}
+}
+
+func TestLoadConfigFile_CacheTTLFlagOverridesConfig(t *testing.T) {
+	resetGlobals()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(cfgPath, []byte("cache-ttl: 2h\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newTestCommand()
+	configFile = cfgPath
+	cmd.Flags().Set(FlagConfig, cfgPath)
+	cmd.Flags().Set(FlagCacheTTL, "5m")
+
+	if err := loadConfigFile(cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cacheTTL != 5*time.Minute {
+		t.Errorf("cacheTTL = %v, want %v (flag should override config)", cacheTTL, 5*time.Minute)
+	}
+}
+
+func TestLoadConfigFile_NativeListsAndMaps(t *testing.T) {
+	resetGlobals()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	content := `version: 1
+mapping:
+  "210987654321": staging
+  123456789012: prod
+include-accounts:
+  - 123456789012
+  - "2109*"
+exclude-accounts: []
+include-permission-sets:
+  - ReadOnly*
+  - re:^Platform-
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newTestCommand()
+	configFile = cfgPath
+	cmd.Flags().Set(FlagConfig, cfgPath)
+
+	if err := loadConfigFile(cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mapping != "123456789012=prod,210987654321=staging" {
+		t.Errorf("mapping = %q, want sorted comma-delimited pairs", mapping)
+	}
+	if includeAccounts != "123456789012,2109*" {
+		t.Errorf("includeAccounts = %q, want %q", includeAccounts, "123456789012,2109*")
+	}
+	if excludeAccounts != "" {
+		t.Errorf("excludeAccounts = %q, want empty", excludeAccounts)
+	}
+	if includePermissionSets != "ReadOnly*,re:^Platform-" {
+		t.Errorf("includePermissionSets = %q, want %q", includePermissionSets, "ReadOnly*,re:^Platform-")
+	}
+}
+
 func TestLoadConfigFile_CustomPath(t *testing.T) {
 	resetGlobals()
 
@@ -353,3 +500,173 @@ func TestConfigFlagRegistered(t *testing.T) {
 		t.Errorf("Expected --%s shorthand to be 'c', got %q", FlagConfig, flag.Shorthand)
 	}
 }
+
+func TestLoadConfigFile_EnvPrecedence(t *testing.T) {
+	resetGlobals()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	content := "sso-session: from-file\nsso-region: us-east-1\nprofile: from-file\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SETLIST_CONFIG", cfgPath)
+	t.Setenv("SETLIST_SSO_REGION", "ca-central-1")
+	t.Setenv("SETLIST_PROFILE", "from-env")
+	t.Setenv("SETLIST_STDOUT", "true")
+
+	cmd := newTestCommand()
+	cmd.Flags().Set(FlagProfile, "from-flag")
+
+	if err := loadConfigFile(cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if profile != "from-flag" {
+		t.Errorf("profile = %q, want flag value", profile)
+	}
+	if ssoRegion != "ca-central-1" {
+		t.Errorf("ssoRegion = %q, want env value", ssoRegion)
+	}
+	if ssoSession != "from-file" {
+		t.Errorf("ssoSession = %q, want config file value", ssoSession)
+	}
+	if !stdout {
+		t.Error("stdout should be set from SETLIST_STDOUT")
+	}
+	if cmd.Flags().Changed(FlagStdout) {
+		t.Error("values from the environment should not mark flags as changed")
+	}
+}
+
+func TestLoadConfigFile_FromSSOSession(t *testing.T) {
+	dir := t.TempDir()
+	awsConfig := filepath.Join(dir, "aws-config")
+	content := `[sso-session corp]
+sso_start_url = https://sso.corp.example.com/start
+sso_region = eu-west-1
+
+[sso-session other]
+sso_start_url = https://other.awsapps.com/start
+sso_region = us-east-1
+`
+	if err := os.WriteFile(awsConfig, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", dir)
+	t.Setenv("AWS_CONFIG_FILE", awsConfig)
+
+	t.Run("fills session, region and start url", func(t *testing.T) {
+		resetGlobals()
+		cmd := newTestCommand()
+		cmd.Flags().Set(FlagFromSSOSession, "corp")
+
+		if err := loadConfigFile(cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ssoSession != "corp" {
+			t.Errorf("ssoSession = %q, want corp", ssoSession)
+		}
+		if ssoRegion != "eu-west-1" {
+			t.Errorf("ssoRegion = %q, want eu-west-1", ssoRegion)
+		}
+		if ssoStartURL != "https://sso.corp.example.com/start" {
+			t.Errorf("ssoStartURL = %q, want the custom start url", ssoStartURL)
+		}
+	})
+
+	t.Run("flags win over the sso-session", func(t *testing.T) {
+		resetGlobals()
+		cmd := newTestCommand()
+		cmd.Flags().Set(FlagFromSSOSession, "corp")
+		cmd.Flags().Set(FlagSSORegion, "ca-central-1")
+
+		if err := loadConfigFile(cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ssoRegion != "ca-central-1" {
+			t.Errorf("ssoRegion = %q, want flag value", ssoRegion)
+		}
+	})
+
+	t.Run("unknown session lists the available ones", func(t *testing.T) {
+		resetGlobals()
+		cmd := newTestCommand()
+		cmd.Flags().Set(FlagFromSSOSession, "missing")
+
+		err := loadConfigFile(cmd)
+		if err == nil || !strings.Contains(err.Error(), "available: corp, other") {
+			t.Errorf("error = %v, want it to list the sessions", err)
+		}
+	})
+}
+
+func TestLoadConfigFile_InvalidEnvValue(t *testing.T) {
+	resetGlobals()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SETLIST_CACHE_TTL", "soon")
+
+	cmd := newTestCommand()
+	err := loadConfigFile(cmd)
+	if err == nil || !strings.Contains(err.Error(), "env SETLIST_CACHE_TTL") {
+		t.Errorf("error = %v, want it to name the variable", err)
+	}
+}
+
+func TestShowSettings(t *testing.T) {
+	resolved := settings.Resolved{
+		"sso-region": {Value: "us-east-1", Source: "env SETLIST_SSO_REGION"},
+		"stdout":     {Value: "true", Source: "config file"},
+	}
+
+	var buf bytes.Buffer
+	if err := showSettings(&buf, resolved, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "sso-region: us-east-1\nstdout: true\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := showSettings(&buf, resolved, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "sso-region: us-east-1 # env SETLIST_SSO_REGION\nstdout: true # config file\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.yaml")
+	if err := os.WriteFile(valid, []byte("sso-session: myorg\nsso-region: us-east-1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := validateConfigFile(&buf, valid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "is valid") {
+		t.Errorf("output = %q", buf.String())
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("sso_region: us-east-1\nmapping: nonsense\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	err := validateConfigFile(&buf, invalid)
+	if err == nil || !strings.Contains(err.Error(), "2 problem(s)") {
+		t.Errorf("error = %v, want both problems counted", err)
+	}
+	for _, want := range []string{invalid + ": line 1: field sso_region not found", invalid + ": line 2: mapping:"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output = %q, want it to contain %q", buf.String(), want)
+		}
+	}
+}
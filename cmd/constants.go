@@ -15,18 +15,43 @@ Parses an AWS organizations permission set structure to build a complete .aws/co
 const (
 	FlagSSOSession            string = "sso-session"
 	FlagSSORegion             string = "sso-region"
+	FlagAllowUnknownRegion    string = "allow-unknown-region"
 	FlagProfile               string = "profile"
 	FlagMapping               string = "mapping"
 	FlagOutput                string = "output"
 	FlagStdout                string = "stdout"
 	FlagSSOFriendlyName       string = "sso-friendly-name"
+	FlagSSOStartURL           string = "sso-start-url"
 	FlagIncludeAccounts       string = "include-accounts"
 	FlagExcludeAccounts       string = "exclude-accounts"
 	FlagIncludePermissionSets string = "include-permission-sets"
 	FlagExcludePermissionSets string = "exclude-permission-sets"
+	FlagFilter                string = "filter"
 	FlagVerbose               string = "verbose"
 	FlagLogFormat             string = "log-format"
 	FlagConfig                string = "config"
+	FlagContext               string = "context"
+	FlagNoCache               string = "no-cache"
+	FlagRefresh               string = "refresh"
+	FlagCacheTTL              string = "cache-ttl"
+	FlagFromSSOSession        string = "from-sso-session"
+	FlagInstanceArn           string = "instance-arn"
+	FlagIdentityStoreId       string = "identity-store-id"
+	FlagAssumeRoleArn         string = "assume-role-arn"
+	FlagExternalId            string = "external-id"
+	FlagRoleSessionName       string = "role-session-name"
+	FlagMFASerial             string = "mfa-serial"
+	FlagMFAToken              string = "mfa-token"
+	FlagEndpointURL           string = "endpoint-url"
+	FlagOrgEndpointURL        string = "organizations-endpoint-url"
+	FlagSSOAdminEndpointURL   string = "sso-admin-endpoint-url"
+	FlagS3EndpointURL         string = "s3-endpoint-url"
+	FlagSTSEndpointURL        string = "sts-endpoint-url"
+	FlagSignKey               string = "sign-key"
+	FlagKey                   string = "key"
+	FlagSignature             string = "signature"
+	FlagFrom                  string = "from"
+	FlagVerifyKey             string = "verify-key"
 )
 
 const DEFAULT_FILENAME string = "aws.config"
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Managing named contexts in the configuration file",
+	Long:  "Listing, selecting and showing the named contexts defined in .setlist.yaml",
+	// Contexts are managed here, so skip resolving one before running.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return configureLogging()
+	},
+}
+
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Listing the contexts in the configuration file",
+	Long:  "Listing the contexts in the configuration file, marking the active one with an asterisk",
+	Args:  cobra.NoArgs,
+	RunE:  handleContextListCommand,
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use NAME",
+	Short: "Setting the current context",
+	Long:  "Setting current-context in the configuration file so later commands use the named context",
+	Args:  cobra.ExactArgs(1),
+	RunE:  handleContextUseCommand,
+}
+
+var contextShowCmd = &cobra.Command{
+	Use:   "show [NAME]",
+	Short: "Showing the settings of a context",
+	Long:  "Showing the effective settings of a context, after top-level defaults are applied (default: the active context)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  handleContextShowCommand,
+}
+
+func init() {
+	contextCmd.AddCommand(contextListCmd)
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextShowCmd)
+	rootCmd.AddCommand(contextCmd)
+}
+
+func handleContextListCommand(cmd *cobra.Command, args []string) error {
+	path, _, err := configFilePath()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+
+	return listContexts(cmd.OutOrStdout(), cfg, selectedContext(cmd))
+}
+
+func handleContextUseCommand(cmd *cobra.Command, args []string) error {
+	path, _, err := configFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := useContext(path, args[0]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Switched to context %q\n", args[0])
+	return nil
+}
+
+func handleContextShowCommand(cmd *cobra.Command, args []string) error {
+	path, _, err := configFilePath()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+
+	name := selectedContext(cmd)
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	return showContext(cmd.OutOrStdout(), cfg, name)
+}
+
+// listContexts writes one context name per line, marking the active one.
+// The active context is the selected one if given, otherwise current-context.
+func listContexts(w io.Writer, cfg *settings.File, selected string) error {
+	if len(cfg.Contexts) == 0 {
+		fmt.Fprintln(w, "No contexts defined")
+		return nil
+	}
+
+	active := selected
+	if active == "" {
+		active = cfg.CurrentContext
+	}
+
+	for _, name := range cfg.ContextNames() {
+		marker := " "
+		if name == active {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %s\n", marker, name)
+	}
+	return nil
+}
+
+// showContext writes the effective settings of the named context as YAML.
+func showContext(w io.Writer, cfg *settings.File, name string) error {
+	values, err := cfg.Resolve(name)
+	if err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(values); err != nil {
+		return fmt.Errorf("unable to encode context: %w", err)
+	}
+	return enc.Close()
+}
+
+// useContext sets current-context in the config file at path. The file is
+// edited as a YAML node tree so comments and key order are kept.
+func useContext(path, name string) error {
+	cfg, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Resolve(name); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a YAML map", path)
+	}
+
+	setMappingValue(doc.Content[0], "current-context", name)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("unable to write config file %s: %w", path, err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("unable to write config file %s: %w", path, err)
+	}
+
+	if err := replaceFile(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("unable to write config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// replaceFile replaces the contents of the file at path, keeping its mode.
+// The data is written to a temporary file in the same directory and renamed
+// into place, so a failed write leaves the original intact. A symlink is
+// followed, so the file it points to is replaced rather than the link.
+func replaceFile(path string, data []byte) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //#nosec: G104
+
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close() //#nosec: G104
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //#nosec: G104
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() //#nosec: G104
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
+
+// setMappingValue sets key to a string value in a YAML mapping node,
+// appending the key if it is not already present.
+func setMappingValue(m *yaml.Node, key, value string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1].SetString(value)
+			return
+		}
+	}
+
+	k := &yaml.Node{}
+	k.SetString(key)
+	v := &yaml.Node{}
+	v.SetString(value)
+	m.Content = append(m.Content, k, v)
+}
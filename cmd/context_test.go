@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scottbrown/setlist/settings"
+)
+
+const contextsConfig = `version: 1
+# Applies to every context
+sso-region: us-east-1
+output: shared.config
+current-context: prod
+contexts:
+  prod:
+    sso-session: prod-org
+    mapping:
+      "123456789012": prod
+  sandbox:
+    sso-session: sandbox-org
+    sso-region: ca-central-1
+`
+
+func writeContextsConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contextsConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile_ContextSelection(t *testing.T) {
+	tests := []struct {
+		name           string
+		flag           string
+		env            string
+		wantSession    string
+		wantRegion     string
+		wantMapping    string
+		wantErrContain string
+	}{
+		{name: "current-context", wantSession: "prod-org", wantRegion: "us-east-1", wantMapping: "123456789012=prod"},
+		{name: "env overrides current-context", env: "sandbox", wantSession: "sandbox-org", wantRegion: "ca-central-1"},
+		{name: "flag overrides env", flag: "prod", env: "sandbox", wantSession: "prod-org", wantRegion: "us-east-1", wantMapping: "123456789012=prod"},
+		{name: "unknown context", flag: "staging", wantErrContain: `context "staging" not found (available: prod, sandbox)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetGlobals()
+			t.Setenv(settings.EnvName(FlagContext), tt.env)
+
+			path := writeContextsConfig(t)
+			cmd := newTestCommand()
+			cmd.Flags().Set(FlagConfig, path)
+			if tt.flag != "" {
+				cmd.Flags().Set(FlagContext, tt.flag)
+			}
+
+			err := loadConfigFile(cmd)
+			if tt.wantErrContain != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContain) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErrContain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if ssoSession != tt.wantSession {
+				t.Errorf("ssoSession = %q, want %q", ssoSession, tt.wantSession)
+			}
+			if ssoRegion != tt.wantRegion {
+				t.Errorf("ssoRegion = %q, want %q", ssoRegion, tt.wantRegion)
+			}
+			if mapping != tt.wantMapping {
+				t.Errorf("mapping = %q, want %q", mapping, tt.wantMapping)
+			}
+			if filename != "shared.config" {
+				t.Errorf("filename = %q, want top-level default %q", filename, "shared.config")
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile_FlagOverridesContext(t *testing.T) {
+	resetGlobals()
+	t.Setenv(settings.EnvName(FlagContext), "")
+
+	path := writeContextsConfig(t)
+	cmd := newTestCommand()
+	cmd.Flags().Set(FlagConfig, path)
+	cmd.Flags().Set(FlagSSOSession, "from-flag")
+
+	if err := loadConfigFile(cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ssoSession != "from-flag" {
+		t.Errorf("ssoSession = %q, want %q", ssoSession, "from-flag")
+	}
+}
+
+func TestLoadConfigFile_ContextWithoutFile(t *testing.T) {
+	resetGlobals()
+	t.Setenv("HOME", t.TempDir())
+
+	cmd := newTestCommand()
+	cmd.Flags().Set(FlagContext, "prod")
+
+	if err := loadConfigFile(cmd); err == nil {
+		t.Fatal("expected error when a context is requested without a config file")
+	}
+}
+
+func TestListContexts(t *testing.T) {
+	cfg, err := settings.Read(writeContextsConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := listContexts(&buf, cfg, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "* prod\n  sandbox\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := listContexts(&buf, cfg, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "  prod\n* sandbox\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestShowContext(t *testing.T) {
+	cfg, err := settings.Read(writeContextsConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := showContext(&buf, cfg, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "sso-session: sandbox-org\nsso-region: ca-central-1\noutput: shared.config\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestUseContext(t *testing.T) {
+	path := writeContextsConfig(t)
+
+	if err := useContext(path, "sandbox"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# Applies to every context") {
+		t.Error("comments were not preserved")
+	}
+
+	cfg, err := settings.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CurrentContext != "sandbox" {
+		t.Errorf("current-context = %q, want %q", cfg.CurrentContext, "sandbox")
+	}
+
+	if err := useContext(path, "missing"); err == nil {
+		t.Error("expected error for unknown context")
+	}
+}
+
+func TestUseContext_AddsCurrentContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "sso-region: us-east-1\ncontexts:\n  prod:\n    sso-session: prod-org\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := useContext(path, "prod"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := settings.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CurrentContext != "prod" {
+		t.Errorf("current-context = %q, want %q", cfg.CurrentContext, "prod")
+	}
+}
+
+func TestUseContext_ReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "setlist.yaml")
+	if err := os.Mkdir(filepath.Dir(target), 0700); err != nil {
+		t.Fatal(err)
+	}
+	content := "contexts:\n  prod:\n    sso-session: prod-org\n"
+	if err := os.WriteFile(target, []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, ".setlist.yaml")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := useContext(link, "prod"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink was replaced: %v", err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(filepath.Dir(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+
+	cfg, err := settings.Read(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CurrentContext != "prod" {
+		t.Errorf("current-context = %q, want %q", cfg.CurrentContext, "prod")
+	}
+}
@@ -24,6 +24,8 @@
 //	permissions       List required AWS permissions
 //	check-update      Check if a newer version is available
 //	init              Generate a blank configuration file
+//	context           List, select and show named config contexts
+//	config            Validate the config file and show the effective configuration
 //
 // Example:
 //
@@ -1,18 +1,46 @@
 package main
 
+import (
+	"time"
+)
+
 var (
-	ssoSession            string // SSO session nickname
-	profile               string // AWS profile name
-	ssoRegion             string // AWS region
-	mapping               string // Mapping of account IDs to nicknames
-	filename              string // Output filename
-	stdout                bool   // Flag to print output to stdout instead of a file
-	ssoFriendlyName       string // Optional friendly name for the SSO instance
-	includeAccounts       string // Comma-delimited list of account IDs to include
-	excludeAccounts       string // Comma-delimited list of account IDs to exclude
-	includePermissionSets string // Comma-delimited list of permission set names to include
-	excludePermissionSets string // Comma-delimited list of permission set names to exclude
-	verbose               bool   // Flag to enable verbose logging
-	logFormat             string // Log format: "plain" or "json"
-	configFile            string // Path to YAML config file
+	ssoSession            string        // SSO session nickname
+	profile               string        // AWS profile name
+	ssoRegion             string        // AWS region
+	mapping               string        // Mapping of account IDs to nicknames
+	filename              string        // Output filename
+	stdout                bool          // Flag to print output to stdout instead of a file
+	ssoFriendlyName       string        // Optional friendly name for the SSO instance
+	includeAccounts       string        // Comma-delimited list of account IDs to include
+	excludeAccounts       string        // Comma-delimited list of account IDs to exclude
+	includePermissionSets string        // Comma-delimited list of permission set names to include
+	excludePermissionSets string        // Comma-delimited list of permission set names to exclude
+	filter                string        // CEL expression selecting account and permission set pairs
+	allowUnknownRegion    bool          // Accept an SSO region missing from the library's region table
+	instanceArn           string        // ARN of the SSO instance to use when several exist
+	identityStoreId       string        // Identity store ID of the SSO instance to use when several exist
+	assumeRoleArn         string        // Role to assume before calling AWS
+	externalId            string        // External ID passed when assuming the role
+	roleSessionName       string        // Session name for the assumed role
+	mfaSerial             string        // MFA device required by the assumed role
+	mfaToken              string        // MFA code; prompted for when empty
+	endpointURL           string        // Endpoint for every AWS service, e.g. LocalStack
+	orgEndpointURL        string        // Endpoint for AWS Organizations
+	ssoAdminEndpointURL   string        // Endpoint for SSO Admin
+	s3EndpointURL         string        // Endpoint for S3
+	stsEndpointURL        string        // Endpoint for STS
+	fromSSOSession        string        // sso-session in ~/.aws/config to take session, region and start URL from
+	ssoStartURL           string        // Full start URL, overriding the one built by setlist
+	verbose               bool          // Flag to enable verbose logging
+	logFormat             string        // Log format: "plain" or "json"
+	configFile            string        // Path to YAML config file
+	contextName           string        // Named context to use from the config file
+	noCache               bool          // Flag to disable the on-disk API response cache
+	refreshCache          bool          // Flag to ignore cached API responses and fetch fresh ones
+	cacheTTL              time.Duration // How long cached API responses remain valid
+	signKey               string        // Ed25519 private key to sign the generated config with
+	verifyKey             string        // Ed25519 public key to verify a config's signature with
+	signatureFile         string        // Detached signature to verify; defaults to the file plus .sig
+	syncFrom              string        // s3:// location of the config to download
 )
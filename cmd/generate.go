@@ -2,12 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
 	"log/slog"
 
 	"github.com/scottbrown/setlist"
 
-	"github.com/aws/aws-sdk-go-v2/service/organizations"
-	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
 	"github.com/spf13/cobra"
 )
 
@@ -26,10 +25,15 @@ func init() {
 	generateCmd.Flags().BoolVar(&stdout, FlagStdout, false, "Specify this flag to write the config file to stdout instead of a file")
 	generateCmd.Flags().StringVarP(&mapping, FlagMapping, "m", "", "Comma-delimited Account Nickname Mapping (id=nickname)")
 	generateCmd.Flags().StringVar(&ssoFriendlyName, FlagSSOFriendlyName, "", "Use this instead of the identity store ID for the start URL")
-	generateCmd.Flags().StringVar(&includeAccounts, FlagIncludeAccounts, "", "Comma-delimited list of account IDs to include (mutually exclusive with --exclude-accounts)")
-	generateCmd.Flags().StringVar(&excludeAccounts, FlagExcludeAccounts, "", "Comma-delimited list of account IDs to exclude (mutually exclusive with --include-accounts)")
-	generateCmd.Flags().StringVar(&includePermissionSets, FlagIncludePermissionSets, "", "Comma-delimited list of permission set names to include (mutually exclusive with --exclude-permission-sets)")
-	generateCmd.Flags().StringVar(&excludePermissionSets, FlagExcludePermissionSets, "", "Comma-delimited list of permission set names to exclude (mutually exclusive with --include-permission-sets)")
+	generateCmd.Flags().StringVar(&ssoStartURL, FlagSSOStartURL, "", "Full https:// start URL to use as-is (takes precedence over --sso-friendly-name)")
+	generateCmd.Flags().StringVar(&includeAccounts, FlagIncludeAccounts, "", "Comma-delimited account IDs, globs, re:<regex> or @file to include")
+	generateCmd.Flags().StringVar(&excludeAccounts, FlagExcludeAccounts, "", "Comma-delimited account IDs, globs, re:<regex> or @file to exclude (applied after include)")
+	generateCmd.Flags().StringVar(&includePermissionSets, FlagIncludePermissionSets, "", "Comma-delimited permission set names, globs, re:<regex> or @file to include")
+	generateCmd.Flags().StringVar(&excludePermissionSets, FlagExcludePermissionSets, "", "Comma-delimited permission set names, globs, re:<regex> or @file to exclude (applied after include)")
+	generateCmd.Flags().StringVar(&fromSSOSession, FlagFromSSOSession, "", "Take the session name, region and start URL from this [sso-session] in ~/.aws/config")
+	addInstanceFlags(generateCmd)
+	generateCmd.Flags().StringVar(&filter, FlagFilter, "", "CEL expression over account and ps attributes selecting which profiles to generate")
+	generateCmd.Flags().StringVar(&signKey, FlagSignKey, "", "PEM-encoded Ed25519 private key to write a detached signature with, next to the output as <output>"+setlist.SignatureExtension)
 
 	rootCmd.AddCommand(generateCmd)
 }
@@ -38,31 +42,55 @@ func handleGenerate(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), DEFAULT_TIMEOUT)
 	defer cancel()
 
+	selector, err := instanceSelector()
+	if err != nil {
+		return err
+	}
+
+	var key ed25519.PrivateKey
+	if signKey != "" {
+		if key, err = setlist.LoadEd25519PrivateKey(signKey); err != nil {
+			return err
+		}
+	}
+
 	slog.Info("Loading AWS configuration", "region", ssoRegion)
 	cfg, err := loadAWSConfig(ctx)
 	if err != nil {
 		return err
 	}
 
-	ssoClient := ssoadmin.NewFromConfig(cfg)
-	orgClient := organizations.NewFromConfig(cfg)
+	ssoClient, orgClient, err := newAPIClients(ctx, cfg)
+	if err != nil {
+		return err
+	}
 
 	configFile, err := setlist.Generate(ctx, setlist.GenerateInput{
 		SSOClient:             ssoClient,
 		OrgClient:             orgClient,
+		Instance:              selector,
 		SessionName:           ssoSession,
 		Region:                ssoRegion,
+		AllowUnknownRegion:    allowUnknownRegion,
 		FriendlyName:          ssoFriendlyName,
+		StartURL:              ssoStartURL,
 		NicknameMapping:       mapping,
 		IncludeAccounts:       includeAccounts,
 		ExcludeAccounts:       excludeAccounts,
 		IncludePermissionSets: includePermissionSets,
 		ExcludePermissionSets: excludePermissionSets,
+		Filter:                filter,
 	})
 	if err != nil {
-		return err
+		return instanceHint(err)
 	}
 
 	slog.Info("Writing output")
-	return outputConfig(configFile)
+	if err := outputConfig(configFile); err != nil {
+		return err
+	}
+	if key != nil {
+		return signFile(ctx, cmd.OutOrStdout(), filename, key)
+	}
+	return nil
 }
@@ -1,26 +1,45 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
 	"github.com/spf13/cobra"
 )
 
-const configTemplate = `# SetList configuration file
+const configTemplate = `# yaml-language-server: $schema=https://raw.githubusercontent.com/scottbrown/setlist/main/setlist.schema.json
+# SetList configuration file
 # See: https://github.com/scottbrown/setlist
 
+# Schema version of this file
+version: 1
+
+# Base config file to merge this file over: a path, https:// URL or
+# s3://bucket/key location
+# extends: ~/team/setlist.yaml
+
 # (Required) Nickname for the SSO session (e.g. your org name)
 sso-session: ""
 
 # (Required) AWS region where AWS SSO resides (e.g. ca-central-1)
 sso-region: ""
 
+# Accept an sso-region launched after this release of setlist
+allow-unknown-region: false
+
 # AWS profile to use for credentials
 profile: ""
 
-# Comma-delimited account nickname mapping (id=nickname)
-mapping: ""
+# Account nickname mapping, as a map or a comma-delimited string (id=nickname)
+# mapping:
+#   "123456789012": prod
+mapping: {}
 
 # Output filename (default: aws.config)
 output: ""
@@ -31,26 +50,57 @@ stdout: false
 # Use a friendly name instead of the identity store ID for the start URL
 sso-friendly-name: ""
 
+# Full https:// start URL, used as-is instead of building one. Takes
+# precedence over sso-friendly-name
+sso-start-url: ""
+
 # Enable verbose logging
 verbose: false
 
 # Log format: "plain" or "json"
 log-format: "plain"
 
-# Comma-delimited list of account IDs to include (mutually exclusive with exclude-accounts)
-include-accounts: ""
-
-# Comma-delimited list of account IDs to exclude (mutually exclusive with include-accounts)
-exclude-accounts: ""
-
-# Comma-delimited list of permission set names to include
-include-permission-sets: ""
-
-# Comma-delimited list of permission set names to exclude
-exclude-permission-sets: ""
+# Account IDs to include, as a list or a comma-delimited string. Entries may
+# be globs (1234*), regular expressions (re:^1234) or @file references with
+# one entry per line
+include-accounts: []
+
+# Account IDs to exclude, applied after include-accounts
+exclude-accounts: []
+
+# Permission set names to include, as a list or a comma-delimited string.
+# Entries may be globs (ReadOnly*), regular expressions (re:^Platform-) or
+# @file references
+include-permission-sets: []
+
+# Permission set names to exclude, applied after include-permission-sets
+exclude-permission-sets: []
+
+# CEL expression selecting which profiles to generate, e.g.
+# account.tags.env == "prod" && !ps.name.startsWith("Break")
+filter: ""
+
+# Disable the on-disk cache of AWS API responses
+no-cache: false
+
+# How long cached AWS API responses remain valid (e.g. 15m, 1h)
+cache-ttl: 15m
+
+# Named contexts, each overriding any of the settings above. Select one with
+# --context, the SETLIST_CONTEXT environment variable or current-context.
+# current-context: prod
+# contexts:
+#   prod:
+#     sso-session: prod-org
+#   sandbox:
+#     sso-session: sandbox-org
+#     sso-region: ca-central-1
 `
 
-var forceOverwrite bool
+var (
+	forceOverwrite bool
+	interactive    bool
+)
 
 var initCmd = &cobra.Command{
 	Use:   "init",
@@ -61,17 +111,14 @@ var initCmd = &cobra.Command{
 
 func init() {
 	initCmd.Flags().BoolVar(&forceOverwrite, "force", false, "Overwrite existing configuration file")
+	initCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Prompt for each setting, suggesting values from ~/.aws/config")
 	rootCmd.AddCommand(initCmd)
 }
 
 func handleInit(cmd *cobra.Command, args []string) error {
-	path := configFile
-	if path == "" {
-		defaultPath, err := defaultConfigPath()
-		if err != nil {
-			return err
-		}
-		path = defaultPath
+	path, _, err := configFilePath()
+	if err != nil {
+		return err
 	}
 
 	if !forceOverwrite {
@@ -80,10 +127,68 @@ func handleInit(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if err := os.WriteFile(path, []byte(configTemplate), 0644); err != nil { //#nosec: G306
+	content := configTemplate
+	if interactive {
+		ctx, cancel := context.WithTimeout(context.Background(), DEFAULT_TIMEOUT)
+		defer cancel()
+
+		answers, err := runInitWizard(ctx, newPrompter(cmd.InOrStdin(), cmd.OutOrStdout()), loadSharedConfigForInit(), discoverAccounts)
+		if err != nil {
+			return err
+		}
+		content = renderInitConfig(answers)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil { //#nosec: G306
 		return fmt.Errorf("unable to write configuration file %s: %w", path, err)
 	}
 
 	fmt.Fprintf(cmd.OutOrStdout(), "Wrote configuration file to %s\n", path)
 	return nil
 }
+
+// loadSharedConfigForInit reads ~/.aws/config (or $AWS_CONFIG_FILE) for the
+// wizard's suggestions. A missing or unreadable file just means there is
+// nothing to suggest.
+func loadSharedConfigForInit() *setlist.SharedConfig {
+	path, err := setlist.DefaultSharedConfigPath()
+	if err != nil {
+		return &setlist.SharedConfig{}
+	}
+
+	shared, err := setlist.LoadSharedConfig(path)
+	if err != nil {
+		slog.Info("No AWS config to suggest values from", "path", path, "error", err)
+		return &setlist.SharedConfig{}
+	}
+	return shared
+}
+
+// discoverAccounts looks up the SSO instance and the organization's
+// accounts using the region and profile chosen in the wizard.
+func discoverAccounts(ctx context.Context, region, awsProfile string) (string, []orgtypes.Account, error) {
+	ssoRegion = region
+	profile = awsProfile
+
+	cfg, err := loadAWSConfig(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ssoClient, orgClient, err := newAPIClients(ctx, cfg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	instance, err := setlist.SsoInstance(ctx, ssoClient)
+	if err != nil {
+		return "", nil, err
+	}
+
+	accounts, err := setlist.ListAccounts(ctx, orgClient)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return aws.ToString(instance.IdentityStoreId), accounts, nil
+}
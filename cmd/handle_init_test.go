@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
@@ -176,3 +177,39 @@ func TestHandleInit_TemplateIsValidYAML(t *testing.T) {
 		t.Fatalf("generated template is not valid config: %v", err)
 	}
 }
+
+func TestHandleInit_Interactive(t *testing.T) {
+	resetGlobals()
+
+	dir := t.TempDir()
+	awsConfig := filepath.Join(dir, "aws-config")
+	content := "[sso-session corp]\nsso_start_url = https://corp.awsapps.com/start\nsso_region = ca-central-1\n"
+	if err := os.WriteFile(awsConfig, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", awsConfig)
+
+	path := filepath.Join(dir, ".setlist.yaml")
+	configFile = path
+	interactive = true
+	defer func() { interactive = false }()
+
+	cmd := initCmd
+	var out bytes.Buffer
+	cmd.SetIn(strings.NewReader("\n\n\n\nn\n\n"))
+	cmd.SetOut(&out)
+	defer cmd.SetIn(nil)
+	defer cmd.SetOut(nil)
+
+	if err := handleInit(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `sso-session: "corp"`) || !strings.Contains(string(data), `sso-region: "ca-central-1"`) {
+		t.Errorf("expected discovered values in config, got:\n%s", data)
+	}
+}
@@ -16,6 +16,7 @@ func TestValidateRequiredFlags(t *testing.T) {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
 	defer os.RemoveAll(tempDir)
+	defer func() { signKey = "" }()
 
 	// Create a valid writable file path
 	validFilePath := filepath.Join(tempDir, "config.txt")
@@ -34,6 +35,7 @@ func TestValidateRequiredFlags(t *testing.T) {
 		ssoRegion   string
 		stdout      bool
 		filename    string
+		signKey     string
 		wantErr     bool
 		errContains string
 	}{
@@ -91,6 +93,16 @@ func TestValidateRequiredFlags(t *testing.T) {
 			wantErr:     true,
 			errContains: "output directory does not exist",
 		},
+		{
+			name:        "sign_key_with_stdout",
+			ssoSession:  "mysession",
+			ssoRegion:   "us-east-1",
+			stdout:      true,
+			filename:    "unused",
+			signKey:     "signing.pem",
+			wantErr:     true,
+			errContains: "--sign-key needs an output file",
+		},
 	}
 
 	for _, tt := range tests {
@@ -100,6 +112,7 @@ func TestValidateRequiredFlags(t *testing.T) {
 			ssoRegion = tt.ssoRegion
 			stdout = tt.stdout
 			filename = tt.filename
+			signKey = tt.signKey
 
 			err := validateRequiredFlags(cmd)
 
@@ -121,10 +134,11 @@ func TestValidateRequiredFlags(t *testing.T) {
 
 func TestValidateRegionOnly(t *testing.T) {
 	tests := []struct {
-		name        string
-		ssoRegion   string
-		wantErr     bool
-		errContains string
+		name         string
+		ssoRegion    string
+		allowUnknown bool
+		wantErr      bool
+		errContains  string
 	}{
 		{
 			name:      "valid region",
@@ -148,11 +162,35 @@ func TestValidateRegionOnly(t *testing.T) {
 			ssoRegion: "ca-central-1",
 			wantErr:   false,
 		},
+		{
+			name:      "israel region",
+			ssoRegion: "il-central-1",
+			wantErr:   false,
+		},
+		{
+			name:      "govcloud region",
+			ssoRegion: "us-gov-west-1",
+			wantErr:   false,
+		},
+		{
+			name:        "unknown region",
+			ssoRegion:   "xx-central-1",
+			wantErr:     true,
+			errContains: "--allow-unknown-region",
+		},
+		{
+			name:         "unknown region allowed",
+			ssoRegion:    "xx-central-1",
+			allowUnknown: true,
+			wantErr:      false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ssoRegion = tt.ssoRegion
+			allowUnknownRegion = tt.allowUnknown
+			defer func() { allowUnknownRegion = false }()
 
 			err := validateRegionOnly()
 
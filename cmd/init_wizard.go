@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+// accountDiscoverer looks up the SSO instance's identity store ID and the
+// organization's accounts so the wizard can offer a nickname mapping.
+type accountDiscoverer func(ctx context.Context, region, profile string) (string, []orgtypes.Account, error)
+
+// initAnswers are the values collected by the init wizard.
+type initAnswers struct {
+	SSOSession      string
+	SSORegion       string
+	AllowUnknown    bool
+	Profile         string
+	SSOFriendlyName string
+	SSOStartURL     string
+	Mapping         map[string]string
+	Output          string
+}
+
+// awsappsStartURL matches the host of the start URL setlist builds outside
+// GovCloud, https://<subdomain>.awsapps.com/start or .awsapps.cn/start.
+var awsappsStartURL = regexp.MustCompile(`^([a-z0-9-]+)\.awsapps\.(?:com|cn)$`)
+
+// govCloudStartURLHost is the host of every GovCloud start URL, which
+// carries the name in its path instead:
+// https://start.us-gov-home.awsapps.com/directory/<name>.
+const govCloudStartURLHost = "start.us-gov-home.awsapps.com"
+
+// nicknameUnsafe matches the characters dropped when suggesting a nickname
+// from an account name.
+var nicknameUnsafe = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// runInitWizard asks for each setting in turn, suggesting values found in
+// the shared AWS config file. When discover is non-nil the user may also
+// build a nickname mapping from the organization's account names.
+func runInitWizard(ctx context.Context, p *prompter, shared *setlist.SharedConfig, discover accountDiscoverer) (initAnswers, error) {
+	answers := initAnswers{Mapping: map[string]string{}}
+
+	var suggested setlist.SSOSessionConfig
+	if len(shared.Sessions) > 0 {
+		fmt.Fprintln(p.out, "Found SSO sessions in your AWS config:")
+		for _, s := range shared.Sessions {
+			fmt.Fprintf(p.out, "  %s (%s, %s)\n", s.Name, s.StartURL, s.Region)
+		}
+		suggested = shared.Sessions[0]
+	} else {
+		// Profiles configured before sso-session sections existed carry
+		// the start URL and region themselves.
+		for _, prof := range shared.Profiles {
+			if prof.SSOStartURL != "" {
+				suggested = setlist.SSOSessionConfig{StartURL: prof.SSOStartURL, Region: prof.SSORegion}
+				break
+			}
+		}
+	}
+
+	var err error
+	if answers.SSOSession, err = p.ask("SSO session name", suggested.Name); err != nil {
+		return answers, err
+	}
+	if s, ok := shared.Session(answers.SSOSession); ok {
+		suggested = s
+	}
+
+	for {
+		if answers.SSORegion, err = p.ask("SSO region", suggested.Region); err != nil {
+			return answers, err
+		}
+		if _, err := setlist.NewRegionAllowUnknown(answers.SSORegion); err != nil {
+			fmt.Fprintf(p.out, "%q is not a valid region.\n", answers.SSORegion)
+			continue
+		}
+		if _, err := setlist.NewRegion(answers.SSORegion); errors.Is(err, setlist.ErrUnknownRegion) {
+			fmt.Fprintf(p.out, "%q is not a region this release knows about; setting %s.\n", answers.SSORegion, FlagAllowUnknownRegion)
+			answers.AllowUnknown = true
+		}
+		break
+	}
+
+	if answers.Profile, err = p.ask("AWS profile for credentials (blank for the default chain)", ""); err != nil {
+		return answers, err
+	}
+
+	friendly, custom := friendlyNameFromStartURL(suggested.StartURL)
+	if custom {
+		// A friendly name can't reproduce a custom URL, so keep it as-is.
+		fmt.Fprintf(p.out, "Start URL %s is a custom URL; it will be written as %s.\n", suggested.StartURL, FlagSSOStartURL)
+		answers.SSOStartURL = suggested.StartURL
+	} else if answers.SSOFriendlyName, err = p.ask("SSO friendly name (blank to use the identity store ID)", friendly); err != nil {
+		return answers, err
+	}
+
+	if discover != nil {
+		lookup, err := p.confirm("Look up accounts in AWS to build a nickname mapping?", false)
+		if err != nil {
+			return answers, err
+		}
+		if lookup {
+			if err := askNicknames(ctx, p, discover, &answers); err != nil {
+				return answers, err
+			}
+		}
+	}
+
+	if answers.Output, err = p.ask("Output filename", DEFAULT_FILENAME); err != nil {
+		return answers, err
+	}
+
+	return answers, nil
+}
+
+// askNicknames offers a nickname for every account, prefilled from the
+// account name. Lookup failures are reported and skipped so the rest of
+// the wizard can still complete.
+func askNicknames(ctx context.Context, p *prompter, discover accountDiscoverer, answers *initAnswers) error {
+	identityStoreId, accounts, err := discover(ctx, answers.SSORegion, answers.Profile)
+	if err != nil {
+		fmt.Fprintf(p.out, "Unable to look up accounts, skipping the nickname mapping: %v\n", err)
+		return nil
+	}
+
+	fmt.Fprintf(p.out, "Found identity store %s with %d accounts. Enter a nickname for each, or '-' to skip.\n", identityStoreId, len(accounts))
+
+	for _, a := range accounts {
+		id, name := aws.ToString(a.Id), aws.ToString(a.Name)
+		for {
+			nickname, err := p.ask(fmt.Sprintf("Nickname for %s (%s)", id, name), suggestNickname(name))
+			if err != nil {
+				return err
+			}
+			if nickname == "-" || nickname == "" {
+				break
+			}
+			if strings.ContainsAny(nickname, ",= \t") {
+				fmt.Fprintln(p.out, "Nicknames must not contain commas, equals signs or spaces.")
+				continue
+			}
+			answers.Mapping[id] = nickname
+			break
+		}
+	}
+	return nil
+}
+
+// friendlyNameFromStartURL returns the friendly name in a start URL setlist
+// can build itself: https://<name>.awsapps.com/start, its awsapps.cn
+// equivalent, or the GovCloud /directory/<name> form. Identity store IDs
+// (d-...) yield no friendly name. custom reports a URL in any other form.
+func friendlyNameFromStartURL(startURL string) (name string, custom bool) {
+	if startURL == "" {
+		return "", false
+	}
+
+	u, err := url.Parse(startURL)
+	if err != nil {
+		return "", true
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == govCloudStartURLHost {
+		dir, ok := strings.CutPrefix(strings.TrimSuffix(u.Path, "/"), "/directory/")
+		if !ok || dir == "" || strings.Contains(dir, "/") {
+			return "", true
+		}
+		name = strings.ToLower(dir)
+	} else {
+		m := awsappsStartURL.FindStringSubmatch(host)
+		if m == nil {
+			return "", true
+		}
+		name = m[1]
+	}
+
+	if strings.HasPrefix(name, "d-") {
+		return "", false
+	}
+	return name, false
+}
+
+// suggestNickname turns an account name into a lower-case, hyphenated
+// nickname, e.g. "Shared Services" becomes "shared-services".
+func suggestNickname(name string) string {
+	s := strings.ToLower(strings.TrimSpace(name))
+	s = nicknameUnsafe.ReplaceAllString(s, "-")
+	return strings.Trim(s, "-")
+}
+
+// renderInitConfig fills the answers into configTemplate, keeping its
+// comments so the file stays self-documenting.
+func renderInitConfig(answers initAnswers) string {
+	out := configTemplate
+	out = setTemplateKey(out, FlagSSOSession, strconv.Quote(answers.SSOSession))
+	out = setTemplateKey(out, FlagSSORegion, strconv.Quote(answers.SSORegion))
+	out = setTemplateKey(out, FlagAllowUnknownRegion, strconv.FormatBool(answers.AllowUnknown))
+	out = setTemplateKey(out, FlagProfile, strconv.Quote(answers.Profile))
+	out = setTemplateKey(out, FlagSSOFriendlyName, strconv.Quote(answers.SSOFriendlyName))
+	out = setTemplateKey(out, FlagSSOStartURL, strconv.Quote(answers.SSOStartURL))
+	out = setTemplateKey(out, FlagOutput, strconv.Quote(answers.Output))
+
+	if len(answers.Mapping) > 0 {
+		ids := make([]string, 0, len(answers.Mapping))
+		for id := range answers.Mapping {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		var b strings.Builder
+		for _, id := range ids {
+			fmt.Fprintf(&b, "\n  %s: %s", strconv.Quote(id), strconv.Quote(answers.Mapping[id]))
+		}
+		out = setTemplateKey(out, FlagMapping, b.String())
+	}
+
+	return out
+}
+
+// setTemplateKey replaces the value of a top-level key in the template. A
+// value starting with a newline is written as a block.
+func setTemplateKey(tmpl, key, value string) string {
+	sep := " "
+	if strings.HasPrefix(value, "\n") {
+		sep = ""
+	}
+	re := regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(key) + `: .*$`)
+	return re.ReplaceAllLiteralString(tmpl, key+":"+sep+value)
+}
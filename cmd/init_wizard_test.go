@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+func testSharedConfig() *setlist.SharedConfig {
+	return &setlist.SharedConfig{
+		Sessions: []setlist.SSOSessionConfig{
+			{Name: "corp", StartURL: "https://corp.awsapps.com/start", Region: "ca-central-1"},
+			{Name: "lab", StartURL: "https://d-1234567890.awsapps.com/start", Region: "us-west-2"},
+		},
+	}
+}
+
+func stubDiscoverer(accounts ...orgtypes.Account) accountDiscoverer {
+	return func(ctx context.Context, region, profile string) (string, []orgtypes.Account, error) {
+		return "d-1234567890", accounts, nil
+	}
+}
+
+func TestRunInitWizard_AcceptSuggestions(t *testing.T) {
+	// Blank answers accept every suggestion; "y" accepts the lookup and
+	// the last account is skipped with "-".
+	input := "\n\n\n\ny\n\nsandbox\n-\n\n"
+	var out bytes.Buffer
+
+	answers, err := runInitWizard(context.Background(), newPrompter(strings.NewReader(input), &out), testSharedConfig(), stubDiscoverer(
+		orgtypes.Account{Id: aws.String("111111111111"), Name: aws.String("Shared Services")},
+		orgtypes.Account{Id: aws.String("222222222222"), Name: aws.String("Dev Sandbox")},
+		orgtypes.Account{Id: aws.String("333333333333"), Name: aws.String("Legacy")},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if answers.SSOSession != "corp" || answers.SSORegion != "ca-central-1" || answers.SSOFriendlyName != "corp" {
+		t.Errorf("answers = %+v, want values from sso-session corp", answers)
+	}
+	if answers.Output != DEFAULT_FILENAME {
+		t.Errorf("Output = %q, want %q", answers.Output, DEFAULT_FILENAME)
+	}
+
+	want := map[string]string{"111111111111": "shared-services", "222222222222": "sandbox"}
+	if len(answers.Mapping) != len(want) {
+		t.Fatalf("Mapping = %v, want %v", answers.Mapping, want)
+	}
+	for id, nick := range want {
+		if answers.Mapping[id] != nick {
+			t.Errorf("Mapping[%s] = %q, want %q", id, answers.Mapping[id], nick)
+		}
+	}
+
+	if !strings.Contains(out.String(), "corp (https://corp.awsapps.com/start, ca-central-1)") {
+		t.Errorf("expected discovered sessions to be listed, got %q", out.String())
+	}
+}
+
+func TestRunInitWizard_OtherSession(t *testing.T) {
+	// Choosing "lab" switches the suggested region, and its d- start URL
+	// needs no friendly name. An invalid region is asked again.
+	input := "lab\nmars-1\n\nadmin\n\nn\nout.config\n"
+	var out bytes.Buffer
+
+	answers, err := runInitWizard(context.Background(), newPrompter(strings.NewReader(input), &out), testSharedConfig(), stubDiscoverer())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if answers.SSORegion != "us-west-2" || answers.SSOFriendlyName != "" || answers.Profile != "admin" || answers.Output != "out.config" {
+		t.Errorf("answers = %+v", answers)
+	}
+	if len(answers.Mapping) != 0 {
+		t.Errorf("Mapping = %v, want none", answers.Mapping)
+	}
+	if !strings.Contains(out.String(), `"mars-1" is not a valid region`) {
+		t.Error("expected invalid region to be reported")
+	}
+}
+
+func TestRunInitWizard_CustomStartURL(t *testing.T) {
+	shared := &setlist.SharedConfig{Sessions: []setlist.SSOSessionConfig{
+		{Name: "corp", StartURL: "https://sso.corp.example.com/start", Region: "eu-west-1"},
+	}}
+	// No friendly name is asked for: session, region, profile, output.
+	input := "\n\n\n\n"
+	var out bytes.Buffer
+
+	answers, err := runInitWizard(context.Background(), newPrompter(strings.NewReader(input), &out), shared, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if answers.SSOStartURL != "https://sso.corp.example.com/start" || answers.SSOFriendlyName != "" {
+		t.Errorf("answers = %+v", answers)
+	}
+
+	cfg, err := settings.Parse([]byte(renderInitConfig(answers)))
+	if err != nil {
+		t.Fatalf("rendered config does not parse: %v", err)
+	}
+	if cfg.SSOStartURL != "https://sso.corp.example.com/start" {
+		t.Errorf("SSOStartURL = %q", cfg.SSOStartURL)
+	}
+}
+
+func TestRunInitWizard_UnknownRegion(t *testing.T) {
+	input := "myorg\nxx-central-1\n\n\n\n"
+	var out bytes.Buffer
+
+	answers, err := runInitWizard(context.Background(), newPrompter(strings.NewReader(input), &out), &setlist.SharedConfig{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if answers.SSORegion != "xx-central-1" || !answers.AllowUnknown {
+		t.Errorf("answers = %+v", answers)
+	}
+
+	content := renderInitConfig(answers)
+	if problems := settings.Validate([]byte(content)); len(problems) != 0 {
+		t.Errorf("rendered config has problems: %v", problems)
+	}
+}
+
+func TestRunInitWizard_LookupFailure(t *testing.T) {
+	discover := func(ctx context.Context, region, profile string) (string, []orgtypes.Account, error) {
+		return "", nil, errors.New("no credentials")
+	}
+	input := "myorg\nus-east-1\n\n\ny\n\n"
+	var out bytes.Buffer
+
+	answers, err := runInitWizard(context.Background(), newPrompter(strings.NewReader(input), &out), &setlist.SharedConfig{}, discover)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if answers.SSOSession != "myorg" || len(answers.Mapping) != 0 {
+		t.Errorf("answers = %+v", answers)
+	}
+	if !strings.Contains(out.String(), "no credentials") {
+		t.Error("expected lookup failure to be reported")
+	}
+}
+
+func TestRunInitWizard_InputEnds(t *testing.T) {
+	_, err := runInitWizard(context.Background(), newPrompter(strings.NewReader("corp\n"), &bytes.Buffer{}), testSharedConfig(), nil)
+	if !errors.Is(err, errInputClosed) {
+		t.Errorf("error = %v, want errInputClosed", err)
+	}
+}
+
+func TestRenderInitConfig(t *testing.T) {
+	content := renderInitConfig(initAnswers{
+		SSOSession:      "corp",
+		SSORegion:       "ca-central-1",
+		SSOFriendlyName: "corp",
+		Mapping:         map[string]string{"222222222222": "dev", "111111111111": "prod"},
+		Output:          "aws.config",
+	})
+
+	cfg, err := settings.Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("rendered config does not parse: %v", err)
+	}
+	if cfg.SSOSession != "corp" || cfg.SSORegion != "ca-central-1" || cfg.SSOFriendlyName != "corp" {
+		t.Errorf("config = %+v", cfg.Values)
+	}
+	if cfg.Mapping != "111111111111=prod,222222222222=dev" {
+		t.Errorf("Mapping = %q", cfg.Mapping)
+	}
+	if problems := settings.Validate([]byte(content)); len(problems) != 0 {
+		t.Errorf("rendered config has problems: %v", problems)
+	}
+	if !strings.Contains(content, "# (Required) Nickname for the SSO session") {
+		t.Error("expected template comments to be kept")
+	}
+}
+
+func TestFriendlyNameFromStartURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		wantName   string
+		wantCustom bool
+	}{
+		{url: "https://corp.awsapps.com/start", wantName: "corp"},
+		{url: "https://d-1234567890.awsapps.com/start"},
+		{url: "https://corp.awsapps.cn/start", wantName: "corp"},
+		{url: "https://start.us-gov-home.awsapps.com/directory/corp", wantName: "corp"},
+		{url: "https://start.us-gov-home.awsapps.com/directory/d-1234567890"},
+		{url: "https://start.us-gov-home.awsapps.com/start", wantCustom: true},
+		{url: "https://sso.example.com/start", wantCustom: true},
+		{url: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			name, custom := friendlyNameFromStartURL(tt.url)
+			if name != tt.wantName || custom != tt.wantCustom {
+				t.Errorf("friendlyNameFromStartURL(%q) = %q, %v, want %q, %v", tt.url, name, custom, tt.wantName, tt.wantCustom)
+			}
+		})
+	}
+}
+
+func TestSuggestNickname(t *testing.T) {
+	tests := map[string]string{
+		"Shared Services": "shared-services",
+		"Prod (EU)":       "prod-eu",
+		"  log_archive ":  "log-archive",
+	}
+	for name, want := range tests {
+		if got := suggestNickname(name); got != want {
+			t.Errorf("suggestNickname(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
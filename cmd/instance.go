@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
+	"github.com/spf13/cobra"
+)
+
+// addInstanceFlags registers the flags that choose between several SSO
+// instances on cmd.
+func addInstanceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&instanceArn, FlagInstanceArn, "", "ARN of the SSO instance to use when several exist")
+	cmd.Flags().StringVar(&identityStoreId, FlagIdentityStoreId, "", "Identity store ID of the SSO instance to use when several exist")
+}
+
+// instanceSelector builds the SSO instance selector from --instance-arn
+// and --identity-store-id, which are mutually exclusive.
+func instanceSelector() (setlist.InstanceSelector, error) {
+	if instanceArn != "" && identityStoreId != "" {
+		return setlist.InstanceSelector{}, fmt.Errorf("--%s and --%s cannot be used together", FlagInstanceArn, FlagIdentityStoreId)
+	}
+
+	if instanceArn != "" && !arn.IsARN(instanceArn) {
+		return setlist.InstanceSelector{}, fmt.Errorf("invalid --%s %q: not an ARN", FlagInstanceArn, instanceArn)
+	}
+
+	if identityStoreId != "" {
+		if _, err := setlist.NewIdentityStoreId(identityStoreId); err != nil {
+			return setlist.InstanceSelector{}, fmt.Errorf("invalid --%s %q: %w", FlagIdentityStoreId, identityStoreId, err)
+		}
+	}
+
+	return setlist.InstanceSelector{InstanceArn: instanceArn, IdentityStoreId: identityStoreId}, nil
+}
+
+// selectInstance retrieves the SSO instance chosen by the selector flags.
+func selectInstance(ctx context.Context, ssoClient setlist.SSOAdminClient) (ssotypes.InstanceMetadata, error) {
+	selector, err := instanceSelector()
+	if err != nil {
+		return ssotypes.InstanceMetadata{}, err
+	}
+
+	instance, err := setlist.SelectSsoInstance(ctx, ssoClient, selector)
+	if err != nil {
+		return ssotypes.InstanceMetadata{}, instanceHint(fmt.Errorf("failed to retrieve SSO instance: %w", err))
+	}
+	return instance, nil
+}
+
+// instanceHint points at the selector flags when err is caused by several
+// SSO instances being visible.
+func instanceHint(err error) error {
+	if errors.Is(err, setlist.ErrMultipleInstances) {
+		return fmt.Errorf("%w; choose one with --%s or --%s", err, FlagInstanceArn, FlagIdentityStoreId)
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
+)
+
+func TestInstanceSelector(t *testing.T) {
+	t.Cleanup(resetGlobals)
+	tests := []struct {
+		name            string
+		instanceArn     string
+		identityStoreId string
+		errContains     string
+	}{
+		{name: "none"},
+		{name: "instance arn", instanceArn: "arn:aws:sso:::instance/ssoins-1"},
+		{name: "identity store id", identityStoreId: "d-1234567890"},
+		{
+			name:            "both",
+			instanceArn:     "arn:aws:sso:::instance/ssoins-1",
+			identityStoreId: "d-1234567890",
+			errContains:     "cannot be used together",
+		},
+		{name: "bad arn", instanceArn: "ssoins-1", errContains: "not an ARN"},
+		{name: "bad identity store id", identityStoreId: "1234567890", errContains: "invalid --identity-store-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetGlobals()
+			instanceArn = tt.instanceArn
+			identityStoreId = tt.identityStoreId
+
+			selector, err := instanceSelector()
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %v, should contain %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if selector.InstanceArn != tt.instanceArn || selector.IdentityStoreId != tt.identityStoreId {
+				t.Errorf("selector = %+v", selector)
+			}
+		})
+	}
+}
+
+func TestSelectInstance(t *testing.T) {
+	t.Cleanup(resetGlobals)
+	client := &mockSSOAdminClient{
+		ListInstancesFunc: func(ctx context.Context, params *ssoadmin.ListInstancesInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListInstancesOutput, error) {
+			return &ssoadmin.ListInstancesOutput{
+				Instances: []ssotypes.InstanceMetadata{
+					{InstanceArn: aws.String("arn:aws:sso:::instance/ssoins-org"), IdentityStoreId: aws.String("d-1111111111")},
+					{InstanceArn: aws.String("arn:aws:sso:::instance/ssoins-member"), IdentityStoreId: aws.String("d-2222222222")},
+				},
+			}, nil
+		},
+	}
+
+	t.Run("ambiguous", func(t *testing.T) {
+		resetGlobals()
+		_, err := selectInstance(context.Background(), client)
+		if err == nil || !strings.Contains(err.Error(), "--instance-arn or --identity-store-id") {
+			t.Errorf("error = %v, want a hint naming the selector flags", err)
+		}
+	})
+
+	t.Run("selected", func(t *testing.T) {
+		resetGlobals()
+		identityStoreId = "d-2222222222"
+		instance, err := selectInstance(context.Background(), client)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if aws.ToString(instance.InstanceArn) != "arn:aws:sso:::instance/ssoins-member" {
+			t.Errorf("InstanceArn = %q", aws.ToString(instance.InstanceArn))
+		}
+	})
+}
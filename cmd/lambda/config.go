@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scottbrown/setlist/settings"
+)
+
+// Environment variables locating a .setlist.yaml document to read settings
+// from. They are looked up directly rather than resolved, since the
+// document is itself a source of settings. envConfigTTL is how long a read
+// document is used before it is read again.
+const (
+	envConfigS3URI        = "CONFIG_S3_URI"
+	envConfigSSMParameter = "CONFIG_SSM_PARAMETER"
+	envConfigTTL          = "CONFIG_TTL"
+)
+
+// keyContext selects a context in the config document, as the CLI's
+// --context does.
+const keyContext = "context"
+
+// configLocation returns where the config document lives, as a location
+// settings.Loader understands, or "" when none is configured.
+func configLocation(lookup func(string) (string, bool)) (string, error) {
+	s3URI, _ := lookup(envConfigS3URI)
+	parameter, _ := lookup(envConfigSSMParameter)
+
+	switch {
+	case s3URI != "" && parameter != "":
+		return "", fmt.Errorf("%s and %s cannot be used together", envConfigS3URI, envConfigSSMParameter)
+	case s3URI != "":
+		if !strings.HasPrefix(s3URI, "s3://") {
+			return "", fmt.Errorf("invalid %s %q: must be an s3:// URI", envConfigS3URI, s3URI)
+		}
+		return s3URI, nil
+	case parameter != "":
+		if strings.HasPrefix(parameter, "ssm:") {
+			return parameter, nil
+		}
+		return "ssm:" + parameter, nil
+	}
+	return "", nil
+}
+
+// configTTL returns how long the config document is used before it is read
+// again, or 0 when it is read once per execution environment.
+func configTTL(lookup func(string) (string, bool)) (time.Duration, error) {
+	v, _ := lookup(envConfigTTL)
+	if v == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative duration", envConfigTTL, v)
+	}
+	return ttl, nil
+}
+
+// documentCache holds the settings layers of the config document. It lives
+// as long as the execution environment, so the document is read when the
+// environment starts rather than on every invocation.
+type documentCache struct {
+	mu     sync.Mutex
+	layers []settings.Layer
+	loaded time.Time
+	now    func() time.Time
+}
+
+// documents is the config document this execution environment read.
+var documents = &documentCache{now: time.Now}
+
+// get returns the cached layers, reading them first when they are missing,
+// or older than a non-zero ttl. Stale layers are still used if reading them
+// again fails, so the document's store being unavailable doesn't fail
+// every invocation. Concurrent callers wait for a single read.
+func (c *documentCache) get(ctx context.Context, ttl time.Duration, load func(context.Context) ([]settings.Layer, error)) ([]settings.Layer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loaded.IsZero() && (ttl == 0 || c.now().Sub(c.loaded) < ttl) {
+		return c.layers, nil
+	}
+
+	layers, err := load(ctx)
+	if err != nil {
+		if c.loaded.IsZero() {
+			return nil, err
+		}
+		slog.Warn("Failed to reread config file, using the cached one", "error", err.Error(), "loaded", c.loaded)
+		return c.layers, nil
+	}
+	c.layers = layers
+	c.loaded = c.now()
+	return layers, nil
+}
+
+// configLayers reads the config document at location, following extends:
+// as the CLI does, and returns its settings layers for the context named by
+// SETLIST_CONTEXT, or the document's current-context.
+func configLayers(ctx context.Context, loader *settings.Loader, location string, lookup func(string) (string, bool)) ([]settings.Layer, error) {
+	file, err := loader.Load(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := lookup(settings.EnvName(keyContext))
+	layers, err := file.Layers(name)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", location, err)
+	}
+
+	for i := range layers {
+		layers[i].Source += " " + location
+	}
+	return layers, nil
+}
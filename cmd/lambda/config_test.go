@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+type stubSSMClient struct {
+	parameters map[string]string
+}
+
+func (c *stubSSMClient) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	value, ok := c.parameters[aws.ToString(params.Name)]
+	if !ok {
+		return nil, errors.New("ParameterNotFound")
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Value: aws.String(value)}}, nil
+}
+
+func TestConfigLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "s3", env: map[string]string{envConfigS3URI: "s3://bucket/setlist.yaml"}, want: "s3://bucket/setlist.yaml"},
+		{name: "ssm path", env: map[string]string{envConfigSSMParameter: "/setlist/config"}, want: "ssm:/setlist/config"},
+		{name: "ssm name", env: map[string]string{envConfigSSMParameter: "setlist-config"}, want: "ssm:setlist-config"},
+		{name: "ssm location", env: map[string]string{envConfigSSMParameter: "ssm:/setlist/config"}, want: "ssm:/setlist/config"},
+		{name: "not s3", env: map[string]string{envConfigS3URI: "https://example.com/setlist.yaml"}, wantErr: "must be an s3:// URI"},
+		{
+			name:    "both",
+			env:     map[string]string{envConfigS3URI: "s3://bucket/setlist.yaml", envConfigSSMParameter: "/setlist/config"},
+			wantErr: "cannot be used together",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := configLocation(lookupFrom(tc.env))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("configLocation() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfigTTL(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "5m", want: 5 * time.Minute},
+		{value: "0s", want: 0},
+		{value: "-1m", wantErr: true},
+		{value: "often", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := configTTL(lookupFrom(map[string]string{envConfigTTL: tc.value}))
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("configTTL(%q) = %v, %v", tc.value, got, err)
+		}
+	}
+}
+
+func TestDocumentCache_Get(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var reads int
+	var failWith error
+	load := func(context.Context) ([]settings.Layer, error) {
+		if failWith != nil {
+			return nil, failWith
+		}
+		reads++
+		return []settings.Layer{{Source: "read " + strconv.Itoa(reads)}}, nil
+	}
+
+	t.Run("once per environment", func(t *testing.T) {
+		reads, failWith = 0, nil
+		cache := &documentCache{now: func() time.Time { return now }}
+		for range 3 {
+			if layers, err := cache.get(context.Background(), 0, load); err != nil || layers[0].Source != "read 1" {
+				t.Fatalf("get = %v, %v", layers, err)
+			}
+			now = now.Add(24 * time.Hour)
+		}
+		if reads != 1 {
+			t.Errorf("document read %d times, want once", reads)
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		reads, failWith = 0, nil
+		cache := &documentCache{now: func() time.Time { return now }}
+		get := func() ([]settings.Layer, error) {
+			return cache.get(context.Background(), 5*time.Minute, load)
+		}
+
+		failWith = errors.New("access denied")
+		if _, err := get(); err == nil {
+			t.Fatal("expected the first failure to be returned")
+		}
+
+		failWith = nil
+		if layers, err := get(); err != nil || layers[0].Source != "read 1" {
+			t.Fatalf("first get = %v, %v", layers, err)
+		}
+
+		now = now.Add(time.Minute)
+		if layers, _ := get(); layers[0].Source != "read 1" || reads != 1 {
+			t.Errorf("fresh get = %v after %d reads, want the cached document", layers, reads)
+		}
+
+		now = now.Add(5 * time.Minute)
+		if layers, _ := get(); layers[0].Source != "read 2" {
+			t.Errorf("stale get = %v, want the document read again", layers)
+		}
+
+		now = now.Add(time.Hour)
+		failWith = errors.New("throttled")
+		if layers, err := get(); err != nil || layers[0].Source != "read 2" {
+			t.Errorf("failed read = %v, %v; want the stale document", layers, err)
+		}
+	})
+}
+
+const configDocument = `sso-session: corp
+sso-region: us-east-1
+mapping:
+  "111111111111": prod
+  "222222222222": dev
+current-context: all
+contexts:
+  all: {}
+  readonly:
+    include-permission-sets: [ReadOnly]
+`
+
+func TestConfigLayers(t *testing.T) {
+	loader := &settings.Loader{SSMClient: &stubSSMClient{parameters: map[string]string{"/setlist/config": configDocument}}}
+
+	t.Run("current context", func(t *testing.T) {
+		layers, err := configLayers(context.Background(), loader, "ssm:/setlist/config", lookupFrom(nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		resolved := loadSettings(Event{}, lookupFrom(map[string]string{"SSO_REGION": "ca-central-1"}), layers...)
+		if got := resolved["mapping"]; got.Value != "111111111111=prod,222222222222=dev" || got.Source != "config file ssm:/setlist/config" {
+			t.Errorf("mapping = %+v", got)
+		}
+		if got := resolved["sso-region"]; got.Value != "ca-central-1" || got.Source != "env SSO_REGION" {
+			t.Errorf("sso-region = %+v, want the environment to win", got)
+		}
+		if got := resolved.Get("include-permission-sets"); got != "" {
+			t.Errorf("include-permission-sets = %q, want unset", got)
+		}
+	})
+
+	t.Run("SETLIST_CONTEXT", func(t *testing.T) {
+		layers, err := configLayers(context.Background(), loader, "ssm:/setlist/config", lookupFrom(map[string]string{"SETLIST_CONTEXT": "readonly"}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		resolved := loadSettings(Event{}, lookupFrom(nil), layers...)
+		if got := resolved["include-permission-sets"]; got.Value != "ReadOnly" || got.Source != "context readonly ssm:/setlist/config" {
+			t.Errorf("include-permission-sets = %+v", got)
+		}
+	})
+
+	t.Run("unknown context", func(t *testing.T) {
+		_, err := configLayers(context.Background(), loader, "ssm:/setlist/config", lookupFrom(map[string]string{"SETLIST_CONTEXT": "missing"}))
+		if err == nil || !strings.Contains(err.Error(), "missing") {
+			t.Errorf("expected unknown context error, got %v", err)
+		}
+	})
+
+	t.Run("missing parameter", func(t *testing.T) {
+		_, err := configLayers(context.Background(), loader, "ssm:/setlist/other", lookupFrom(nil))
+		if err == nil || !strings.Contains(err.Error(), "ParameterNotFound") {
+			t.Errorf("expected ParameterNotFound error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// sourceEvent is reported as the source of values taken from the
+// invocation event.
+const sourceEvent = "event"
+
+// Event is the JSON payload the function can be invoked with, e.g. from a
+// Step Functions task. Each field mirrors the environment variable of the
+// same name, lower-cased, and overrides it for this invocation only. Empty
+// fields leave the environment's value alone.
+type Event struct {
+	SSOSession            string `json:"sso_session,omitempty"`
+	SSORegion             string `json:"sso_region,omitempty"`
+	SSOFriendlyName       string `json:"sso_friendly_name,omitempty"`
+	SSOStartURL           string `json:"sso_start_url,omitempty"`
+	NicknameMapping       string `json:"nickname_mapping,omitempty"`
+	IncludeAccounts       string `json:"include_accounts,omitempty"`
+	ExcludeAccounts       string `json:"exclude_accounts,omitempty"`
+	IncludePermissionSets string `json:"include_permission_sets,omitempty"`
+	ExcludePermissionSets string `json:"exclude_permission_sets,omitempty"`
+	Filter                string `json:"filter,omitempty"`
+	InstanceArn           string `json:"instance_arn,omitempty"`
+	IdentityStoreId       string `json:"identity_store_id,omitempty"`
+	S3Bucket              string `json:"s3_bucket,omitempty"`
+	S3Key                 string `json:"s3_key,omitempty"`
+	Format                string `json:"format,omitempty"`
+}
+
+// parseEvent decodes the payload of a direct invocation. An empty payload
+// or null carries no overrides. Any other payload must be an Event with no
+// unknown fields, so a misspelt override fails the run rather than being
+// ignored.
+func parseEvent(payload []byte) (Event, error) {
+	var event Event
+
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 || bytes.Equal(payload, []byte("null")) {
+		return event, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&event); err != nil {
+		return event, fmt.Errorf("invalid event: %w", err)
+	}
+	return event, nil
+}
+
+// values returns the overrides the event sets, keyed by option name.
+func (e Event) values() map[string]string {
+	all := map[string]string{
+		"sso-session":             e.SSOSession,
+		"sso-region":              e.SSORegion,
+		"sso-friendly-name":       e.SSOFriendlyName,
+		"sso-start-url":           e.SSOStartURL,
+		"mapping":                 e.NicknameMapping,
+		"include-accounts":        e.IncludeAccounts,
+		"exclude-accounts":        e.ExcludeAccounts,
+		"include-permission-sets": e.IncludePermissionSets,
+		"exclude-permission-sets": e.ExcludePermissionSets,
+		"filter":                  e.Filter,
+		"instance-arn":            e.InstanceArn,
+		"identity-store-id":       e.IdentityStoreId,
+		keyS3Bucket:               e.S3Bucket,
+		keyS3Key:                  e.S3Key,
+		keyFormat:                 e.Format,
+	}
+
+	values := map[string]string{}
+	for k, v := range all {
+		if v != "" {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// Layer returns the event's overrides as the highest-precedence settings
+// layer.
+func (e Event) Layer() settings.Layer {
+	return settings.Layer{Source: sourceEvent, Values: e.values()}
+}
+
+// Validate checks the event's overrides before any AWS call is made, so a
+// bad invocation fails fast with every problem listed.
+func (e Event) Validate(allowUnknownRegion bool) error {
+	var errs []error
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+
+	if e.SSORegion != "" {
+		newRegion := setlist.NewRegion
+		if allowUnknownRegion {
+			newRegion = setlist.NewRegionAllowUnknown
+		}
+		_, err := newRegion(e.SSORegion)
+		check("sso_region", err)
+	}
+	if e.SSOStartURL != "" {
+		check("sso_start_url", setlist.ValidateStartURL(e.SSOStartURL))
+	}
+	if e.NicknameMapping != "" {
+		_, err := setlist.ParseNicknameMapping(e.NicknameMapping)
+		check("nickname_mapping", err)
+	}
+	// Patterns from an event never name files: reading them would let
+	// whoever can invoke the function read its local files, such as
+	// /proc/self/environ, back out of error messages.
+	fileReference := func(field, patterns string) bool {
+		if !setlist.HasPatternFile(patterns) {
+			return false
+		}
+		check(field, errors.New("@file references are not allowed in events"))
+		return true
+	}
+	if !fileReference("include_accounts", e.IncludeAccounts) {
+		_, err := setlist.ParseAccountPatterns(e.IncludeAccounts)
+		check("include_accounts", err)
+	}
+	if !fileReference("exclude_accounts", e.ExcludeAccounts) {
+		_, err := setlist.ParseAccountPatterns(e.ExcludeAccounts)
+		check("exclude_accounts", err)
+	}
+	if !fileReference("include_permission_sets", e.IncludePermissionSets) {
+		_, err := setlist.ParsePermissionSetPatterns(e.IncludePermissionSets)
+		check("include_permission_sets", err)
+	}
+	if !fileReference("exclude_permission_sets", e.ExcludePermissionSets) {
+		_, err := setlist.ParsePermissionSetPatterns(e.ExcludePermissionSets)
+		check("exclude_permission_sets", err)
+	}
+	if e.Filter != "" {
+		_, err := setlist.NewFilter(e.Filter)
+		check("filter", err)
+	}
+	if e.InstanceArn != "" && e.IdentityStoreId != "" {
+		check("instance_arn", errors.New("cannot be combined with identity_store_id"))
+	}
+	if e.InstanceArn != "" && !arn.IsARN(e.InstanceArn) {
+		check("instance_arn", errors.New("not an ARN"))
+	}
+	if e.IdentityStoreId != "" {
+		_, err := setlist.NewIdentityStoreId(e.IdentityStoreId)
+		check("identity_store_id", err)
+	}
+	if strings.HasPrefix(e.S3Key, "/") {
+		check("s3_key", errors.New("must not start with /"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid event: %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Event
+		wantErr string
+	}{
+		{name: "empty", payload: ""},
+		{name: "null", payload: "null"},
+		{name: "empty object", payload: "{}"},
+		{
+			name:    "overrides",
+			payload: `{"s3_key":"readonly.config","include_permission_sets":"ReadOnly*","format":"ini"}`,
+			want:    Event{S3Key: "readonly.config", IncludePermissionSets: "ReadOnly*", Format: "ini"},
+		},
+		{
+			name:    "unknown field",
+			payload: `{"s3key":"typo.config"}`,
+			wantErr: `unknown field "s3key"`,
+		},
+		{
+			name:    "not an object",
+			payload: `"hello"`,
+			wantErr: "invalid event",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseEvent([]byte(tc.payload))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("parseEvent() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEventValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		event        Event
+		allowUnknown bool
+		wantErr      []string
+	}{
+		{name: "empty", event: Event{}},
+		{
+			name: "valid",
+			event: Event{
+				SSORegion:             "ca-central-1",
+				SSOStartURL:           "https://corp.example.com/start",
+				NicknameMapping:       "123456789012=prod",
+				IncludeAccounts:       "1234*",
+				ExcludePermissionSets: "re:^Admin",
+				Filter:                `account.name != "sandbox"`,
+				IdentityStoreId:       "d-1234567890",
+				S3Key:                 "team/readonly.config",
+			},
+		},
+		{
+			name:    "unknown region",
+			event:   Event{SSORegion: "xx-central-1"},
+			wantErr: []string{"sso_region"},
+		},
+		{
+			name:         "unknown region allowed",
+			event:        Event{SSORegion: "xx-central-1"},
+			allowUnknown: true,
+		},
+		{
+			name: "file references",
+			event: Event{
+				IncludeAccounts:       "1234*,@/proc/self/environ",
+				ExcludeAccounts:       " @accounts.txt",
+				IncludePermissionSets: "@/etc/passwd",
+				ExcludePermissionSets: "re:^@Admin",
+			},
+			wantErr: []string{
+				"include_accounts: @file references are not allowed in events",
+				"exclude_accounts: @file references are not allowed in events",
+				"include_permission_sets: @file references are not allowed in events",
+			},
+		},
+		{
+			name: "every problem listed",
+			event: Event{
+				SSOStartURL:     "http://corp.example.com",
+				NicknameMapping: "nope",
+				IncludeAccounts: "12",
+				Filter:          "account.name ==",
+				InstanceArn:     "not-an-arn",
+				IdentityStoreId: "d-1234567890",
+				S3Key:           "/leading.config",
+			},
+			wantErr: []string{"sso_start_url", "nickname_mapping", "include_accounts", "filter", "instance_arn: cannot be combined", "instance_arn: not an ARN", "s3_key"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.event.Validate(tc.allowUnknown)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadSettings_EventOverridesEnv(t *testing.T) {
+	env := map[string]string{
+		"S3_KEY":                   "all.config",
+		"SETLIST_SSO_REGION":       "us-east-1",
+		"INCLUDE_PERMISSION_SETS":  "",
+		"SETLIST_EXCLUDE_ACCOUNTS": "111111111111",
+		"EXCLUDE_PERMISSION_SETS":  "Billing",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	event := Event{S3Key: "readonly.config", IncludePermissionSets: "ReadOnly*"}
+	resolved := loadSettings(event, lookup)
+
+	tests := []struct {
+		key, value, source string
+	}{
+		{keyS3Key, "readonly.config", sourceEvent},
+		{"include-permission-sets", "ReadOnly*", sourceEvent},
+		{"sso-region", "us-east-1", "env SETLIST_SSO_REGION"},
+		{"exclude-permission-sets", "Billing", "env EXCLUDE_PERMISSION_SETS"},
+	}
+	for _, tc := range tests {
+		if got := resolved[tc.key]; got.Value != tc.value || got.Source != tc.source {
+			t.Errorf("%s = %+v, want %q from %q", tc.key, got, tc.value, tc.source)
+		}
+	}
+}
+
+func TestRequireSetting(t *testing.T) {
+	_, err := requireSetting(loadSettings(Event{}, func(string) (string, bool) { return "", false }), keyS3Bucket)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"S3_BUCKET", "SETLIST_S3_BUCKET", "s3_bucket event field"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
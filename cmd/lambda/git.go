@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Option keys for the credentials the git sink pushes with.
+const (
+	keyGitTokenSecret = "git-token-secret"
+	keyGitUsername    = "git-username"
+)
+
+// defaultGitUsername is sent with the token when GIT_USERNAME is unset. It
+// is what GitHub expects for app and fine-grained tokens; GitLab and
+// Bitbucket accept any non-empty username with an access token.
+const defaultGitUsername = "x-access-token"
+
+// defaultBranch is the branch a git output commits to unless it names one.
+const defaultBranch = "main"
+
+// The author of the git sink's commits.
+const (
+	commitAuthorName  = "setlist"
+	commitAuthorEmail = "setlist@localhost"
+)
+
+// maxCloneSize is the most object data a clone may hold. The clone, its
+// worktree and every rendered output share the function's 128 MB.
+const maxCloneSize = 32 << 20
+
+// errPushRejected means the branch moved on the remote after it was cloned.
+var errPushRejected = errors.New("push rejected")
+
+// errRepositoryTooLarge means the tip of a branch holds more than
+// maxCloneSize, too much to clone into memory.
+var errRepositoryTooLarge = fmt.Errorf("repository holds more than %d MiB at the tip of the branch; keep large files out of the repository setlist writes to", maxCloneSize>>20)
+
+// gitRepository is a branch of a remote repository, cloned locally.
+type gitRepository interface {
+	// ReadFile returns the contents of a file on the branch, or an error
+	// wrapping fs.ErrNotExist when there is no such file.
+	ReadFile(path string) ([]byte, error)
+
+	// CommitFile writes a file, commits it and pushes the branch. It returns
+	// an error wrapping errPushRejected when the remote branch has moved.
+	CommitFile(ctx context.Context, path string, body []byte, message string) error
+
+	// Files lists the paths of every file on the branch.
+	Files() ([]string, error)
+
+	// RemoveFiles deletes files in one commit and pushes the branch, like
+	// CommitFile.
+	RemoveFiles(ctx context.Context, paths []string, message string) error
+}
+
+// openRepositoryFunc clones a branch of a repository.
+type openRepositoryFunc func(ctx context.Context, repository, branch string) (gitRepository, error)
+
+// gitSink writes artifacts as files committed to a git repository, one
+// commit per changed file. Each repository and branch is cloned once per
+// invocation, on first use.
+type gitSink struct {
+	open  openRepositoryFunc
+	repos map[string]gitRepository
+}
+
+func newGitSink(open openRepositoryFunc) *gitSink {
+	return &gitSink{open: open, repos: map[string]gitRepository{}}
+}
+
+// repository returns the clone of t's repository and branch, cloning it on
+// first use.
+func (s *gitSink) repository(ctx context.Context, t target) (gitRepository, error) {
+	id := t.repository + "#" + t.branch
+	if repo, ok := s.repos[id]; ok {
+		return repo, nil
+	}
+	slog.Info("Cloning repository", "repository", t.repository, "branch", t.branch)
+	repo, err := s.open(ctx, t.repository, t.branch)
+	if err != nil {
+		return nil, err
+	}
+	s.repos[id] = repo
+	return repo, nil
+}
+
+// forget drops the clone of t's repository after a failed push, since it
+// may hold a commit the remote doesn't, so the next write starts from a
+// fresh one.
+func (s *gitSink) forget(t target) {
+	delete(s.repos, t.repository+"#"+t.branch)
+}
+
+func (s *gitSink) Write(ctx context.Context, a artifact) (Result, error) {
+	result := newResult(a)
+
+	repo, err := s.repository(ctx, a.target)
+	if err != nil {
+		return result, err
+	}
+
+	previous, err := repo.ReadFile(a.key)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return result, fmt.Errorf("failed to read %s: %w", a.target, err)
+	case setlist.ContentHash(previous) == result.Hash:
+		slog.Info("Config file unchanged, skipping commit", "location", result.Location, "hash", result.Hash)
+		result.Status = StatusUnchanged
+		return result, nil
+	default:
+		result.previous = previous
+	}
+
+	message := fmt.Sprintf("Update %s\n\nGenerated by setlist with %d account and permission set pairs.\n\nSetlist-Hash: %s\n", a.key, a.profiles, result.Hash)
+	slog.Info("Committing config", "location", result.Location, "hash", result.Hash)
+	if err := repo.CommitFile(ctx, a.key, a.body, message); err != nil {
+		s.forget(a.target)
+		if errors.Is(err, errPushRejected) {
+			return result, fmt.Errorf("%s was modified during the push; it will be retried on the next run: %w", a.target, err)
+		}
+		return result, fmt.Errorf("failed to push %s: %w", a.target, err)
+	}
+
+	result.Status = StatusChanged
+	result.Changed = true
+	return result, nil
+}
+
+func (s *gitSink) Stored(ctx context.Context, t target) (string, error) {
+	body, err := s.Read(ctx, t)
+	if err != nil || body == nil {
+		return "", err
+	}
+	return setlist.ContentHash(body), nil
+}
+
+func (s *gitSink) Read(ctx context.Context, t target) ([]byte, error) {
+	repo, err := s.repository(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	body, err := repo.ReadFile(t.key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", t, err)
+	}
+	return body, nil
+}
+
+// Prune removes the stale files under the prefix in one commit.
+func (s *gitSink) Prune(ctx context.Context, t target, stale func(key string) bool) ([]string, error) {
+	repo, err := s.repository(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	files, err := repo.Files()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %w", t, err)
+	}
+
+	var paths, locations []string
+	for _, name := range files {
+		if strings.HasPrefix(name, t.key) && stale(name) {
+			f := t
+			f.key = name
+			paths = append(paths, name)
+			locations = append(locations, f.String())
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	message := fmt.Sprintf("Remove %d stale configs\n\nRemoved by setlist because their groups no longer exist:\n\n%s\n", len(paths), strings.Join(paths, "\n"))
+	slog.Info("Removing stale configs", "location", t.String(), "files", len(paths))
+	if err := repo.RemoveFiles(ctx, paths, message); err != nil {
+		s.forget(t)
+		if errors.Is(err, errPushRejected) {
+			return nil, fmt.Errorf("%s was modified during the push; it will be retried on the next run: %w", t, err)
+		}
+		return nil, fmt.Errorf("failed to push %s: %w", t, err)
+	}
+	return locations, nil
+}
+
+// gitAuth returns the credentials to push over HTTPS with: the token held
+// by the secret GIT_TOKEN_SECRET names, sent as the password of
+// GIT_USERNAME.
+func gitAuth(ctx context.Context, store secretStore, resolved settings.Resolved) (transport.AuthMethod, error) {
+	secret := resolved.Get(keyGitTokenSecret)
+	if secret == "" {
+		return nil, fmt.Errorf("%s (or %s) environment variable is required for git outputs", legacyEnv[keyGitTokenSecret], settings.EnvName(keyGitTokenSecret))
+	}
+	token, err := readSecret(ctx, store, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	username := resolved.Get(keyGitUsername)
+	if username == "" {
+		username = defaultGitUsername
+	}
+	return &githttp.BasicAuth{Username: username, Password: strings.TrimSpace(token)}, nil
+}
+
+// boundedStorage is in-memory storage that refuses objects once they add
+// up to more than limit bytes, so a large repository fails the clone
+// instead of the function running out of memory.
+type boundedStorage struct {
+	*memory.Storage
+	size  int64
+	limit int64
+}
+
+func (s *boundedStorage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	s.size += obj.Size()
+	if s.size > s.limit {
+		return plumbing.ZeroHash, errRepositoryTooLarge
+	}
+	return s.Storage.SetEncodedObject(obj)
+}
+
+// remoteRepository is a gitRepository cloned into memory with go-git, so
+// the function needs neither a git binary nor a writable disk. base is the
+// commit the remote branch is expected to point at, zero while it doesn't
+// exist.
+type remoteRepository struct {
+	repo   *git.Repository
+	branch plumbing.ReferenceName
+	auth   transport.AuthMethod
+	base   plumbing.Hash
+}
+
+// cloneRepository clones the tip of one branch of url into memory, without
+// its history. An empty repository is initialised instead, and its first
+// push creates the branch. auth may be nil.
+func cloneRepository(ctx context.Context, url, branch string, auth transport.AuthMethod) (*remoteRepository, error) {
+	ref := plumbing.NewBranchReferenceName(branch)
+	repo, err := git.CloneContext(ctx, &boundedStorage{Storage: memory.NewStorage(), limit: maxCloneSize}, memfs.New(), &git.CloneOptions{
+		URL:           url,
+		Auth:          auth,
+		ReferenceName: ref,
+		SingleBranch:  true,
+		Depth:         1,
+		Tags:          git.NoTags,
+	})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		repo, err = git.InitWithOptions(memory.NewStorage(), memfs.New(), git.InitOptions{DefaultBranch: ref})
+		if err == nil {
+			_, err = repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone %s branch %s: %w", url, branch, err)
+	}
+
+	r := &remoteRepository{repo: repo, branch: ref, auth: auth}
+	if head, err := repo.Reference(ref, true); err == nil {
+		r.base = head.Hash()
+	}
+	return r, nil
+}
+
+// remoteHead returns the commit the branch points at on the remote, or the
+// zero hash when it doesn't exist.
+func (r *remoteRepository) remoteHead(ctx context.Context) (plumbing.Hash, error) {
+	remote, err := r.repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: r.auth})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	for _, ref := range refs {
+		if ref.Name() == r.branch {
+			return ref.Hash(), nil
+		}
+	}
+	return plumbing.ZeroHash, nil
+}
+
+// checkBase returns an error wrapping errPushRejected when the remote
+// branch no longer points at the commit the clone is based on.
+func (r *remoteRepository) checkBase(ctx context.Context) error {
+	head, err := r.remoteHead(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list remote branches: %w", err)
+	}
+	if head != r.base {
+		return fmt.Errorf("%w: %s is at %s, not %s", errPushRejected, r.branch.Short(), head, r.base)
+	}
+	return nil
+}
+
+func (r *remoteRepository) ReadFile(name string) ([]byte, error) {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	f, err := wt.Filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// CommitFile checks the remote branch hasn't moved before committing, and
+// again when the push fails, to tell a lost race from any other failure.
+func (r *remoteRepository) CommitFile(ctx context.Context, name string, body []byte, message string) error {
+	if err := r.checkBase(ctx); err != nil {
+		return err
+	}
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	if dir := path.Dir(name); dir != "." {
+		if err := wt.Filesystem.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	if err := util.WriteFile(wt.Filesystem, name, body, 0o644); err != nil {
+		return err
+	}
+	if _, err := wt.Add(name); err != nil {
+		return err
+	}
+	return r.commitAndPush(ctx, wt, message)
+}
+
+func (r *remoteRepository) Files() ([]string, error) {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = util.Walk(wt.Filesystem, "", func(name string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, name)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (r *remoteRepository) RemoveFiles(ctx context.Context, paths []string, message string) error {
+	if err := r.checkBase(ctx); err != nil {
+		return err
+	}
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	for _, name := range paths {
+		if _, err := wt.Remove(name); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	return r.commitAndPush(ctx, wt, message)
+}
+
+// commitAndPush commits what is staged in wt and pushes the branch.
+func (r *remoteRepository) commitAndPush(ctx context.Context, wt *git.Worktree, message string) error {
+	commit, err := wt.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{Name: commitAuthorName, Email: commitAuthorEmail, When: time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	err = r.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(r.branch + ":" + r.branch)},
+		Auth:       r.auth,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		if moved := r.checkBase(ctx); errors.Is(moved, errPushRejected) {
+			return fmt.Errorf("%w: %w", moved, err)
+		}
+		return err
+	}
+	r.base = commit
+	return nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// fakeRepository is an in-memory gitRepository. Files are only visible
+// once committed.
+type fakeRepository struct {
+	files   map[string]string
+	commits []string
+	pushErr error
+}
+
+func (f *fakeRepository) ReadFile(path string) ([]byte, error) {
+	body, ok := f.files[path]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return []byte(body), nil
+}
+
+func (f *fakeRepository) CommitFile(ctx context.Context, path string, body []byte, message string) error {
+	if f.pushErr != nil {
+		return f.pushErr
+	}
+	f.files[path] = string(body)
+	f.commits = append(f.commits, message)
+	return nil
+}
+
+func (f *fakeRepository) Files() ([]string, error) {
+	var names []string
+	for name := range f.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (f *fakeRepository) RemoveFiles(ctx context.Context, paths []string, message string) error {
+	if f.pushErr != nil {
+		return f.pushErr
+	}
+	for _, name := range paths {
+		delete(f.files, name)
+	}
+	f.commits = append(f.commits, message)
+	return nil
+}
+
+func gitArtifact(key string, body []byte) artifact {
+	return artifact{
+		target:   target{sink: sinkGit, repository: "https://git.example.com/platform/configs.git", branch: "main", key: key},
+		format:   formatINI,
+		profiles: 3,
+		body:     body,
+	}
+}
+
+func TestGitSink_Write(t *testing.T) {
+	body := []byte("# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n")
+	repo := &fakeRepository{files: map[string]string{
+		"aws/unchanged.config": strings.Replace(string(body), "2024", "2023", 1),
+		"aws/changed.config":   "old",
+	}}
+	var opens int
+	sink := newGitSink(func(ctx context.Context, repository, branch string) (gitRepository, error) {
+		opens++
+		return repo, nil
+	})
+
+	tests := []struct {
+		key          string
+		wantStatus   string
+		wantPrevious string
+	}{
+		{key: "aws/new.config", wantStatus: StatusChanged},
+		{key: "aws/unchanged.config", wantStatus: StatusUnchanged},
+		{key: "aws/changed.config", wantStatus: StatusChanged, wantPrevious: "old"},
+	}
+	for _, tc := range tests {
+		result, err := sink.Write(context.Background(), gitArtifact(tc.key, body))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.key, err)
+		}
+		if result.Status != tc.wantStatus || string(result.previous) != tc.wantPrevious {
+			t.Errorf("%s: status %q, previous %q; want %q, %q", tc.key, result.Status, result.previous, tc.wantStatus, tc.wantPrevious)
+		}
+		if want := "https://git.example.com/platform/configs.git#main:" + tc.key; result.Location != want {
+			t.Errorf("Location = %q, want %q", result.Location, want)
+		}
+	}
+
+	if opens != 1 {
+		t.Errorf("repository opened %d times, want once", opens)
+	}
+	if len(repo.commits) != 2 {
+		t.Fatalf("%d commits, want one per changed file", len(repo.commits))
+	}
+	if !strings.HasPrefix(repo.commits[0], "Update aws/new.config\n") || !strings.Contains(repo.commits[0], "Setlist-Hash: ") {
+		t.Errorf("commit message = %q", repo.commits[0])
+	}
+}
+
+func TestGitSink_PushRejected(t *testing.T) {
+	var opens int
+	sink := newGitSink(func(ctx context.Context, repository, branch string) (gitRepository, error) {
+		opens++
+		return &fakeRepository{files: map[string]string{}, pushErr: errPushRejected}, nil
+	})
+
+	_, err := sink.Write(context.Background(), gitArtifact("aws.config", []byte("[default]\n")))
+	if err == nil || !strings.Contains(err.Error(), "was modified during the push") {
+		t.Fatalf("expected rejected push error, got %v", err)
+	}
+
+	sink.Write(context.Background(), gitArtifact("aws.config", []byte("[default]\n")))
+	if opens != 2 {
+		t.Errorf("repository opened %d times, want a fresh clone after a failed push", opens)
+	}
+}
+
+func TestGitSink_Prune(t *testing.T) {
+	repo := &fakeRepository{files: map[string]string{
+		"aws/Admin.config":    "kept",
+		"aws/Retired.config":  "stale",
+		"aws/README.md":       "not a config",
+		"other/Admin.config":  "outside the prefix",
+		"aws/Deleted.config":  "stale",
+		"aws/Deleted.config2": "not a config",
+	}}
+	sink := newGitSink(func(ctx context.Context, repository, branch string) (gitRepository, error) {
+		return repo, nil
+	})
+	stale := func(key string) bool { return strings.HasSuffix(key, ".config") && key != "aws/Admin.config" }
+
+	deleted, err := sink.Prune(context.Background(), gitArtifact("aws/", nil).target, stale)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"https://git.example.com/platform/configs.git#main:aws/Deleted.config",
+		"https://git.example.com/platform/configs.git#main:aws/Retired.config",
+	}
+	if !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted = %v, want %v", deleted, want)
+	}
+	if len(repo.commits) != 1 || !strings.HasPrefix(repo.commits[0], "Remove 2 stale configs\n") {
+		t.Errorf("commits = %q, want one removing both", repo.commits)
+	}
+
+	// Nothing stale, nothing committed.
+	if deleted, err := sink.Prune(context.Background(), gitArtifact("aws/", nil).target, stale); err != nil || deleted != nil || len(repo.commits) != 1 {
+		t.Errorf("second prune = %v, %v with %d commits", deleted, err, len(repo.commits))
+	}
+}
+
+func TestGitSink_OpenError(t *testing.T) {
+	sink := newGitSink(func(ctx context.Context, repository, branch string) (gitRepository, error) {
+		return nil, errors.New("authentication required")
+	})
+
+	if _, err := sink.Write(context.Background(), gitArtifact("aws.config", []byte("[default]\n"))); err == nil || !strings.Contains(err.Error(), "authentication required") {
+		t.Errorf("expected authentication error, got %v", err)
+	}
+}
+
+// TestRemoteRepository pushes to a bare repository on disk. The file
+// transport runs git-upload-pack and git-receive-pack, so it needs git.
+func TestRemoteRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := filepath.Join(t.TempDir(), "configs.git")
+	if _, err := git.PlainInit(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	first, err := cloneRepository(ctx, dir, "main", nil)
+	if err != nil {
+		t.Fatalf("cloning an empty repository: %v", err)
+	}
+	if _, err := first.ReadFile("aws/aws.config"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile on an empty repository = %v, want fs.ErrNotExist", err)
+	}
+	if err := first.CommitFile(ctx, "aws/aws.config", []byte("one\n"), "Add aws.config"); err != nil {
+		t.Fatalf("first push: %v", err)
+	}
+
+	second, err := cloneRepository(ctx, dir, "main", nil)
+	if err != nil {
+		t.Fatalf("cloning: %v", err)
+	}
+	if got, err := second.ReadFile("aws/aws.config"); err != nil || string(got) != "one\n" {
+		t.Errorf("ReadFile = %q, %v; want the pushed file", got, err)
+	}
+
+	if err := second.CommitFile(ctx, "aws/aws.config", []byte("two\n"), "Update aws.config"); err != nil {
+		t.Fatalf("second push: %v", err)
+	}
+	if err := first.CommitFile(ctx, "aws/aws.config", []byte("three\n"), "Update aws.config"); !errors.Is(err, errPushRejected) {
+		t.Errorf("push from a stale clone = %v, want errPushRejected", err)
+	}
+
+	// Only the tip is cloned, however long the history.
+	third, err := cloneRepository(ctx, dir, "main", nil)
+	if err != nil {
+		t.Fatalf("cloning: %v", err)
+	}
+	if shallow, err := third.repo.Storer.Shallow(); err != nil || len(shallow) != 1 {
+		t.Errorf("shallow commits = %v, %v; want the tip", shallow, err)
+	}
+	if err := third.CommitFile(ctx, "aws/aws.config", []byte("four\n"), "Update aws.config"); err != nil {
+		t.Errorf("push from a shallow clone: %v", err)
+	}
+	if got, err := third.ReadFile("aws/aws.config"); err != nil || string(got) != "four\n" {
+		t.Errorf("ReadFile = %q, %v", got, err)
+	}
+
+	if err := third.CommitFile(ctx, "aws/old.config", []byte("old\n"), "Add old.config"); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if files, err := third.Files(); err != nil || !reflect.DeepEqual(files, []string{"aws/aws.config", "aws/old.config"}) {
+		t.Errorf("Files() = %v, %v", files, err)
+	}
+	if err := third.RemoveFiles(ctx, []string{"aws/old.config"}, "Remove old.config"); err != nil {
+		t.Fatalf("removing: %v", err)
+	}
+	fourth, err := cloneRepository(ctx, dir, "main", nil)
+	if err != nil {
+		t.Fatalf("cloning: %v", err)
+	}
+	if files, err := fourth.Files(); err != nil || !reflect.DeepEqual(files, []string{"aws/aws.config"}) {
+		t.Errorf("Files() after removal = %v, %v", files, err)
+	}
+}
+
+func TestBoundedStorage(t *testing.T) {
+	s := &boundedStorage{Storage: memory.NewStorage(), limit: 10}
+	blob := func(content string) plumbing.EncodedObject {
+		obj := s.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		w, _ := obj.Writer()
+		w.Write([]byte(content))
+		w.Close()
+		return obj
+	}
+
+	if _, err := s.SetEncodedObject(blob("123456")); err != nil {
+		t.Fatalf("object within the limit: %v", err)
+	}
+	if _, err := s.SetEncodedObject(blob("abcdef")); !errors.Is(err, errRepositoryTooLarge) {
+		t.Errorf("object past the limit = %v, want errRepositoryTooLarge", err)
+	}
+}
+
+func TestGitAuth(t *testing.T) {
+	store := newFakeSecretStore()
+	store.secrets["setlist/git-token"] = "ghp_token\n"
+
+	auth, err := gitAuth(context.Background(), store, loadSettings(Event{}, lookupFrom(map[string]string{"GIT_TOKEN_SECRET": "setlist/git-token"})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	basic, ok := auth.(*githttp.BasicAuth)
+	if !ok || basic.Username != defaultGitUsername || basic.Password != "ghp_token" {
+		t.Errorf("auth = %+v", auth)
+	}
+
+	auth, err = gitAuth(context.Background(), store, loadSettings(Event{}, lookupFrom(map[string]string{"GIT_TOKEN_SECRET": "setlist/git-token", "GIT_USERNAME": "oauth2"})))
+	if err != nil || auth.(*githttp.BasicAuth).Username != "oauth2" {
+		t.Errorf("auth = %+v, %v; want username oauth2", auth, err)
+	}
+
+	if _, err := gitAuth(context.Background(), store, loadSettings(Event{}, lookupFrom(nil))); err == nil || !strings.Contains(err.Error(), "GIT_TOKEN_SECRET") {
+		t.Errorf("expected GIT_TOKEN_SECRET error, got %v", err)
+	}
+	if _, err := gitAuth(context.Background(), store, loadSettings(Event{}, lookupFrom(map[string]string{"GIT_TOKEN_SECRET": "missing"}))); err == nil {
+		t.Error("expected error for a missing secret")
+	}
+}
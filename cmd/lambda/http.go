@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Options for the HTTPS endpoint. keyServeRegenerate lets a request
+// generate the config itself when the scheduled runs haven't stored it, and
+// keyServeTTL is how long such a config is kept before regenerating it.
+const (
+	keyServeRegenerate = "serve-regenerate"
+	keyServeTTL        = "serve-ttl"
+)
+
+// defaultServeTTL applies when SERVE_TTL is unset.
+const defaultServeTTL = 15 * time.Minute
+
+// errNotStored means no S3 output holds the whole config in the requested
+// format, so there is nothing stored to serve.
+var errNotStored = errors.New("no s3 output holds the whole config in this format")
+
+// serveCacheControl lets clients keep the config but has them revalidate it
+// with its ETag on every use, which costs a 304 when nothing changed.
+const serveCacheControl = "private, no-cache"
+
+// httpRequest is the part of a Lambda Function URL or API Gateway HTTP API
+// (payload format 2.0) event the function reads.
+type httpRequest struct {
+	Headers               map[string]string `json:"headers"`
+	QueryStringParameters map[string]string `json:"queryStringParameters"`
+	RequestContext        struct {
+		HTTP struct {
+			Method string `json:"method"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+// httpResponse is the response a Function URL or HTTP API expects.
+type httpResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+}
+
+// parseHTTPRequest returns the HTTP request a payload holds, and false when
+// the payload isn't one.
+func parseHTTPRequest(payload []byte) (httpRequest, bool, error) {
+	var envelope struct {
+		RequestContext map[string]json.RawMessage `json:"requestContext"`
+	}
+	if trimmed := bytes.TrimSpace(payload); len(trimmed) == 0 || trimmed[0] != '{' {
+		return httpRequest{}, false, nil
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return httpRequest{}, false, fmt.Errorf("invalid event: %w", err)
+	}
+	if _, ok := envelope.RequestContext["http"]; !ok {
+		return httpRequest{}, false, nil
+	}
+
+	var req httpRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return httpRequest{}, false, fmt.Errorf("invalid HTTP event: %w", err)
+	}
+	return req, true, nil
+}
+
+// header returns a request header. Function URLs and HTTP APIs lower-case
+// header names, but tests and proxies may not.
+func (r httpRequest) header(name string) string {
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// servedConfig is a rendered config and its content hash, which becomes
+// its ETag.
+type servedConfig struct {
+	body []byte
+	hash string
+}
+
+// configCache holds the config file the HTTPS endpoint generated itself,
+// when regenerating is enabled and nothing is stored to serve. It lives as
+// long as the execution environment, so it is only shared by the requests
+// that environment handles.
+type configCache struct {
+	mu         sync.Mutex
+	configFile setlist.ConfigFile
+	generated  time.Time
+	now        func() time.Time
+}
+
+// regenerated is the config file this execution environment generated to
+// serve.
+var regenerated = &configCache{now: time.Now}
+
+// get returns the cached config file, regenerating it first when it is
+// missing or older than ttl. A stale config is still served if regenerating
+// fails, so an outage of the discovery APIs doesn't take the endpoint down
+// with it. Concurrent callers wait for a single regeneration.
+func (c *configCache) get(ctx context.Context, ttl time.Duration, generate func(context.Context) (setlist.ConfigFile, error)) (setlist.ConfigFile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.generated.IsZero() && c.now().Sub(c.generated) < ttl {
+		return c.configFile, nil
+	}
+
+	configFile, err := generate(ctx)
+	if err != nil {
+		if c.generated.IsZero() {
+			return setlist.ConfigFile{}, err
+		}
+		slog.Warn("Failed to regenerate config, serving the cached one", "error", err.Error(), "generated", c.generated)
+		return c.configFile, nil
+	}
+	c.configFile = configFile
+	c.generated = c.now()
+	return configFile, nil
+}
+
+// handleHTTP serves the config file over a Function URL or HTTP API. It is
+// read from the S3 output the scheduled and change runs write, so a request
+// never waits on discovery. Only with SERVE_REGENERATE set does a request
+// that finds nothing stored generate the config itself. Problems are
+// answered with an HTTP error rather than returned, which the caller would
+// only see as a bare 502.
+func handleHTTP(ctx context.Context, req httpRequest) httpResponse {
+	start := time.Now()
+	metrics := newEMFRecorder(os.LookupEnv)
+	endpointMetrics.set(metrics)
+	defer func() {
+		endpointMetrics.set(nil)
+		if err := metrics.Flush(os.Stdout, time.Since(start), time.Now()); err != nil {
+			slog.Warn("Failed to write metrics", "error", err.Error())
+		}
+	}()
+
+	e, err := currentEndpoint(ctx)
+	if err != nil {
+		slog.Error("Failed to set up the HTTPS endpoint", "error", err.Error())
+		return textResponse(http.StatusInternalServerError, "function is misconfigured")
+	}
+	return serveConfig(ctx, req, e.resolved.Get(keyFormat), e.load)
+}
+
+// endpointMetrics is what the clients the HTTPS endpoint builds count
+// their calls with.
+var endpointMetrics = &requestRecorder{}
+
+// endpoint is what the HTTPS endpoint sets up once per execution
+// environment: its settings, the outputs it serves and the clients it reads
+// them with.
+type endpoint struct {
+	resolved   settings.Resolved
+	fallback   bool
+	ttl        time.Duration
+	outputs    []Output
+	outputsErr error
+	store      objectStore
+	cache      *servedCache
+	regenerate func(context.Context) (setlist.ConfigFile, error)
+	created    time.Time
+}
+
+var (
+	endpointMu sync.Mutex
+	// httpEndpoint is this execution environment's endpoint, once a request
+	// has set it up.
+	httpEndpoint *endpoint
+)
+
+// currentEndpoint returns this execution environment's endpoint, setting
+// it up on first use, and again once CONFIG_TTL passes so it follows the
+// config document. A failed setup is retried by the next request.
+func currentEndpoint(ctx context.Context) (*endpoint, error) {
+	endpointMu.Lock()
+	defer endpointMu.Unlock()
+
+	ttl, err := configTTL(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	if httpEndpoint != nil && (ttl == 0 || time.Since(httpEndpoint.created) < ttl) {
+		return httpEndpoint, nil
+	}
+	e, err := newEndpoint(ctx, endpointMetrics)
+	if err != nil {
+		return nil, err
+	}
+	httpEndpoint = e
+	return e, nil
+}
+
+// newEndpoint resolves the settings the HTTPS endpoint serves with and
+// builds its clients. An outputs list that can't be read only fails the
+// requests that need it, so the regenerating fallback still works without
+// one.
+func newEndpoint(ctx context.Context, metrics setlist.MetricsRecorder) (*endpoint, error) {
+	resolved, err := resolveSettings(ctx, Event{}, metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve settings: %w", err)
+	}
+	fallback, ttl, err := regenerateOptions(resolved)
+	if err != nil {
+		return nil, err
+	}
+	allowUnknownRegion, err := allowsUnknownRegion(resolved)
+	if err != nil {
+		return nil, err
+	}
+	clients, err := discoveryClients(ctx, resolved, metrics)
+	if err != nil {
+		return nil, err
+	}
+	outputs, outputsErr := outputsFor(ctx, clients.S3, resolved, resolved.Get(keyS3Bucket))
+
+	return &endpoint{
+		resolved:   resolved,
+		fallback:   fallback,
+		ttl:        ttl,
+		outputs:    outputs,
+		outputsErr: outputsErr,
+		store:      clients.S3,
+		cache:      served,
+		regenerate: func(ctx context.Context) (setlist.ConfigFile, error) {
+			slog.Info("Regenerating the served config")
+			return generateConfig(ctx, clients, resolved, allowUnknownRegion, metrics)
+		},
+		created: time.Now(),
+	}, nil
+}
+
+// load returns the config to serve in format: the stored one, or with the
+// fallback enabled, one generated by this execution environment when
+// nothing stored can be read.
+func (e *endpoint) load(ctx context.Context, format string) (servedConfig, error) {
+	stored, err := e.loadStored(ctx, format)
+	if err == nil || !e.fallback {
+		return stored, err
+	}
+	slog.Warn("Failed to read the stored config, regenerating it", "error", err.Error())
+	configFile, err := regenerated.get(ctx, e.ttl, e.regenerate)
+	if err != nil {
+		return servedConfig{}, err
+	}
+	body, err := render(configFile, format)
+	if err != nil {
+		return servedConfig{}, err
+	}
+	return servedConfig{body: body, hash: setlist.ContentHash(body)}, nil
+}
+
+// loadStored returns the config in format from the S3 output it is written
+// to, from the cache while it is fresh.
+func (e *endpoint) loadStored(ctx context.Context, format string) (servedConfig, error) {
+	if e.outputsErr != nil {
+		return servedConfig{}, e.outputsErr
+	}
+	o, ok := servedOutput(e.outputs, format)
+	if !ok {
+		return servedConfig{}, errNotStored
+	}
+	return e.cache.get(ctx, o.target(o.Key).String(), e.ttl, func(ctx context.Context) (servedConfig, error) {
+		return readStored(ctx, e.store, o.Bucket, o.Key)
+	})
+}
+
+// servedCache holds the stored configs the HTTPS endpoint serves, by where
+// they are stored, so a request that finds a fresh one makes no AWS calls.
+// Runs in the same execution environment replace an entry as they write
+// it; the rest are read again once older than SERVE_TTL.
+type servedCache struct {
+	mu      sync.Mutex
+	configs map[string]cachedConfig
+	now     func() time.Time
+}
+
+// cachedConfig is a served config and when it was read or written.
+type cachedConfig struct {
+	servedConfig
+	cached time.Time
+}
+
+// served is the stored configs this execution environment has read or
+// written.
+var served = &servedCache{configs: map[string]cachedConfig{}, now: time.Now}
+
+// get returns the config cached for location, reading it first when it is
+// missing or older than ttl. A stale config is still served if reading it
+// again fails.
+func (c *servedCache) get(ctx context.Context, location string, ttl time.Duration, read func(context.Context) (servedConfig, error)) (servedConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.configs[location]
+	if ok && c.now().Sub(cached.cached) < ttl {
+		return cached.servedConfig, nil
+	}
+
+	config, err := read(ctx)
+	if err != nil {
+		if !ok {
+			return servedConfig{}, err
+		}
+		slog.Warn("Failed to read the stored config, serving the cached one", "location", location, "error", err.Error(), "cached", cached.cached)
+		return cached.servedConfig, nil
+	}
+	c.configs[location] = cachedConfig{servedConfig: config, cached: c.now()}
+	return config, nil
+}
+
+// put caches the config a run has just written to location.
+func (c *servedCache) put(location string, config servedConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configs[location] = cachedConfig{servedConfig: config, cached: c.now()}
+}
+
+// cacheWritten caches a written artifact when the HTTPS endpoint serves it,
+// so this execution environment serves the new config without reading it.
+func cacheWritten(outputs []Output, a artifact) {
+	if o, ok := servedOutput(outputs, a.format); !ok || o.target(o.Key) != a.target {
+		return
+	}
+	served.put(a.target.String(), servedConfig{body: a.body, hash: setlist.ContentHash(a.body)})
+}
+
+// regenerateOptions returns whether requests may regenerate the config,
+// and how long a regenerated config is served.
+func regenerateOptions(resolved settings.Resolved) (bool, time.Duration, error) {
+	var fallback bool
+	if v := resolved.Get(keyServeRegenerate); v != "" {
+		var err error
+		if fallback, err = strconv.ParseBool(v); err != nil {
+			return false, 0, fmt.Errorf("invalid %s: %w", legacyEnv[keyServeRegenerate], err)
+		}
+	}
+	ttl := defaultServeTTL
+	if v := resolved.Get(keyServeTTL); v != "" {
+		var err error
+		if ttl, err = time.ParseDuration(v); err != nil {
+			return false, 0, fmt.Errorf("invalid %s: %w", legacyEnv[keyServeTTL], err)
+		}
+	}
+	return fallback, ttl, nil
+}
+
+// servedOutput returns the first S3 output holding the whole config, unsplit
+// and unfiltered, in format.
+func servedOutput(outputs []Output, format string) (Output, bool) {
+	for _, o := range outputs {
+		whole := o.Split == "" && o.IncludeAccounts == "" && o.ExcludeAccounts == "" && o.IncludePermissionSets == "" && o.ExcludePermissionSets == ""
+		if o.Sink == sinkS3 && o.Format == format && whole {
+			return o, true
+		}
+	}
+	return Output{}, false
+}
+
+// readStored downloads a stored config. Its hash comes from the setlist-hash
+// metadata it was uploaded with, or from its content when that is missing.
+func readStored(ctx context.Context, store objectStore, bucket, key string) (servedConfig, error) {
+	out, err := store.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return servedConfig{}, fmt.Errorf("failed to read s3://%s/%s: %w", bucket, key, err)
+	}
+	defer out.Body.Close()
+
+	body, err := io.ReadAll(out.Body)
+	if err != nil {
+		return servedConfig{}, fmt.Errorf("failed to read s3://%s/%s: %w", bucket, key, err)
+	}
+	hash := out.Metadata[hashMetadataKey]
+	if hash == "" {
+		hash = setlist.ContentHash(body)
+	}
+	return servedConfig{body: body, hash: hash}, nil
+}
+
+// serveConfig answers a request for the config file in the format the
+// format query parameter names, or defaultFormat. The ETag is the config's
+// content hash, which ignores the generation timestamp, so a regenerated but
+// identical config still matches a client's copy.
+func serveConfig(ctx context.Context, req httpRequest, defaultFormat string, load func(ctx context.Context, format string) (servedConfig, error)) httpResponse {
+	method := req.RequestContext.HTTP.Method
+	if method != http.MethodGet && method != http.MethodHead {
+		resp := textResponse(http.StatusMethodNotAllowed, "method not allowed")
+		resp.Headers["Allow"] = "GET, HEAD"
+		return resp
+	}
+
+	format := req.QueryStringParameters["format"]
+	if format == "" {
+		format = defaultFormat
+	}
+	if format == "" {
+		format = formatINI
+	}
+	if err := validateFormat(format); err != nil {
+		return textResponse(http.StatusBadRequest, err.Error())
+	}
+
+	config, err := load(ctx, format)
+	if errors.Is(err, errNotStored) {
+		return textResponse(http.StatusNotFound, fmt.Sprintf("config is not stored as %s", format))
+	}
+	if err != nil {
+		slog.Error("Failed to load config", "error", err.Error())
+		return textResponse(http.StatusServiceUnavailable, "config is not available")
+	}
+
+	etag := `"` + config.hash + `"`
+	headers := map[string]string{
+		"Content-Type":  contentTypes[format],
+		"ETag":          etag,
+		"Cache-Control": serveCacheControl,
+	}
+	if etagMatches(req.header("If-None-Match"), etag) {
+		return httpResponse{StatusCode: http.StatusNotModified, Headers: headers}
+	}
+	if method == http.MethodHead {
+		return httpResponse{StatusCode: http.StatusOK, Headers: headers}
+	}
+	return httpResponse{StatusCode: http.StatusOK, Headers: headers, Body: string(config.body)}
+}
+
+// etagMatches reports whether an If-None-Match header matches etag. It
+// compares weakly, as RFC 9110 asks for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// textResponse returns a plain text response, for errors.
+func textResponse(status int, message string) httpResponse {
+	return httpResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "text/plain"},
+		Body:       message + "\n",
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestParseHTTPRequest(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		wantMethod  string
+		wantFormat  string
+		wantIfMatch bool
+	}{
+		{fixture: "function_url_get.json", wantMethod: http.MethodGet, wantFormat: formatJSON, wantIfMatch: true},
+		{fixture: "http_api_head.json", wantMethod: http.MethodHead},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.fixture, func(t *testing.T) {
+			req, ok, err := parseHTTPRequest(readFixture(t, tc.fixture))
+			if err != nil || !ok {
+				t.Fatalf("parseHTTPRequest() = %v, %v", ok, err)
+			}
+			if req.RequestContext.HTTP.Method != tc.wantMethod {
+				t.Errorf("method = %q, want %q", req.RequestContext.HTTP.Method, tc.wantMethod)
+			}
+			if got := req.QueryStringParameters["format"]; got != tc.wantFormat {
+				t.Errorf("format = %q, want %q", got, tc.wantFormat)
+			}
+			if got := req.header("If-None-Match") != ""; got != tc.wantIfMatch {
+				t.Errorf("has If-None-Match = %v, want %v", got, tc.wantIfMatch)
+			}
+		})
+	}
+}
+
+func TestParseHTTPRequest_OtherEvents(t *testing.T) {
+	payloads := map[string][]byte{
+		"schedule": readFixture(t, "scheduled.json"),
+		"sqs":      readFixture(t, "sqs_changes.json"),
+		"direct":   []byte(`{"s3_key": "readonly.config"}`),
+		"empty":    nil,
+		"null":     []byte("null"),
+		"no http":  []byte(`{"requestContext": {"stage": "prod"}}`),
+	}
+	for name, payload := range payloads {
+		t.Run(name, func(t *testing.T) {
+			if _, ok, err := parseHTTPRequest(payload); ok || err != nil {
+				t.Errorf("parseHTTPRequest() = %v, %v; want not an HTTP request", ok, err)
+			}
+		})
+	}
+
+	if _, _, err := parseHTTPRequest([]byte(`{"requestContext": `)); err == nil {
+		t.Error("expected an error for malformed JSON")
+	}
+}
+
+func httpRequestFor(method string, query map[string]string, headers map[string]string) httpRequest {
+	var req httpRequest
+	req.RequestContext.HTTP.Method = method
+	req.QueryStringParameters = query
+	req.Headers = headers
+	return req
+}
+
+func TestServeConfig(t *testing.T) {
+	ini, err := render(testConfigFile(), formatINI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iniTag := `"` + setlist.ContentHash(ini) + `"`
+	load := renderedConfig(testConfigFile())
+
+	tests := []struct {
+		name        string
+		req         httpRequest
+		format      string
+		load        func(context.Context, string) (servedConfig, error)
+		wantStatus  int
+		wantType    string
+		wantBody    bool
+		wantETag    string
+		wantInBody  string
+		wantHeaders map[string]string
+	}{
+		{
+			name:       "ini by default",
+			req:        httpRequestFor(http.MethodGet, nil, nil),
+			wantStatus: http.StatusOK,
+			wantType:   "text/plain",
+			wantBody:   true,
+			wantETag:   iniTag,
+			wantInBody: "[profile prod-ReadOnly]",
+		},
+		{
+			name:       "json by query",
+			req:        httpRequestFor(http.MethodGet, map[string]string{"format": formatJSON}, nil),
+			wantStatus: http.StatusOK,
+			wantType:   "application/json",
+			wantBody:   true,
+			wantInBody: `"sso_session": "corp"`,
+		},
+		{
+			name:       "json by FORMAT",
+			req:        httpRequestFor(http.MethodGet, nil, nil),
+			format:     formatJSON,
+			wantStatus: http.StatusOK,
+			wantType:   "application/json",
+			wantBody:   true,
+		},
+		{
+			name:       "head",
+			req:        httpRequestFor(http.MethodHead, nil, nil),
+			wantStatus: http.StatusOK,
+			wantType:   "text/plain",
+			wantETag:   iniTag,
+		},
+		{
+			name:       "etag matches",
+			req:        httpRequestFor(http.MethodGet, nil, map[string]string{"if-none-match": iniTag}),
+			wantStatus: http.StatusNotModified,
+			wantETag:   iniTag,
+		},
+		{
+			name:       "weak etag in a list matches",
+			req:        httpRequestFor(http.MethodGet, nil, map[string]string{"If-None-Match": `"other", W/` + iniTag}),
+			wantStatus: http.StatusNotModified,
+			wantETag:   iniTag,
+		},
+		{
+			name:       "wildcard matches",
+			req:        httpRequestFor(http.MethodGet, nil, map[string]string{"if-none-match": "*"}),
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "stale etag",
+			req:        httpRequestFor(http.MethodGet, nil, map[string]string{"if-none-match": `"other"`}),
+			wantStatus: http.StatusOK,
+			wantBody:   true,
+			wantETag:   iniTag,
+		},
+		{
+			name:        "unsupported method",
+			req:         httpRequestFor(http.MethodPost, nil, nil),
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantBody:    true,
+			wantHeaders: map[string]string{"Allow": "GET, HEAD"},
+		},
+		{
+			name:       "unsupported format",
+			req:        httpRequestFor(http.MethodGet, map[string]string{"format": "yaml"}, nil),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   true,
+			wantInBody: `unsupported format "yaml"`,
+		},
+		{
+			name: "stored hash is the etag",
+			req:  httpRequestFor(http.MethodGet, nil, nil),
+			load: func(context.Context, string) (servedConfig, error) {
+				return servedConfig{body: ini, hash: "stored"}, nil
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   true,
+			wantETag:   `"stored"`,
+		},
+		{
+			name: "not stored",
+			req:  httpRequestFor(http.MethodGet, map[string]string{"format": formatJSON}, nil),
+			load: func(context.Context, string) (servedConfig, error) {
+				return servedConfig{}, errNotStored
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   true,
+			wantInBody: "not stored as json",
+		},
+		{
+			name: "loading fails",
+			req:  httpRequestFor(http.MethodGet, nil, nil),
+			load: func(context.Context, string) (servedConfig, error) {
+				return servedConfig{}, errors.New("access denied")
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := load
+			if tc.load != nil {
+				l = tc.load
+			}
+			resp := serveConfig(context.Background(), tc.req, tc.format, l)
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d, want %d (body %q)", resp.StatusCode, tc.wantStatus, resp.Body)
+			}
+			if tc.wantType != "" && resp.Headers["Content-Type"] != tc.wantType {
+				t.Errorf("Content-Type = %q, want %q", resp.Headers["Content-Type"], tc.wantType)
+			}
+			if (resp.Body != "") != tc.wantBody {
+				t.Errorf("body = %q, want a body: %v", resp.Body, tc.wantBody)
+			}
+			if tc.wantETag != "" && resp.Headers["ETag"] != tc.wantETag {
+				t.Errorf("ETag = %q, want %q", resp.Headers["ETag"], tc.wantETag)
+			}
+			if resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+				if resp.Headers["Cache-Control"] != serveCacheControl {
+					t.Errorf("Cache-Control = %q", resp.Headers["Cache-Control"])
+				}
+			}
+			if !strings.Contains(resp.Body, tc.wantInBody) {
+				t.Errorf("body does not contain %q: %q", tc.wantInBody, resp.Body)
+			}
+			for k, v := range tc.wantHeaders {
+				if resp.Headers[k] != v {
+					t.Errorf("%s = %q, want %q", k, resp.Headers[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestServeConfig_ResponseJSON(t *testing.T) {
+	resp := serveConfig(context.Background(), httpRequestFor(http.MethodGet, nil, nil), "", renderedConfig(testConfigFile()))
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"statusCode", "headers", "body"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("response is missing %q: %s", name, data)
+		}
+	}
+}
+
+func TestConfigCache_Get(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := &configCache{now: func() time.Time { return now }}
+
+	var generations int
+	var failWith error
+	generate := func(context.Context) (setlist.ConfigFile, error) {
+		if failWith != nil {
+			return setlist.ConfigFile{}, failWith
+		}
+		generations++
+		cf := testConfigFile()
+		cf.SessionName = "generation-" + strconv.Itoa(generations)
+		return cf, nil
+	}
+	get := func() (setlist.ConfigFile, error) {
+		return cache.get(context.Background(), 15*time.Minute, generate)
+	}
+
+	failWith = errors.New("access denied")
+	if _, err := get(); err == nil {
+		t.Fatal("expected the first failure to be returned")
+	}
+
+	failWith = nil
+	if cf, err := get(); err != nil || cf.SessionName != "generation-1" {
+		t.Fatalf("first get = %q, %v", cf.SessionName, err)
+	}
+
+	now = now.Add(10 * time.Minute)
+	if cf, _ := get(); cf.SessionName != "generation-1" || generations != 1 {
+		t.Errorf("fresh get = %q after %d generations, want the cached config", cf.SessionName, generations)
+	}
+
+	now = now.Add(10 * time.Minute)
+	if cf, _ := get(); cf.SessionName != "generation-2" {
+		t.Errorf("stale get = %q, want a regenerated config", cf.SessionName)
+	}
+
+	now = now.Add(time.Hour)
+	failWith = errors.New("throttled")
+	if cf, err := get(); err != nil || cf.SessionName != "generation-2" {
+		t.Errorf("failed regeneration = %q, %v; want the stale config", cf.SessionName, err)
+	}
+}
+
+func TestEndpoint_Load(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := "# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n"
+	second := "# Generated on: 2024-01-01T00:20:00 UTC\n[default]\nsso_session = corp2\n"
+
+	store := &stubObjectStore{get: existingObject(`"etag"`, "first-hash", first)}
+	e := &endpoint{
+		ttl:     15 * time.Minute,
+		outputs: []Output{{Key: "aws.config", Sink: sinkS3, Bucket: "configs", Format: formatINI}},
+		store:   store,
+		cache:   &servedCache{configs: map[string]cachedConfig{}, now: func() time.Time { return now }},
+	}
+	get := func(headers map[string]string) httpResponse {
+		return serveConfig(context.Background(), httpRequestFor(http.MethodGet, nil, headers), "", e.load)
+	}
+
+	if resp := get(nil); resp.StatusCode != http.StatusOK || resp.Body != first || len(store.gets) != 1 {
+		t.Fatalf("first request = %d %q after %d reads", resp.StatusCode, resp.Body, len(store.gets))
+	}
+
+	// A fresh config is served from memory without any AWS calls.
+	store.getErr = errors.New("unexpected read")
+	now = now.Add(10 * time.Minute)
+	if resp := get(nil); resp.StatusCode != http.StatusOK || resp.Body != first {
+		t.Errorf("cached request = %d %q", resp.StatusCode, resp.Body)
+	}
+	if resp := get(map[string]string{"If-None-Match": `"first-hash"`}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("revalidation = %d, want 304", resp.StatusCode)
+	}
+	if len(store.gets) != 1 {
+		t.Errorf("cache hits made %d reads, want none", len(store.gets)-1)
+	}
+
+	store.get, store.getErr = existingObject(`"etag2"`, "second-hash", second), nil
+	now = now.Add(10 * time.Minute)
+	if resp := get(nil); resp.Body != second || resp.Headers["ETag"] != `"second-hash"` || len(store.gets) != 2 {
+		t.Errorf("stale request = %q %q after %d reads, want the config read again", resp.Body, resp.Headers["ETag"], len(store.gets))
+	}
+
+	store.get, store.getErr = nil, &types.NoSuchKey{}
+	now = now.Add(time.Hour)
+	if resp := get(nil); resp.StatusCode != http.StatusOK || resp.Body != second {
+		t.Errorf("failed read = %d %q, want the stale config", resp.StatusCode, resp.Body)
+	}
+
+	e.outputs = nil
+	if resp := get(nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unstored format = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestCacheWritten(t *testing.T) {
+	saved := served
+	served = &servedCache{configs: map[string]cachedConfig{}, now: time.Now}
+	t.Cleanup(func() { served = saved })
+
+	outputs := []Output{
+		{Key: "aws/{ou}.config", Sink: sinkS3, Bucket: "configs", Format: formatINI, Split: splitOU},
+		{Key: "aws.config", Sink: sinkS3, Bucket: "configs", Format: formatINI},
+	}
+	body := []byte("# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n")
+	cacheWritten(outputs, artifact{target: outputs[0].target("aws/Root.config"), format: formatINI, body: []byte("[split]\n")})
+	cacheWritten(outputs, artifact{target: outputs[1].target("aws.config"), format: formatINI, body: body})
+	if len(served.configs) != 1 {
+		t.Errorf("cached %d configs, want only the served one", len(served.configs))
+	}
+
+	// A config this environment wrote is served without reading it back.
+	store := &stubObjectStore{getErr: errors.New("unexpected read")}
+	e := &endpoint{ttl: 15 * time.Minute, outputs: outputs, store: store, cache: served}
+	got, err := e.load(context.Background(), formatINI)
+	if err != nil || string(got.body) != string(body) || got.hash != setlist.ContentHash(body) || len(store.gets) != 0 {
+		t.Errorf("load() = %q, %q, %v after %d reads", got.body, got.hash, err, len(store.gets))
+	}
+}
+
+func TestServedOutput(t *testing.T) {
+	outputs := []Output{
+		{Key: "aws/{ou}.config", Sink: sinkS3, Bucket: "configs", Format: formatINI, Split: splitOU},
+		{Key: "readonly.config", Sink: sinkS3, Bucket: "configs", Format: formatINI, IncludePermissionSets: "ReadOnly"},
+		{Key: "/setlist/aws-config", Sink: sinkSSM, Format: formatINI},
+		{Key: "aws.config", Sink: sinkS3, Bucket: "configs", Format: formatINI},
+		{Key: "aws.json", Sink: sinkS3, Bucket: "configs", Format: formatJSON},
+	}
+
+	if o, ok := servedOutput(outputs, formatINI); !ok || o.Key != "aws.config" {
+		t.Errorf("servedOutput(ini) = %+v, %v", o, ok)
+	}
+	if o, ok := servedOutput(outputs, formatJSON); !ok || o.Key != "aws.json" {
+		t.Errorf("servedOutput(json) = %+v, %v", o, ok)
+	}
+	if o, ok := servedOutput(outputs[:3], formatINI); ok {
+		t.Errorf("servedOutput() = %+v; split, filtered and non-S3 outputs don't hold the whole config", o)
+	}
+}
+
+func TestReadStored(t *testing.T) {
+	body := "# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n"
+
+	store := &stubObjectStore{get: existingObject(`"etag"`, "stored-hash", body)}
+	got, err := readStored(context.Background(), store, "configs", "aws.config")
+	if err != nil || string(got.body) != body || got.hash != "stored-hash" {
+		t.Errorf("readStored() = %+v, %v", got, err)
+	}
+	if in := store.gets[0]; aws.ToString(in.Bucket) != "configs" || aws.ToString(in.Key) != "aws.config" {
+		t.Errorf("get input = %+v", in)
+	}
+
+	// Objects uploaded without the metadata are hashed as they are read.
+	store = &stubObjectStore{get: existingObject(`"etag"`, "", body)}
+	if got, _ := readStored(context.Background(), store, "configs", "aws.config"); got.hash != setlist.ContentHash([]byte(body)) {
+		t.Errorf("hash = %q, want the content hash", got.hash)
+	}
+
+	store = &stubObjectStore{getErr: &types.NoSuchKey{}}
+	if _, err := readStored(context.Background(), store, "configs", "aws.config"); err == nil || !strings.Contains(err.Error(), "s3://configs/aws.config") {
+		t.Errorf("expected a read error naming the object, got %v", err)
+	}
+}
+
+func TestRegenerateOptions(t *testing.T) {
+	fallback, ttl, err := regenerateOptions(loadSettings(Event{}, lookupFrom(nil)))
+	if err != nil || fallback || ttl != defaultServeTTL {
+		t.Errorf("defaults = %v, %v, %v", fallback, ttl, err)
+	}
+
+	fallback, ttl, err = regenerateOptions(loadSettings(Event{}, lookupFrom(map[string]string{"SERVE_REGENERATE": "true", "SERVE_TTL": "5m"})))
+	if err != nil || !fallback || ttl != 5*time.Minute {
+		t.Errorf("set = %v, %v, %v", fallback, ttl, err)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"regenerate": {"SERVE_REGENERATE": "sometimes"},
+		"ttl":        {"SERVE_TTL": "soon"},
+	} {
+		if _, _, err := regenerateOptions(loadSettings(Event{}, lookupFrom(env))); err == nil {
+			t.Errorf("invalid %s accepted", name)
+		}
+	}
+}
+
+// renderedConfig loads configFile rendered in the requested format.
+func renderedConfig(configFile setlist.ConfigFile) func(context.Context, string) (servedConfig, error) {
+	return func(ctx context.Context, format string) (servedConfig, error) {
+		body, err := render(configFile, format)
+		if err != nil {
+			return servedConfig{}, err
+		}
+		return servedConfig{body: body, hash: setlist.ContentHash(body)}, nil
+	}
+}
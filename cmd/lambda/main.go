@@ -1,99 +1,426 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/organizations"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
 )
 
-func handleRequest(ctx context.Context) error {
-	ssoSession := os.Getenv("SSO_SESSION")
-	ssoRegion := os.Getenv("SSO_REGION")
-	s3Bucket := os.Getenv("S3_BUCKET")
-	s3Key := os.Getenv("S3_KEY")
-	ssoFriendlyName := os.Getenv("SSO_FRIENDLY_NAME")
-	nicknameMapping := os.Getenv("NICKNAME_MAPPING")
-	includeAccounts := os.Getenv("INCLUDE_ACCOUNTS")
-	excludeAccounts := os.Getenv("EXCLUDE_ACCOUNTS")
-	includePermissionSets := os.Getenv("INCLUDE_PERMISSION_SETS")
-	excludePermissionSets := os.Getenv("EXCLUDE_PERMISSION_SETS")
+// Option keys used only by the Lambda.
+const (
+	keyS3Bucket = "s3-bucket"
+	keyS3Key    = "s3-key"
+)
+
+// legacyEnv maps option keys to the environment variables the function has
+// always read. The matching SETLIST_* variables take precedence.
+var legacyEnv = map[string]string{
+	"sso-session":             "SSO_SESSION",
+	"sso-region":              "SSO_REGION",
+	keyS3Bucket:               "S3_BUCKET",
+	keyS3Key:                  "S3_KEY",
+	"sso-friendly-name":       "SSO_FRIENDLY_NAME",
+	"sso-start-url":           "SSO_START_URL",
+	"mapping":                 "NICKNAME_MAPPING",
+	"include-accounts":        "INCLUDE_ACCOUNTS",
+	"exclude-accounts":        "EXCLUDE_ACCOUNTS",
+	"include-permission-sets": "INCLUDE_PERMISSION_SETS",
+	"exclude-permission-sets": "EXCLUDE_PERMISSION_SETS",
+	"filter":                  "FILTER",
+	"instance-arn":            "INSTANCE_ARN",
+	"assume-role-arn":         "ASSUME_ROLE_ARN",
+	"external-id":             "EXTERNAL_ID",
+	"role-session-name":       "ROLE_SESSION_NAME",
+	"identity-store-id":       "IDENTITY_STORE_ID",
+	keySNSTopicArn:            "SNS_TOPIC_ARN",
+	keyEventBusName:           "EVENT_BUS_NAME",
+	keyFormat:                 "FORMAT",
+	keyOutputs:                "OUTPUTS",
+	keyGitTokenSecret:         "GIT_TOKEN_SECRET",
+	keyGitUsername:            "GIT_USERNAME",
+	keyServeRegenerate:        "SERVE_REGENERATE",
+	keyServeTTL:               "SERVE_TTL",
+	keySigningKeyID:           "SIGNING_KEY_ID",
+}
+
+// loadSettings resolves the function's options from the invocation event,
+// then SETLIST_* variables, then the legacy variable names, then the config
+// document's layers, if any.
+func loadSettings(event Event, lookup func(string) (string, bool), documentLayers ...settings.Layer) settings.Resolved {
+	keys := append(settings.Keys(), keyS3Bucket, keyS3Key, keySNSTopicArn, keyEventBusName, keyFormat, keyOutputs, keyGitTokenSecret, keyGitUsername, keyServeRegenerate, keyServeTTL, keySigningKeyID)
+	layers := []settings.Layer{
+		event.Layer(),
+		settings.EnvLayer(keys, lookup),
+		settings.NamedEnvLayer(legacyEnv, lookup),
+	}
+	return settings.Resolve(append(layers, documentLayers...)...)
+}
+
+// endpointsFrom returns the endpoint overrides in resolved.
+func endpointsFrom(resolved settings.Resolved) setlist.Endpoints {
+	return setlist.Endpoints{
+		Default:       resolved.Get("endpoint-url"),
+		Organizations: resolved.Get("organizations-endpoint-url"),
+		SSOAdmin:      resolved.Get("sso-admin-endpoint-url"),
+		S3:            resolved.Get("s3-endpoint-url"),
+		STS:           resolved.Get("sts-endpoint-url"),
+	}
+}
+
+// requireSetting returns the value of key, or an error naming both
+// variables and the event field that can set it.
+func requireSetting(resolved settings.Resolved, key string) (string, error) {
+	if v := resolved.Get(key); v != "" {
+		return v, nil
+	}
+	return "", fmt.Errorf("%s (or %s) environment variable or %s event field is required", legacyEnv[key], settings.EnvName(key), strings.ToLower(legacyEnv[key]))
+}
+
+// handle routes an invocation: HTTPS requests are served the config, and
+// every other event runs the generator.
+func handle(ctx context.Context, payload json.RawMessage) (any, error) {
+	req, ok, err := parseHTTPRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return handleHTTP(ctx, req), nil
+	}
+	return handleRequest(ctx, payload)
+}
+
+// handleRequest runs one invocation and writes its metrics to stdout, as
+// EMF, however it ends.
+func handleRequest(ctx context.Context, payload json.RawMessage) (Response, error) {
+	start := time.Now()
+	metrics := newEMFRecorder(os.LookupEnv)
+	response, err := run(ctx, payload, metrics)
+	if flushErr := metrics.Flush(os.Stdout, time.Since(start), time.Now()); flushErr != nil {
+		slog.Warn("Failed to write metrics", "error", flushErr.Error())
+	}
+	return response, err
+}
+
+// run generates the config an invocation asks for and writes its outputs.
+func run(ctx context.Context, payload json.RawMessage, metrics *emfRecorder) (Response, error) {
+	inv, err := parseInvocation(payload)
+	if err != nil {
+		return Response{}, err
+	}
+	if inv.skip {
+		slog.Info("No relevant changes, skipping regeneration")
+		return Response{Status: StatusSkipped, Trigger: inv.trigger}, nil
+	}
+	slog.Info("Invoked", "trigger", inv.trigger, "changes", inv.changes)
 
-	if ssoSession == "" {
-		return fmt.Errorf("SSO_SESSION environment variable is required")
+	event := inv.event
+	resolved, err := resolveSettings(ctx, event, metrics)
+	if err != nil {
+		return Response{}, err
+	}
+
+	allowUnknownRegion, err := allowsUnknownRegion(resolved)
+	if err != nil {
+		return Response{}, err
+	}
+	if err := event.Validate(allowUnknownRegion); err != nil {
+		return Response{}, err
+	}
+
+	clients, err := discoveryClients(ctx, resolved, metrics)
+	if err != nil {
+		return Response{}, err
+	}
+
+	outputs, err := outputsFor(ctx, clients.S3, resolved, resolved.Get(keyS3Bucket))
+	if err != nil {
+		return Response{}, err
+	}
+
+	configFile, err := generateConfig(ctx, clients, resolved, allowUnknownRegion, metrics)
+	if err != nil {
+		return Response{}, err
+	}
+
+	var ouPath ouPathFunc
+	if needsOUPaths(outputs) {
+		ouPath = setlist.NewOUPathResolver(clients.Organizations).Path
 	}
-	if ssoRegion == "" {
-		return fmt.Errorf("SSO_REGION environment variable is required")
+
+	artifacts, err := buildArtifacts(ctx, configFile, outputs, ouPath)
+	if err != nil {
+		return Response{}, err
 	}
-	if s3Bucket == "" {
-		return fmt.Errorf("S3_BUCKET environment variable is required")
+
+	response := Response{
+		Status:       StatusUnchanged,
+		Trigger:      inv.trigger,
+		Changes:      inv.changes,
+		ProfileCount: len(configFile.Profiles),
 	}
-	if s3Key == "" {
-		return fmt.Errorf("S3_KEY environment variable is required")
+	notifiers := publishers(resolved, clients)
+	sinks := outputSinks(resolved, clients)
+	signer := signerFor(resolved, clients)
+
+	// Every artifact is attempted even if an earlier one fails, so one bad
+	// key doesn't hold back the rest.
+	var errs []error
+	metrics.Count(metricOutputsChanged, 0)
+	metrics.Count(metricOutputsUnchanged, 0)
+	metrics.Count(metricOutputsFailed, 0)
+	for _, a := range artifacts {
+		// Outputs are signed before they are written, so an output that
+		// can't be signed is never published without its signature.
+		var sig target
+		if signer != nil {
+			if sig, err = signIfChanged(ctx, sinks, signer, a); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", a.target, err))
+				metrics.Count(metricOutputsFailed, 1)
+				continue
+			}
+		}
+
+		result, err := sinks.Write(ctx, a)
+		if err != nil {
+			errs = append(errs, err)
+			metrics.Count(metricOutputsFailed, 1)
+			continue
+		}
+		if signer != nil {
+			result.Signature = sig.String()
+		}
+		cacheWritten(outputs, a)
+		response.add(result)
+		if result.Changed {
+			metrics.Count(metricOutputsChanged, 1)
+		} else {
+			metrics.Count(metricOutputsUnchanged, 1)
+		}
+
+		slog.Info("Config file processed", "location", result.Location, "status", result.Status, "profiles", result.ProfileCount)
+
+		// The upload has already happened, so a failed notification can't
+		// be retried by a later run; surface it as the invocation's error.
+		if err := notifyChange(ctx, notifiers, result, a.body); err != nil {
+			errs = append(errs, fmt.Errorf("failed to publish change notification for %s: %w", a.key, err))
+		}
+	}
+
+	deleted, err := pruneSplits(ctx, sinks, outputs, artifacts)
+	response.delete(deleted)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return response, errors.Join(errs...)
+}
+
+// resolveSettings resolves the function's options for an invocation,
+// layering in the config document if one is configured. The document is
+// read once per execution environment, or again once CONFIG_TTL passes.
+func resolveSettings(ctx context.Context, event Event, metrics setlist.MetricsRecorder) (settings.Resolved, error) {
+	resolved := loadSettings(event, os.LookupEnv)
+
+	location, err := configLocation(os.LookupEnv)
+	if err != nil || location == "" {
+		return resolved, err
+	}
+	ttl, err := configTTL(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := documents.get(ctx, ttl, func(ctx context.Context) ([]settings.Layer, error) {
+		// The document is read with the function's own role and region,
+		// before the settings it holds are known.
+		endpoints := endpointsFrom(resolved)
+		if err := endpoints.Validate(); err != nil {
+			return nil, err
+		}
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+		}
+		clients := setlist.NewClients(setlist.WithMetrics(cfg, metrics), endpoints)
+
+		slog.Info("Reading config file", "location", location)
+		return configLayers(ctx, &settings.Loader{S3Client: clients.S3, SSMClient: clients.SSM}, location, os.LookupEnv)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadSettings(event, os.LookupEnv, layers...), nil
+}
+
+// allowsUnknownRegion reports whether SETLIST_ALLOW_UNKNOWN_REGION is set.
+func allowsUnknownRegion(resolved settings.Resolved) (bool, error) {
+	v := resolved.Get("allow-unknown-region")
+	if v == "" {
+		return false, nil
+	}
+	allow, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", settings.EnvName("allow-unknown-region"), err)
+	}
+	return allow, nil
+}
+
+// discoveryClients returns clients in the SSO region. Organizations and SSO
+// Admin use the role in ASSUME_ROLE_ARN when it is set; every other client
+// keeps the function's own role, so outputs, notifications and signatures
+// stay in the account the function runs in.
+func discoveryClients(ctx context.Context, resolved settings.Resolved, metrics setlist.MetricsRecorder) (setlist.Clients, error) {
+	ssoRegion, err := requireSetting(resolved, "sso-region")
+	if err != nil {
+		return setlist.Clients{}, err
+	}
+	endpoints := endpointsFrom(resolved)
+	if err := endpoints.Validate(); err != nil {
+		return setlist.Clients{}, err
 	}
 
 	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(ssoRegion))
 	if err != nil {
-		return fmt.Errorf("failed to load AWS configuration: %w", err)
+		return setlist.Clients{}, fmt.Errorf("failed to load AWS configuration: %w", err)
 	}
+	cfg = setlist.WithMetrics(cfg, metrics)
 
-	ssoClient := ssoadmin.NewFromConfig(cfg)
-	orgClient := organizations.NewFromConfig(cfg)
+	own := setlist.NewClients(cfg, endpoints)
+
+	// The function's own role discovers nothing itself when it runs outside
+	// the management account; it only needs sts:AssumeRole.
+	roleArn := resolved.Get("assume-role-arn")
+	if roleArn == "" {
+		return own, nil
+	}
+	slog.Info("Assuming role", "role_arn", roleArn)
+	assumed, err := setlist.AssumeRole(cfg, own.STS, setlist.AssumeRoleInput{
+		RoleArn:         roleArn,
+		ExternalId:      resolved.Get("external-id"),
+		RoleSessionName: resolved.Get("role-session-name"),
+	})
+	if err != nil {
+		return setlist.Clients{}, fmt.Errorf("failed to assume role %s: %w", roleArn, err)
+	}
+	return withDiscovery(own, setlist.NewClients(assumed, endpoints)), nil
+}
+
+// withDiscovery returns own with its Organizations and SSO Admin clients
+// replaced by discovery's.
+func withDiscovery(own, discovery setlist.Clients) setlist.Clients {
+	own.Organizations = discovery.Organizations
+	own.SSOAdmin = discovery.SSOAdmin
+	return own
+}
+
+// generateConfig discovers the accounts and permission sets and generates
+// the config file the resolved settings describe.
+func generateConfig(ctx context.Context, clients setlist.Clients, resolved settings.Resolved, allowUnknownRegion bool, metrics setlist.MetricsRecorder) (setlist.ConfigFile, error) {
+	ssoSession, err := requireSetting(resolved, "sso-session")
+	if err != nil {
+		return setlist.ConfigFile{}, err
+	}
 
 	slog.Info("Generating AWS config file")
 	configFile, err := setlist.Generate(ctx, setlist.GenerateInput{
-		SSOClient:             ssoClient,
-		OrgClient:             orgClient,
+		SSOClient: clients.SSOAdmin,
+		OrgClient: clients.Organizations,
+		Instance: setlist.InstanceSelector{
+			InstanceArn:     resolved.Get("instance-arn"),
+			IdentityStoreId: resolved.Get("identity-store-id"),
+		},
 		SessionName:           ssoSession,
-		Region:                ssoRegion,
-		FriendlyName:          ssoFriendlyName,
-		NicknameMapping:       nicknameMapping,
-		IncludeAccounts:       includeAccounts,
-		ExcludeAccounts:       excludeAccounts,
-		IncludePermissionSets: includePermissionSets,
-		ExcludePermissionSets: excludePermissionSets,
+		Region:                resolved.Get("sso-region"),
+		AllowUnknownRegion:    allowUnknownRegion,
+		FriendlyName:          resolved.Get("sso-friendly-name"),
+		StartURL:              resolved.Get("sso-start-url"),
+		NicknameMapping:       resolved.Get("mapping"),
+		IncludeAccounts:       resolved.Get("include-accounts"),
+		ExcludeAccounts:       resolved.Get("exclude-accounts"),
+		IncludePermissionSets: resolved.Get("include-permission-sets"),
+		ExcludePermissionSets: resolved.Get("exclude-permission-sets"),
+		Filter:                resolved.Get("filter"),
+		Metrics:               metrics,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to generate config: %w", err)
+		return setlist.ConfigFile{}, fmt.Errorf("failed to generate config: %w", err)
 	}
+	return configFile, nil
+}
 
-	builder := setlist.NewFileBuilder(configFile)
-	payload, err := builder.Build()
-	if err != nil {
-		return fmt.Errorf("failed to build config file: %w", err)
+// outputsFor returns the outputs to write: the OUTPUTS list, or a single
+// output at S3_KEY in S3_BUCKET, in FORMAT, when it is unset. An event that names its own
+// s3_key or format always writes that single output, so variants can be
+// run without editing the list.
+func outputsFor(ctx context.Context, store objectStore, resolved settings.Resolved, bucket string) ([]Output, error) {
+	fromEvent := resolved[keyS3Key].Source == sourceEvent || resolved[keyFormat].Source == sourceEvent
+	if source := resolved.Get(keyOutputs); source != "" && !fromEvent {
+		return loadOutputs(ctx, store, source, bucket)
 	}
 
-	var buf bytes.Buffer
-	if _, err := payload.WriteTo(&buf); err != nil {
-		return fmt.Errorf("failed to write config to buffer: %w", err)
+	if bucket == "" {
+		_, err := requireSetting(resolved, keyS3Bucket)
+		return nil, err
+	}
+	key, err := requireSetting(resolved, keyS3Key)
+	if err != nil {
+		return nil, err
 	}
 
-	s3Client := s3.NewFromConfig(cfg)
-	body := bytes.NewReader(buf.Bytes())
-	contentType := "text/plain"
+	output := Output{Key: key, Sink: sinkS3, Bucket: bucket, Format: formatINI}
+	if v := resolved.Get(keyFormat); v != "" {
+		output.Format = v
+	}
+	if err := output.validate(); err != nil {
+		return nil, fmt.Errorf("invalid output: %w", err)
+	}
+	return []Output{output}, nil
+}
 
-	slog.Info("Uploading config to S3", "bucket", s3Bucket, "key", s3Key)
-	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      &s3Bucket,
-		Key:         &s3Key,
-		Body:        body,
-		ContentType: &contentType,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to upload to S3: %w", err)
+// publishers returns a publisher for each notification target configured.
+func publishers(resolved settings.Resolved, clients setlist.Clients) []publisher {
+	var ps []publisher
+	if topicArn := resolved.Get(keySNSTopicArn); topicArn != "" {
+		ps = append(ps, snsPublisher{client: clients.SNS, topicArn: topicArn})
+	}
+	if busName := resolved.Get(keyEventBusName); busName != "" {
+		ps = append(ps, eventBridgePublisher{client: clients.EventBridge, busName: busName})
 	}
+	return ps
+}
 
-	slog.Info("Config file uploaded successfully", "bucket", s3Bucket, "key", s3Key)
-	return nil
+// outputSinks returns a sink for each kind of output. Git credentials are
+// only read when a git output is first written, and S3 outputs only
+// download the object they replace when a notification will diff it.
+func outputSinks(resolved settings.Resolved, clients setlist.Clients) sinkSet {
+	return sinkSet{
+		sinkS3:             s3Sink{store: clients.S3, readPrevious: len(publishers(resolved, clients)) > 0},
+		sinkSSM:            parameterSink{store: clients.SSM},
+		sinkSecretsManager: secretSink{store: clients.SecretsManager},
+		sinkGit: newGitSink(func(ctx context.Context, repository, branch string) (gitRepository, error) {
+			auth, err := gitAuth(ctx, clients.SecretsManager, resolved)
+			if err != nil {
+				return nil, err
+			}
+			repo, err := cloneRepository(ctx, repository, branch, auth)
+			if err != nil {
+				return nil, err
+			}
+			return repo, nil
+		}),
+	}
 }
 
 func main() {
@@ -101,5 +428,5 @@ func main() {
 		Level: slog.LevelInfo,
 	})))
 
-	lambda.Start(handleRequest)
+	lambda.Start(handle)
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/scottbrown/setlist"
+)
+
+// Metric names recorded only by the Lambda; the rest come from the library.
+const (
+	metricDuration         = "Duration"
+	metricOutputsChanged   = "OutputsChanged"
+	metricOutputsUnchanged = "OutputsUnchanged"
+	metricOutputsFailed    = "OutputsFailed"
+)
+
+// defaultMetricsNamespace is the CloudWatch namespace metrics are published
+// under unless METRICS_NAMESPACE names another.
+const defaultMetricsNamespace = "Setlist"
+
+// emfRecorder collects a run's counts and writes them as one CloudWatch
+// Embedded Metric Format log line, which CloudWatch Logs turns into metrics
+// without any API calls from the function.
+type emfRecorder struct {
+	namespace  string
+	dimensions map[string]string
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// newEMFRecorder returns a recorder for one invocation. Its metrics carry
+// the function's name as a dimension when running in Lambda. API calls and
+// throttles start at zero so that every run reports them.
+func newEMFRecorder(lookup func(string) (string, bool)) *emfRecorder {
+	r := &emfRecorder{
+		namespace:  defaultMetricsNamespace,
+		dimensions: map[string]string{},
+		counts:     map[string]int{setlist.MetricAPICalls: 0, setlist.MetricThrottles: 0},
+	}
+	if v, ok := lookup("METRICS_NAMESPACE"); ok && v != "" {
+		r.namespace = v
+	}
+	if v, ok := lookup("AWS_LAMBDA_FUNCTION_NAME"); ok && v != "" {
+		r.dimensions["FunctionName"] = v
+	}
+	return r
+}
+
+func (r *emfRecorder) Count(name string, value int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[name] += value
+}
+
+// emfMetric declares one metric in an EMF document.
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// Flush writes the counts recorded so far, and the run's duration, as a
+// single EMF document on one line.
+func (r *emfRecorder) Flush(w io.Writer, duration time.Duration, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc := map[string]any{}
+	for k, v := range r.dimensions {
+		doc[k] = v
+	}
+
+	metrics := []emfMetric{{Name: metricDuration, Unit: "Milliseconds"}}
+	doc[metricDuration] = duration.Milliseconds()
+	for _, name := range slices.Sorted(maps.Keys(r.counts)) {
+		metrics = append(metrics, emfMetric{Name: name, Unit: "Count"})
+		doc[name] = r.counts[name]
+	}
+
+	dimensions := slices.Sorted(maps.Keys(r.dimensions))
+	if dimensions == nil {
+		dimensions = []string{}
+	}
+	doc["_aws"] = map[string]any{
+		"Timestamp": now.UnixMilli(),
+		"CloudWatchMetrics": []map[string]any{{
+			"Namespace":  r.namespace,
+			"Dimensions": [][]string{dimensions},
+			"Metrics":    metrics,
+		}},
+	}
+
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// requestRecorder passes counts on to the recorder of the invocation being
+// handled, if any. Clients built once per execution environment count
+// through one, so their calls still land in each invocation's metrics;
+// Lambda hands an environment one invocation at a time.
+type requestRecorder struct {
+	mu       sync.Mutex
+	recorder setlist.MetricsRecorder
+}
+
+// set makes recorder the one counts are passed on to.
+func (r *requestRecorder) set(recorder setlist.MetricsRecorder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorder = recorder
+}
+
+func (r *requestRecorder) Count(name string, value int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recorder != nil {
+		r.recorder.Count(name, value)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scottbrown/setlist"
+)
+
+func TestEMFRecorder_Flush(t *testing.T) {
+	env := map[string]string{
+		"METRICS_NAMESPACE":        "Platform/Setlist",
+		"AWS_LAMBDA_FUNCTION_NAME": "setlist",
+	}
+	r := newEMFRecorder(lookupFrom(env))
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Count(setlist.MetricAPICalls, 1)
+		}()
+	}
+	wg.Wait()
+	r.Count(setlist.MetricProfilesGenerated, 7)
+	r.Count(metricOutputsChanged, 1)
+
+	var buf bytes.Buffer
+	now := time.UnixMilli(1700000000000)
+	if err := r.Flush(&buf, 1500*time.Millisecond, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Count(buf.String(), "\n") != 1 || !strings.HasSuffix(buf.String(), "\n") {
+		t.Errorf("want a single line, got %q", buf.String())
+	}
+
+	var doc struct {
+		AWS struct {
+			Timestamp         int64
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []emfMetric
+			}
+		} `json:"_aws"`
+		FunctionName      string
+		Duration          int64
+		APICalls          int
+		Throttles         int
+		ProfilesGenerated int
+		OutputsChanged    int
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	if doc.AWS.Timestamp != now.UnixMilli() || len(doc.AWS.CloudWatchMetrics) != 1 {
+		t.Fatalf("_aws = %+v", doc.AWS)
+	}
+	directive := doc.AWS.CloudWatchMetrics[0]
+	if directive.Namespace != "Platform/Setlist" {
+		t.Errorf("Namespace = %q", directive.Namespace)
+	}
+	if !reflect.DeepEqual(directive.Dimensions, [][]string{{"FunctionName"}}) || doc.FunctionName != "setlist" {
+		t.Errorf("Dimensions = %v, FunctionName = %q", directive.Dimensions, doc.FunctionName)
+	}
+
+	want := []emfMetric{
+		{Name: metricDuration, Unit: "Milliseconds"},
+		{Name: setlist.MetricAPICalls, Unit: "Count"},
+		{Name: metricOutputsChanged, Unit: "Count"},
+		{Name: setlist.MetricProfilesGenerated, Unit: "Count"},
+		{Name: setlist.MetricThrottles, Unit: "Count"},
+	}
+	if !reflect.DeepEqual(directive.Metrics, want) {
+		t.Errorf("Metrics = %+v, want %+v", directive.Metrics, want)
+	}
+	if doc.Duration != 1500 || doc.APICalls != 10 || doc.Throttles != 0 || doc.ProfilesGenerated != 7 || doc.OutputsChanged != 1 {
+		t.Errorf("values = %+v", doc)
+	}
+}
+
+func TestEMFRecorder_Defaults(t *testing.T) {
+	r := newEMFRecorder(lookupFrom(nil))
+
+	var buf bytes.Buffer
+	if err := r.Flush(&buf, 0, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	directive := doc["_aws"].(map[string]any)["CloudWatchMetrics"].([]any)[0].(map[string]any)
+	if directive["Namespace"] != defaultMetricsNamespace {
+		t.Errorf("Namespace = %v", directive["Namespace"])
+	}
+	// A dimension set with no dimensions is still required by EMF.
+	if !reflect.DeepEqual(directive["Dimensions"], []any{[]any{}}) {
+		t.Errorf("Dimensions = %v", directive["Dimensions"])
+	}
+	if _, ok := doc["FunctionName"]; ok {
+		t.Error("FunctionName set outside Lambda")
+	}
+}
+
+func TestRequestRecorder(t *testing.T) {
+	var r requestRecorder
+	r.Count(setlist.MetricAPICalls, 1)
+
+	first := newEMFRecorder(func(string) (string, bool) { return "", false })
+	r.set(first)
+	r.Count(setlist.MetricAPICalls, 2)
+
+	second := newEMFRecorder(func(string) (string, bool) { return "", false })
+	r.set(second)
+	r.Count(setlist.MetricAPICalls, 3)
+
+	if got := first.counts[setlist.MetricAPICalls]; got != 2 {
+		t.Errorf("first invocation counted %d calls, want 2", got)
+	}
+	if got := second.counts[setlist.MetricAPICalls]; got != 3 {
+		t.Errorf("second invocation counted %d calls, want 3", got)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// Option keys naming where change notifications are published.
+const (
+	keySNSTopicArn  = "sns-topic-arn"
+	keyEventBusName = "event-bus-name"
+)
+
+// EventBridge envelope fields of a change notification.
+const (
+	eventSource     = "setlist"
+	eventDetailType = "Setlist Config Changed"
+)
+
+// maxEventSize is the most bytes a change notification marshals to. SNS
+// messages and EventBridge entries are limited to 256 KiB, and the rest is
+// left for the SNS subject and the EventBridge envelope.
+const maxEventSize = 240 * 1024
+
+// ChangeEvent describes a written config file whose contents changed.
+// Bucket is only set for the s3 sink. Truncated means Added and Removed
+// were cut short to fit the notification; the counts are always complete.
+type ChangeEvent struct {
+	Sink         string   `json:"sink"`
+	Location     string   `json:"location"`
+	Bucket       string   `json:"bucket,omitempty"`
+	Key          string   `json:"key"`
+	Hash         string   `json:"hash"`
+	AddedCount   int      `json:"added_count"`
+	RemovedCount int      `json:"removed_count"`
+	Added        []string `json:"added"`
+	Removed      []string `json:"removed"`
+	Truncated    bool     `json:"truncated,omitempty"`
+}
+
+// truncate cuts Added, then Removed, short so the event marshals to at most
+// limit bytes.
+func (e *ChangeEvent) truncate(limit int) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(data) <= limit {
+		return nil
+	}
+
+	added, removed := e.Added, e.Removed
+	e.Added, e.Removed, e.Truncated = []string{}, []string{}, true
+	data, err = json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	size := len(data)
+	add := func(list []string, name string) ([]string, bool) {
+		quoted, _ := json.Marshal(name)
+		cost := len(quoted)
+		if len(list) > 0 {
+			cost++ // the comma before it
+		}
+		if size+cost > limit {
+			return list, false
+		}
+		size += cost
+		return append(list, name), true
+	}
+	var ok bool
+	for _, name := range added {
+		if e.Added, ok = add(e.Added, name); !ok {
+			return nil
+		}
+	}
+	for _, name := range removed {
+		if e.Removed, ok = add(e.Removed, name); !ok {
+			return nil
+		}
+	}
+	return nil
+}
+
+// publisher sends a change notification somewhere.
+type publisher interface {
+	Publish(ctx context.Context, event ChangeEvent) error
+}
+
+// snsAPI is the subset of the SNS API the function publishes with.
+type snsAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// eventBridgeAPI is the subset of the EventBridge API the function
+// publishes with.
+type eventBridgeAPI interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+// snsPublisher publishes change notifications as JSON messages to an SNS
+// topic.
+type snsPublisher struct {
+	client   snsAPI
+	topicArn string
+}
+
+func (p snsPublisher) Publish(ctx context.Context, event ChangeEvent) error {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(p.topicArn),
+		Subject:  aws.String(eventDetailType),
+		Message:  aws.String(string(message)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to SNS topic %s: %w", p.topicArn, err)
+	}
+	return nil
+}
+
+// eventBridgePublisher puts change notifications on an EventBridge bus.
+type eventBridgePublisher struct {
+	client  eventBridgeAPI
+	busName string
+}
+
+func (p eventBridgePublisher) Publish(ctx context.Context, event ChangeEvent) error {
+	detail, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	out, err := p.client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{{
+			EventBusName: aws.String(p.busName),
+			Source:       aws.String(eventSource),
+			DetailType:   aws.String(eventDetailType),
+			Detail:       aws.String(string(detail)),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put event on bus %s: %w", p.busName, err)
+	}
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		entry := out.Entries[0]
+		return fmt.Errorf("failed to put event on bus %s: %s: %s", p.busName, aws.ToString(entry.ErrorCode), aws.ToString(entry.ErrorMessage))
+	}
+	return nil
+}
+
+// diffProfiles returns the profiles in current but not previous, and those
+// in previous but not current, each sorted. Both are rendered in format.
+// The [default] section is not a profile of its own and is ignored. An
+// empty previous means every profile was added.
+func diffProfiles(previous, current []byte, format string) (added, removed []string, err error) {
+	before, err := profileNames(previous, format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse previous config: %w", err)
+	}
+	after, err := profileNames(current, format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse generated config: %w", err)
+	}
+
+	added, removed = []string{}, []string{}
+	for name := range after {
+		if !before[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			removed = append(removed, name)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed, nil
+}
+
+// notifyChange publishes the profile diff of a write to every publisher.
+// Nothing is sent when the write was skipped. Every publisher is tried
+// even if an earlier one fails.
+func notifyChange(ctx context.Context, publishers []publisher, result Result, current []byte) error {
+	if result.Status != StatusChanged || len(publishers) == 0 {
+		return nil
+	}
+
+	added, removed, err := diffProfiles(result.previous, current, result.Format)
+	if err != nil {
+		return err
+	}
+
+	event := ChangeEvent{
+		Sink:         result.Sink,
+		Location:     result.Location,
+		Bucket:       result.Bucket,
+		Key:          result.Key,
+		Hash:         result.Hash,
+		AddedCount:   len(added),
+		RemovedCount: len(removed),
+		Added:        added,
+		Removed:      removed,
+	}
+	if err := event.truncate(maxEventSize); err != nil {
+		return err
+	}
+
+	slog.Info("Publishing change notification", "added", event.AddedCount, "removed", event.RemovedCount, "truncated", event.Truncated)
+	var errs []error
+	for _, p := range publishers {
+		errs = append(errs, p.Publish(ctx, event))
+	}
+	return errors.Join(errs...)
+}
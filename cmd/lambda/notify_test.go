@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+const previousConfig = `[default]
+sso_session = corp
+
+[profile 111111111111-ReadOnly]
+sso_account_id = 111111111111
+
+[profile 222222222222-Admin]
+sso_account_id = 222222222222
+`
+
+const currentConfig = `# Generated on: 2024-01-01T00:00:00 UTC
+[default]
+sso_session = corp
+
+[profile 111111111111-ReadOnly]
+sso_account_id = 111111111111
+
+[profile 333333333333-Admin]
+sso_account_id = 333333333333
+`
+
+type recordingPublisher struct {
+	events []ChangeEvent
+	err    error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event ChangeEvent) error {
+	p.events = append(p.events, event)
+	return p.err
+}
+
+type stubSNSClient struct {
+	input *sns.PublishInput
+	err   error
+}
+
+func (s *stubSNSClient) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	s.input = params
+	return &sns.PublishOutput{}, s.err
+}
+
+type stubEventBridgeClient struct {
+	input *eventbridge.PutEventsInput
+	out   *eventbridge.PutEventsOutput
+}
+
+func (s *stubEventBridgeClient) PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	s.input = params
+	if s.out == nil {
+		return &eventbridge.PutEventsOutput{}, nil
+	}
+	return s.out, nil
+}
+
+func TestDiffProfiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		previous    string
+		current     string
+		wantAdded   []string
+		wantRemoved []string
+		wantErr     bool
+	}{
+		{
+			name:        "added and removed",
+			previous:    previousConfig,
+			current:     currentConfig,
+			wantAdded:   []string{"333333333333-Admin"},
+			wantRemoved: []string{"222222222222-Admin"},
+		},
+		{
+			name:        "no previous object",
+			current:     currentConfig,
+			wantAdded:   []string{"111111111111-ReadOnly", "333333333333-Admin"},
+			wantRemoved: []string{},
+		},
+		{
+			name:        "same profiles",
+			previous:    previousConfig,
+			current:     previousConfig,
+			wantAdded:   []string{},
+			wantRemoved: []string{},
+		},
+		{
+			name:     "malformed previous",
+			previous: "[unterminated",
+			current:  currentConfig,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			added, removed, err := diffProfiles([]byte(tc.previous), []byte(tc.current), formatINI)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(added, tc.wantAdded) {
+				t.Errorf("added = %v, want %v", added, tc.wantAdded)
+			}
+			if !reflect.DeepEqual(removed, tc.wantRemoved) {
+				t.Errorf("removed = %v, want %v", removed, tc.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestNotifyChange(t *testing.T) {
+	changed := Result{Status: StatusChanged, Bucket: "bucket", Key: "key", Hash: "abc", previous: []byte(previousConfig)}
+
+	t.Run("unchanged sends nothing", func(t *testing.T) {
+		p := &recordingPublisher{}
+		unchanged := Result{Status: StatusUnchanged}
+		if err := notifyChange(context.Background(), []publisher{p}, unchanged, []byte(currentConfig)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(p.events) != 0 {
+			t.Errorf("published %d events, want 0", len(p.events))
+		}
+	})
+
+	t.Run("changed publishes the diff", func(t *testing.T) {
+		p := &recordingPublisher{}
+		if err := notifyChange(context.Background(), []publisher{p}, changed, []byte(currentConfig)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := ChangeEvent{
+			Bucket:       "bucket",
+			Key:          "key",
+			Hash:         "abc",
+			AddedCount:   1,
+			RemovedCount: 1,
+			Added:        []string{"333333333333-Admin"},
+			Removed:      []string{"222222222222-Admin"},
+		}
+		if len(p.events) != 1 || !reflect.DeepEqual(p.events[0], want) {
+			t.Errorf("events = %+v, want [%+v]", p.events, want)
+		}
+	})
+
+	t.Run("every publisher is tried", func(t *testing.T) {
+		failing := &recordingPublisher{err: errors.New("boom")}
+		ok := &recordingPublisher{}
+		err := notifyChange(context.Background(), []publisher{failing, ok}, changed, []byte(currentConfig))
+		if err == nil || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("expected boom error, got %v", err)
+		}
+		if len(ok.events) != 1 {
+			t.Errorf("second publisher got %d events, want 1", len(ok.events))
+		}
+	})
+}
+
+func TestNotifyChange_LargeDiff(t *testing.T) {
+	// A first write of a large organization lists every profile as added.
+	var config strings.Builder
+	config.WriteString("[default]\nsso_session = corp\n")
+	for i := range 20000 {
+		fmt.Fprintf(&config, "\n[profile %012d-AdministratorAccess]\nsso_account_id = %012d\n", i, i)
+	}
+	result := Result{Status: StatusChanged, Sink: sinkS3, Bucket: "bucket", Key: "key", Hash: "abc"}
+
+	client := &stubSNSClient{}
+	p := snsPublisher{client: client, topicArn: "arn:aws:sns:us-east-1:123456789012:setlist"}
+	if err := notifyChange(context.Background(), []publisher{p}, result, []byte(config.String())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	message := aws.ToString(client.input.Message)
+	if len(message) > maxEventSize {
+		t.Errorf("message is %d bytes, more than %d", len(message), maxEventSize)
+	}
+	var got ChangeEvent
+	if err := json.Unmarshal([]byte(message), &got); err != nil {
+		t.Fatalf("message is not JSON: %v", err)
+	}
+	if !got.Truncated || got.AddedCount != 20000 || len(got.Added) == 0 || len(got.Added) >= 20000 {
+		t.Errorf("truncated = %v, added_count = %d, %d names listed", got.Truncated, got.AddedCount, len(got.Added))
+	}
+	if got.Added[0] != "000000000000-AdministratorAccess" {
+		t.Errorf("first added = %q, want the first in sorted order", got.Added[0])
+	}
+	// Most of the budget is used, rather than dropping the lists entirely.
+	if len(message) < maxEventSize-100 {
+		t.Errorf("message is only %d bytes", len(message))
+	}
+}
+
+func TestChangeEvent_Truncate(t *testing.T) {
+	event := ChangeEvent{Key: "key", AddedCount: 2, RemovedCount: 2, Added: []string{"a", "b"}, Removed: []string{"c", "ddddddddddddddddddddddddddddddd"}}
+	full, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	small := event
+	if err := small.truncate(len(full)); err != nil || small.Truncated {
+		t.Errorf("event that fits was truncated: %+v, %v", small, err)
+	}
+
+	// Room for everything but the last name.
+	want := ChangeEvent{Key: "key", AddedCount: 2, RemovedCount: 2, Added: []string{"a", "b"}, Removed: []string{"c"}, Truncated: true}
+	limit, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := event.truncate(len(limit)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(event, want) {
+		t.Errorf("event = %+v, want %+v", event, want)
+	}
+}
+
+func TestSNSPublisher(t *testing.T) {
+	client := &stubSNSClient{}
+	p := snsPublisher{client: client, topicArn: "arn:aws:sns:us-east-1:123456789012:setlist"}
+
+	event := ChangeEvent{Bucket: "bucket", Key: "key", AddedCount: 1, Added: []string{"a"}, Removed: []string{}}
+	if err := p.Publish(context.Background(), event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := aws.ToString(client.input.TopicArn); got != p.topicArn {
+		t.Errorf("TopicArn = %q, want %q", got, p.topicArn)
+	}
+	var got ChangeEvent
+	if err := json.Unmarshal([]byte(aws.ToString(client.input.Message)), &got); err != nil {
+		t.Fatalf("message is not JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, event) {
+		t.Errorf("message = %+v, want %+v", got, event)
+	}
+
+	client.err = errors.New("denied")
+	if err := p.Publish(context.Background(), event); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestEventBridgePublisher(t *testing.T) {
+	client := &stubEventBridgeClient{}
+	p := eventBridgePublisher{client: client, busName: "default"}
+
+	if err := p.Publish(context.Background(), ChangeEvent{Bucket: "bucket"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry := client.input.Entries[0]
+	if aws.ToString(entry.EventBusName) != "default" || aws.ToString(entry.Source) != eventSource || aws.ToString(entry.DetailType) != eventDetailType {
+		t.Errorf("entry = %+v", entry)
+	}
+	if !strings.Contains(aws.ToString(entry.Detail), `"bucket":"bucket"`) {
+		t.Errorf("Detail = %s", aws.ToString(entry.Detail))
+	}
+
+	client.out = &eventbridge.PutEventsOutput{
+		FailedEntryCount: 1,
+		Entries:          []ebtypes.PutEventsResultEntry{{ErrorCode: aws.String("AccessDenied"), ErrorMessage: aws.String("nope")}},
+	}
+	if err := p.Publish(context.Background(), ChangeEvent{}); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("expected AccessDenied error, got %v", err)
+	}
+}
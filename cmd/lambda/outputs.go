@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"gopkg.in/yaml.v3"
+)
+
+// keyOutputs holds the outputs list: inline YAML, or an s3:// URL of a YAML
+// document.
+const keyOutputs = "outputs"
+
+// Ways an output can be split into one object per group. The output's key
+// must contain the group's placeholder.
+const (
+	splitOU            = "ou"
+	splitPermissionSet = "permission-set"
+)
+
+// splitPlaceholders maps each split to the placeholder its key must hold.
+var splitPlaceholders = map[string]string{
+	splitOU:            "{ou}",
+	splitPermissionSet: "{permission_set}",
+}
+
+// Output declares one object, or one object per group when split, to be
+// rendered from the generated config file and written to a sink. Key is the
+// object key, parameter name, secret name or file path, depending on the
+// sink.
+type Output struct {
+	Key                   string `yaml:"key"`
+	Sink                  string `yaml:"sink,omitempty"`
+	Bucket                string `yaml:"bucket,omitempty"`
+	Repository            string `yaml:"repository,omitempty"`
+	Branch                string `yaml:"branch,omitempty"`
+	Format                string `yaml:"format,omitempty"`
+	IncludeAccounts       string `yaml:"include-accounts,omitempty"`
+	ExcludeAccounts       string `yaml:"exclude-accounts,omitempty"`
+	IncludePermissionSets string `yaml:"include-permission-sets,omitempty"`
+	ExcludePermissionSets string `yaml:"exclude-permission-sets,omitempty"`
+	Split                 string `yaml:"split,omitempty"`
+}
+
+// outputsDocument is the YAML document holding the outputs list.
+type outputsDocument struct {
+	Outputs []Output `yaml:"outputs"`
+}
+
+// validate checks an output whose defaults have been applied.
+func (o Output) validate() error {
+	var errs []error
+
+	if o.Key == "" {
+		errs = append(errs, errors.New("key is required"))
+	}
+
+	switch o.Sink {
+	case sinkS3:
+		if o.Bucket == "" {
+			errs = append(errs, errors.New("bucket is required when S3_BUCKET is unset"))
+		}
+	case sinkSSM, sinkSecretsManager:
+	case sinkGit:
+		if !strings.HasPrefix(o.Repository, "https://") {
+			errs = append(errs, errors.New("repository must be an https:// URL"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported sink %q (supported: %s)", o.Sink, strings.Join(sinkNames, ", ")))
+	}
+	if o.Key != "" {
+		if err := validateKey(o.Sink, o.Key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if o.Sink != sinkS3 && o.Bucket != "" {
+		errs = append(errs, fmt.Errorf("bucket does not apply to the %s sink", o.Sink))
+	}
+	if o.Sink != sinkGit && (o.Repository != "" || o.Branch != "") {
+		errs = append(errs, fmt.Errorf("repository and branch do not apply to the %s sink", o.Sink))
+	}
+
+	if err := validateFormat(o.Format); err != nil {
+		errs = append(errs, err)
+	}
+
+	if o.Split != "" {
+		placeholder, ok := splitPlaceholders[o.Split]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("unsupported split %q (supported: %s, %s)", o.Split, splitOU, splitPermissionSet))
+		case !strings.Contains(o.Key, placeholder):
+			errs = append(errs, fmt.Errorf("key must contain %s when split by %s", placeholder, o.Split))
+		case !slices.Contains(pruningSinks, o.Sink):
+			errs = append(errs, fmt.Errorf("the %s sink can't be split, because it can't remove the groups that disappear (split sinks: %s)", o.Sink, strings.Join(pruningSinks, ", ")))
+		}
+	}
+	for split, placeholder := range splitPlaceholders {
+		if o.Split != split && strings.Contains(o.Key, placeholder) {
+			errs = append(errs, fmt.Errorf("key contains %s but the output is not split by %s", placeholder, split))
+		}
+	}
+
+	if _, err := setlist.ParseAccountPatterns(o.IncludeAccounts); err != nil {
+		errs = append(errs, fmt.Errorf("include-accounts: %w", err))
+	}
+	if _, err := setlist.ParseAccountPatterns(o.ExcludeAccounts); err != nil {
+		errs = append(errs, fmt.Errorf("exclude-accounts: %w", err))
+	}
+	if _, err := setlist.ParsePermissionSetPatterns(o.IncludePermissionSets); err != nil {
+		errs = append(errs, fmt.Errorf("include-permission-sets: %w", err))
+	}
+	if _, err := setlist.ParsePermissionSetPatterns(o.ExcludePermissionSets); err != nil {
+		errs = append(errs, fmt.Errorf("exclude-permission-sets: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateKey checks a key is one the sink can write to. It is checked
+// again once a split's placeholder is replaced, since an OU or permission
+// set name may hold a / or be "..". Object keys and file paths must stay
+// inside their bucket or repository, with no empty, . or .. segments.
+func validateKey(sink, key string) error {
+	switch sink {
+	case sinkS3:
+		if strings.HasPrefix(key, "/") {
+			return errors.New("key must not start with /")
+		}
+		if hasDotSegment(key) {
+			return errors.New("key must not contain empty, . or .. path segments")
+		}
+	case sinkSSM:
+		if !strings.HasPrefix(key, "/") {
+			return errors.New("key must be a parameter name starting with /")
+		}
+	case sinkGit:
+		if strings.HasPrefix(key, "/") || hasDotSegment(key) {
+			return errors.New("key must be a path inside the repository")
+		}
+	}
+	return nil
+}
+
+// hasDotSegment reports whether a /-separated path has an empty, . or ..
+// segment.
+func hasDotSegment(key string) bool {
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOutputs decodes an outputs document, fills in the default sink,
+// bucket, branch and format, and validates every entry.
+func parseOutputs(data []byte, defaultBucket string) ([]Output, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var doc outputsDocument
+	if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid outputs: %w", err)
+	}
+	if len(doc.Outputs) == 0 {
+		return nil, errors.New("invalid outputs: no outputs listed")
+	}
+
+	var errs []error
+	seen := map[string]int{}
+	for i := range doc.Outputs {
+		o := &doc.Outputs[i]
+		if o.Sink == "" {
+			o.Sink = sinkS3
+		}
+		if o.Sink == sinkS3 && o.Bucket == "" {
+			o.Bucket = defaultBucket
+		}
+		if o.Sink == sinkGit && o.Branch == "" {
+			o.Branch = defaultBranch
+		}
+		if o.Format == "" {
+			o.Format = formatINI
+		}
+
+		if err := o.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("outputs[%d]: %w", i, err))
+		}
+
+		dest := o.target(o.Key).String()
+		if j, ok := seen[dest]; ok {
+			errs = append(errs, fmt.Errorf("outputs[%d]: %s is also written by outputs[%d]", i, dest, j))
+		}
+		seen[dest] = i
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid outputs: %w", errors.Join(errs...))
+	}
+	return doc.Outputs, nil
+}
+
+// loadOutputs reads the outputs list from source, which is either an
+// s3:// URL or the YAML itself.
+func loadOutputs(ctx context.Context, store objectStore, source, defaultBucket string) ([]Output, error) {
+	location, ok := strings.CutPrefix(source, "s3://")
+	if !ok {
+		return parseOutputs([]byte(source), defaultBucket)
+	}
+
+	bucket, key, ok := strings.Cut(location, "/")
+	if !ok || bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid outputs location %q: want s3://bucket/key", source)
+	}
+
+	out, err := store.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outputs from %s: %w", source, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outputs from %s: %w", source, err)
+	}
+
+	outputs, err := parseOutputs(data, defaultBucket)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return outputs, nil
+}
+
+// target is where an artifact is written.
+type target struct {
+	sink       string
+	bucket     string // s3 only
+	repository string // git only
+	branch     string // git only
+	key        string
+}
+
+// String returns the target as a single location, e.g.
+// s3://bucket/aws.config or ssm:/setlist/aws-config.
+func (t target) String() string {
+	switch t.sink {
+	case sinkS3:
+		return "s3://" + t.bucket + "/" + t.key
+	case sinkGit:
+		return t.repository + "#" + t.branch + ":" + t.key
+	}
+	return t.sink + ":" + t.key
+}
+
+// target returns where the output writes key, one of its split keys.
+func (o Output) target(key string) target {
+	return target{
+		sink:       o.Sink,
+		bucket:     o.Bucket,
+		repository: o.Repository,
+		branch:     o.Branch,
+		key:        key,
+	}
+}
+
+// artifact is one rendered object waiting to be written.
+type artifact struct {
+	target
+	format   string
+	profiles int
+	body     []byte
+}
+
+// ouPathFunc returns the OU path of an account, e.g. Root/Workloads/Prod.
+type ouPathFunc func(ctx context.Context, accountId string) (string, error)
+
+// buildArtifacts renders every output from the one generated config file.
+// ouPath is only called when an output is split by OU, once per account.
+func buildArtifacts(ctx context.Context, configFile setlist.ConfigFile, outputs []Output, ouPath ouPathFunc) ([]artifact, error) {
+	paths := map[string]string{}
+	accountPath := func(accountId string) (string, error) {
+		if p, ok := paths[accountId]; ok {
+			return p, nil
+		}
+		p, err := ouPath(ctx, accountId)
+		if err != nil {
+			return "", err
+		}
+		paths[accountId] = p
+		return p, nil
+	}
+
+	var artifacts []artifact
+	for _, o := range outputs {
+		profiles, err := o.filter(configFile.Profiles)
+		if err != nil {
+			return nil, err
+		}
+
+		groups := map[string][]setlist.Profile{"": profiles}
+		if o.Split != "" {
+			groups = map[string][]setlist.Profile{}
+			for _, p := range profiles {
+				group := p.RoleName.String()
+				if o.Split == splitOU {
+					if group, err = accountPath(p.AccountId.String()); err != nil {
+						return nil, fmt.Errorf("failed to split %s by OU: %w", o.Key, err)
+					}
+				}
+				groups[group] = append(groups[group], p)
+			}
+		}
+
+		names := make([]string, 0, len(groups))
+		for name := range groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			cf := configFile
+			cf.Profiles = groups[name]
+
+			body, err := render(cf, o.Format)
+			if err != nil {
+				return nil, err
+			}
+
+			key := o.Key
+			if o.Split != "" {
+				key = strings.ReplaceAll(key, splitPlaceholders[o.Split], name)
+				if err := validateKey(o.Sink, key); err != nil {
+					return nil, fmt.Errorf("%s split by %s into %q: %w", o.Key, o.Split, name, err)
+				}
+			}
+
+			artifacts = append(artifacts, artifact{
+				target:   o.target(key),
+				format:   o.Format,
+				profiles: len(cf.Profiles),
+				body:     body,
+			})
+		}
+	}
+
+	return artifacts, nil
+}
+
+// pruneSplits deletes what split outputs wrote for groups that no longer
+// exist: anything under a split output's prefix whose key fits its pattern
+// but wasn't among the artifacts, along with its signature. Sinks only
+// delete what setlist wrote. It returns the locations deleted.
+func pruneSplits(ctx context.Context, sinks sinkSet, outputs []Output, artifacts []artifact) ([]string, error) {
+	written := map[string]bool{}
+	for _, a := range artifacts {
+		written[a.target.String()] = true
+	}
+
+	var deleted []string
+	var errs []error
+	for _, o := range outputs {
+		if o.Split == "" {
+			continue
+		}
+		p, ok := sinks[o.Sink].(pruner)
+		if !ok {
+			continue
+		}
+
+		prefix, suffix, _ := strings.Cut(o.Key, splitPlaceholders[o.Split])
+		stale := func(key string) bool {
+			base := strings.TrimSuffix(key, setlist.SignatureExtension)
+			return len(base) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(base, prefix) && strings.HasSuffix(base, suffix) &&
+				!written[o.target(base).String()]
+		}
+		d, err := p.Prune(ctx, o.target(prefix), stale)
+		deleted = append(deleted, d...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove stale groups of %s: %w", o.target(o.Key), err))
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// filter returns the profiles the output's account and permission set
+// patterns select. Exclusions are applied after inclusions.
+func (o Output) filter(profiles []setlist.Profile) ([]setlist.Profile, error) {
+	includeAccounts, err := setlist.ParseAccountPatterns(o.IncludeAccounts)
+	if err != nil {
+		return nil, err
+	}
+	excludeAccounts, err := setlist.ParseAccountPatterns(o.ExcludeAccounts)
+	if err != nil {
+		return nil, err
+	}
+	includePS, err := setlist.ParsePermissionSetPatterns(o.IncludePermissionSets)
+	if err != nil {
+		return nil, err
+	}
+	excludePS, err := setlist.ParsePermissionSetPatterns(o.ExcludePermissionSets)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []setlist.Profile{}
+	for _, p := range profiles {
+		accountId, roleName := p.AccountId.String(), p.RoleName.String()
+		if len(includeAccounts) > 0 && !includeAccounts.Match(accountId) {
+			continue
+		}
+		if excludeAccounts.Match(accountId) {
+			continue
+		}
+		if len(includePS) > 0 && !includePS.Match(roleName) {
+			continue
+		}
+		if excludePS.Match(roleName) {
+			continue
+		}
+		selected = append(selected, p)
+	}
+	return selected, nil
+}
+
+// needsOUPaths reports whether any output is split by OU.
+func needsOUPaths(outputs []Output) bool {
+	for _, o := range outputs {
+		if o.Split == splitOU {
+			return true
+		}
+	}
+	return false
+}
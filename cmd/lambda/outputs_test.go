@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const outputsYAML = `outputs:
+  - key: all.config
+  - key: readonly.config
+    include-permission-sets: "ReadOnly*"
+  - key: ou/{ou}.config
+    split: ou
+  - key: inventory.json
+    bucket: other-bucket
+    format: json
+  - key: /setlist/aws-config
+    sink: ssm
+  - key: configs/aws.config
+    sink: git
+    repository: https://git.example.com/platform/configs.git
+`
+
+func TestParseOutputs(t *testing.T) {
+	outputs, err := parseOutputs([]byte(outputsYAML), "bucket")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Output{
+		{Key: "all.config", Sink: sinkS3, Bucket: "bucket", Format: formatINI},
+		{Key: "readonly.config", Sink: sinkS3, Bucket: "bucket", Format: formatINI, IncludePermissionSets: "ReadOnly*"},
+		{Key: "ou/{ou}.config", Sink: sinkS3, Bucket: "bucket", Format: formatINI, Split: splitOU},
+		{Key: "inventory.json", Sink: sinkS3, Bucket: "other-bucket", Format: formatJSON},
+		{Key: "/setlist/aws-config", Sink: sinkSSM, Format: formatINI},
+		{Key: "configs/aws.config", Sink: sinkGit, Repository: "https://git.example.com/platform/configs.git", Branch: defaultBranch, Format: formatINI},
+	}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("parseOutputs() = %+v, want %+v", outputs, want)
+	}
+}
+
+func TestParseOutputs_NoDefaultBucket(t *testing.T) {
+	if _, err := parseOutputs([]byte("outputs:\n  - key: /a\n    sink: ssm\n"), ""); err != nil {
+		t.Errorf("unexpected error for an output that needs no bucket: %v", err)
+	}
+	if _, err := parseOutputs([]byte("outputs:\n  - key: a\n"), ""); err == nil || !strings.Contains(err.Error(), "bucket is required") {
+		t.Errorf("expected bucket error, got %v", err)
+	}
+}
+
+func TestParseOutputs_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr []string
+	}{
+		{name: "empty", yaml: "", wantErr: []string{"no outputs listed"}},
+		{name: "unknown field", yaml: "outputs:\n  - key: a\n    splt: ou\n", wantErr: []string{"splt"}},
+		{name: "missing key", yaml: "outputs:\n  - format: ini\n", wantErr: []string{"outputs[0]: key is required"}},
+		{name: "bad format", yaml: "outputs:\n  - key: a\n    format: toml\n", wantErr: []string{`unsupported format "toml"`}},
+		{name: "bad split", yaml: "outputs:\n  - key: a/{ou}\n    split: region\n", wantErr: []string{`unsupported split "region"`}},
+		{name: "split without placeholder", yaml: "outputs:\n  - key: a.config\n    split: permission-set\n", wantErr: []string{"must contain {permission_set}"}},
+		{name: "placeholder without split", yaml: "outputs:\n  - key: a/{ou}.config\n", wantErr: []string{"not split by ou"}},
+		{name: "bad pattern", yaml: "outputs:\n  - key: a\n    include-accounts: \"12\"\n", wantErr: []string{"include-accounts"}},
+		{name: "bad sink", yaml: "outputs:\n  - key: a\n    sink: dynamodb\n", wantErr: []string{`unsupported sink "dynamodb"`}},
+		{name: "relative parameter", yaml: "outputs:\n  - key: setlist/config\n    sink: ssm\n", wantErr: []string{"starting with /"}},
+		{name: "bucket on ssm", yaml: "outputs:\n  - key: /a\n    sink: ssm\n    bucket: b\n", wantErr: []string{"bucket does not apply to the ssm sink"}},
+		{name: "git without repository", yaml: "outputs:\n  - key: a\n    sink: git\n", wantErr: []string{"https:// URL"}},
+		{name: "git path escapes", yaml: "outputs:\n  - key: ../a\n    sink: git\n    repository: https://h/r.git\n", wantErr: []string{"inside the repository"}},
+		{name: "s3 dot segment", yaml: "outputs:\n  - key: a/../b\n", wantErr: []string{"empty, . or .. path segments"}},
+		{name: "split parameter", yaml: "outputs:\n  - key: /setlist/{ou}\n    sink: ssm\n    split: ou\n", wantErr: []string{"the ssm sink can't be split"}},
+		{name: "split secret", yaml: "outputs:\n  - key: setlist/{permission_set}\n    sink: secretsmanager\n    split: permission-set\n", wantErr: []string{"the secretsmanager sink can't be split"}},
+		{name: "branch on secret", yaml: "outputs:\n  - key: a\n    sink: secretsmanager\n    branch: main\n", wantErr: []string{"do not apply to the secretsmanager sink"}},
+		{
+			name:    "duplicate destination",
+			yaml:    "outputs:\n  - key: a\n  - key: b\n  - key: a\n",
+			wantErr: []string{"outputs[2]: s3://bucket/a is also written by outputs[0]"},
+		},
+		{
+			name:    "duplicate parameter",
+			yaml:    "outputs:\n  - key: /a\n    sink: ssm\n  - key: /a\n    sink: ssm\n    format: json\n",
+			wantErr: []string{"outputs[1]: ssm:/a is also written by outputs[0]"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseOutputs([]byte(tc.yaml), "bucket")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadOutputs_S3(t *testing.T) {
+	store := &stubObjectStore{get: &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(outputsYAML))}}
+
+	outputs, err := loadOutputs(context.Background(), store, "s3://config-bucket/setlist/outputs.yaml", "bucket")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(outputs) != 6 {
+		t.Errorf("got %d outputs, want 6", len(outputs))
+	}
+
+	if _, err := loadOutputs(context.Background(), store, "s3://config-bucket", "bucket"); err == nil {
+		t.Error("expected error for a URL without a key")
+	}
+
+	store = &stubObjectStore{getErr: errors.New("access denied")}
+	if _, err := loadOutputs(context.Background(), store, "s3://config-bucket/outputs.yaml", "bucket"); err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("expected access denied error, got %v", err)
+	}
+}
+
+func TestOutputsFor(t *testing.T) {
+	resolve := func(event Event, env map[string]string) settings.Resolved {
+		return loadSettings(event, func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		})
+	}
+	outputsEnv := map[string]string{"S3_KEY": "aws.config", "OUTPUTS": "outputs:\n  - key: all.config\n  - key: inventory.json\n    format: json\n"}
+
+	tests := []struct {
+		name     string
+		resolved settings.Resolved
+		wantKeys []string
+		wantErr  string
+	}{
+		{
+			name:     "single output from S3_KEY",
+			resolved: resolve(Event{}, map[string]string{"S3_KEY": "aws.config"}),
+			wantKeys: []string{"aws.config"},
+		},
+		{
+			name:     "outputs list wins over S3_KEY",
+			resolved: resolve(Event{}, outputsEnv),
+			wantKeys: []string{"all.config", "inventory.json"},
+		},
+		{
+			name:     "event key wins over outputs list",
+			resolved: resolve(Event{S3Key: "variant.config"}, outputsEnv),
+			wantKeys: []string{"variant.config"},
+		},
+		{
+			name:     "no key",
+			resolved: resolve(Event{}, nil),
+			wantErr:  "S3_KEY",
+		},
+		{
+			name:     "bad format",
+			resolved: resolve(Event{}, map[string]string{"S3_KEY": "aws.config", "FORMAT": "yaml"}),
+			wantErr:  `unsupported format "yaml"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			outputs, err := outputsFor(context.Background(), &stubObjectStore{}, tc.resolved, "bucket")
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var keys []string
+			for _, o := range outputs {
+				keys = append(keys, o.Key)
+			}
+			if !reflect.DeepEqual(keys, tc.wantKeys) {
+				t.Errorf("keys = %v, want %v", keys, tc.wantKeys)
+			}
+		})
+	}
+
+	t.Run("single output needs a bucket", func(t *testing.T) {
+		_, err := outputsFor(context.Background(), &stubObjectStore{}, resolve(Event{}, map[string]string{"S3_KEY": "aws.config"}), "")
+		if err == nil || !strings.Contains(err.Error(), "S3_BUCKET") {
+			t.Errorf("expected S3_BUCKET error, got %v", err)
+		}
+	})
+}
+
+func testConfigFile() setlist.ConfigFile {
+	profile := func(accountId, role string) setlist.Profile {
+		return setlist.Profile{
+			SessionName:     "corp",
+			AccountId:       setlist.AWSAccountId(accountId),
+			RoleName:        setlist.RoleName(role),
+			Description:     "desc",
+			SessionDuration: "PT1H",
+		}
+	}
+	return setlist.ConfigFile{
+		SessionName:     "corp",
+		IdentityStoreId: "d-1234567890",
+		Region:          "us-east-1",
+		NicknameMapping: map[string]string{"111111111111": "prod"},
+		Profiles: []setlist.Profile{
+			profile("111111111111", "ReadOnly"),
+			profile("111111111111", "Admin"),
+			profile("222222222222", "ReadOnly"),
+		},
+	}
+}
+
+func TestBuildArtifacts(t *testing.T) {
+	ouPaths := map[string]string{"111111111111": "Root/Prod", "222222222222": "Root/Dev"}
+	var lookups int
+	ouPath := func(ctx context.Context, accountId string) (string, error) {
+		lookups++
+		return ouPaths[accountId], nil
+	}
+
+	outputs, err := parseOutputs([]byte(`outputs:
+  - key: all.config
+  - key: readonly.config
+    include-permission-sets: "ReadOnly*"
+  - key: ou/{ou}.config
+    split: ou
+  - key: ps/{permission_set}.json
+    format: json
+    exclude-accounts: "222222222222"
+    split: permission-set
+`), "bucket")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	artifacts, err := buildArtifacts(context.Background(), testConfigFile(), outputs, ouPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]int{
+		"all.config":          3,
+		"readonly.config":     2,
+		"ou/Root/Dev.config":  1,
+		"ou/Root/Prod.config": 2,
+		"ps/Admin.json":       1,
+		"ps/ReadOnly.json":    1,
+	}
+	got := map[string]int{}
+	for _, a := range artifacts {
+		got[a.key] = a.profiles
+		if a.bucket != "bucket" {
+			t.Errorf("%s bucket = %q, want bucket", a.key, a.bucket)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("artifacts = %v, want %v", got, want)
+	}
+	if lookups != 2 {
+		t.Errorf("OU path looked up %d times, want once per account", lookups)
+	}
+}
+
+func TestBuildArtifacts_OUPathError(t *testing.T) {
+	outputs := []Output{{Key: "{ou}.config", Sink: sinkS3, Bucket: "bucket", Format: formatINI, Split: splitOU}}
+	ouPath := func(ctx context.Context, accountId string) (string, error) {
+		return "", errors.New("denied")
+	}
+
+	if _, err := buildArtifacts(context.Background(), testConfigFile(), outputs, ouPath); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("expected denied error, got %v", err)
+	}
+}
+
+func TestBuildArtifacts_UnsafeGroupNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		output Output
+		ouPath string
+	}{
+		{name: "OU path climbs out", output: Output{Key: "ou/{ou}.config", Sink: sinkS3, Bucket: "bucket", Format: formatINI, Split: splitOU}, ouPath: "Root/../../etc"},
+		{name: "empty OU name", output: Output{Key: "ou/{ou}/aws.config", Sink: sinkGit, Repository: "https://h/r.git", Branch: "main", Format: formatINI, Split: splitOU}, ouPath: "Root//Prod"},
+		{name: "dot dot permission set", output: Output{Key: "{permission_set}/aws.config", Sink: sinkGit, Repository: "https://h/r.git", Branch: "main", Format: formatINI, Split: splitPermissionSet}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			configFile := testConfigFile()
+			configFile.Profiles[0].RoleName = ".."
+			ouPath := func(ctx context.Context, accountId string) (string, error) { return tc.ouPath, nil }
+
+			_, err := buildArtifacts(context.Background(), configFile, []Output{tc.output}, ouPath)
+			if err == nil || !strings.Contains(err.Error(), "split by") {
+				t.Errorf("expected the substituted key to be rejected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPruneSplits(t *testing.T) {
+	written := map[string]string{hashMetadataKey: "abc"}
+	bucket := &fakeBucket{pageSize: 10, objects: map[string]map[string]string{
+		"ps/Admin.config":       written,
+		"ps/Admin.config.sig":   written,
+		"ps/ReadOnly.config":    written,
+		"ps/Retired.config":     written,
+		"ps/Retired.config.sig": written,
+		"ps/all.config":         written,
+		"ps/inventory.json":     written,
+	}}
+	sinks := sinkSet{sinkS3: s3Sink{store: bucket}}
+
+	outputs := []Output{
+		{Key: "ps/{permission_set}.config", Sink: sinkS3, Bucket: "configs", Format: formatINI, Split: splitPermissionSet},
+		{Key: "ps/all.config", Sink: sinkS3, Bucket: "configs", Format: formatINI},
+	}
+	artifacts, err := buildArtifacts(context.Background(), testConfigFile(), outputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := pruneSplits(context.Background(), sinks, outputs, artifacts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"s3://configs/ps/Retired.config", "s3://configs/ps/Retired.config.sig"}
+	if !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted = %v, want %v", deleted, want)
+	}
+}
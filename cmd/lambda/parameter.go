@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// parameterValueLimit is the most bytes an advanced tier parameter holds.
+const parameterValueLimit = 8192
+
+// Lines of the manifest a chunked parameter holds instead of the config.
+const (
+	manifestChunksPrefix = "setlist-chunks: "
+	manifestHashPrefix   = "setlist-hash: "
+)
+
+// chunkHashLength is how much of the config's hash names its chunks.
+const chunkHashLength = 12
+
+// deleteParametersLimit is the most names one DeleteParameters call takes.
+const deleteParametersLimit = 10
+
+// parameterStore is the subset of the SSM API the parameter sink uses.
+type parameterStore interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	DeleteParameters(ctx context.Context, params *ssm.DeleteParametersInput, optFns ...func(*ssm.Options)) (*ssm.DeleteParametersOutput, error)
+}
+
+// parameterSink writes artifacts as advanced tier SSM parameters. A config
+// too large for one parameter is split on line boundaries into chunks named
+// after its hash, <key>/<hash>/001, <key>/<hash>/002 and so on, and <key>
+// holds a manifest giving the chunk count and content hash. A new config's
+// chunks never overwrite the ones the manifest points at: they are written
+// first, the manifest is switched to them, and the previous config's chunks
+// are deleted after it. Readers check what they reassemble against the
+// manifest's hash.
+type parameterSink struct {
+	store parameterStore
+}
+
+func (s parameterSink) Write(ctx context.Context, a artifact) (Result, error) {
+	name := a.key
+	result := newResult(a)
+
+	previous, previousManifest, err := s.read(ctx, name)
+	if err != nil {
+		return result, err
+	}
+	if previous != nil && setlist.ContentHash(previous) == result.Hash {
+		slog.Info("Config file unchanged, skipping write", "parameter", name, "hash", result.Hash)
+		result.Status = StatusUnchanged
+		return result, nil
+	}
+	result.previous = previous
+
+	chunks := splitChunks(string(a.body), parameterValueLimit)
+	if len(chunks) == 1 {
+		if err := s.put(ctx, name, chunks[0]); err != nil {
+			return result, err
+		}
+	} else {
+		m := manifest{chunks: len(chunks), hash: result.Hash}
+		for i, chunk := range chunks {
+			if err := s.put(ctx, m.chunkName(name, i+1), chunk); err != nil {
+				return result, err
+			}
+		}
+		if err := s.put(ctx, name, m.String()); err != nil {
+			return result, err
+		}
+	}
+
+	if err := s.deleteChunks(ctx, name, previousManifest); err != nil {
+		return result, err
+	}
+
+	slog.Info("Wrote config to Parameter Store", "parameter", name, "chunks", len(chunks), "hash", result.Hash)
+	result.Status = StatusChanged
+	result.Changed = true
+	return result, nil
+}
+
+func (s parameterSink) Stored(ctx context.Context, t target) (string, error) {
+	value, _, err := s.read(ctx, t.key)
+	if err != nil || value == nil {
+		return "", err
+	}
+	return setlist.ContentHash(value), nil
+}
+
+func (s parameterSink) Read(ctx context.Context, t target) ([]byte, error) {
+	value, _, err := s.read(ctx, t.key)
+	return value, err
+}
+
+// read returns the config stored at name, reassembled from its chunks, and
+// the manifest it was reassembled with, which is empty for a config that
+// wasn't chunked. It returns nil when the parameter does not exist, and an
+// error when the chunks don't add up to the manifest's hash.
+func (s parameterSink) read(ctx context.Context, name string) ([]byte, manifest, error) {
+	value, err := s.get(ctx, name)
+	if err != nil || value == nil {
+		return nil, manifest{}, err
+	}
+
+	m, ok := parseManifest(*value)
+	if !ok {
+		return []byte(*value), manifest{}, nil
+	}
+
+	var b strings.Builder
+	for i := 1; i <= m.chunks; i++ {
+		chunk, err := s.get(ctx, m.chunkName(name, i))
+		if err != nil {
+			return nil, manifest{}, err
+		}
+		if chunk == nil {
+			return nil, manifest{}, fmt.Errorf("parameter %s is missing chunk %d of %d", name, i, m.chunks)
+		}
+		b.WriteString(*chunk)
+	}
+	config := []byte(b.String())
+	if setlist.ContentHash(config) != m.hash {
+		return nil, manifest{}, fmt.Errorf("parameter %s: chunks don't match the manifest's hash %s", name, m.hash)
+	}
+	return config, m, nil
+}
+
+// get returns a parameter's value, or nil when it does not exist.
+func (s parameterSink) get(ctx context.Context, name string) (*string, error) {
+	out, err := s.store.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(name)})
+	var notFound *ssmtypes.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parameter %s: %w", name, err)
+	}
+	return out.Parameter.Value, nil
+}
+
+func (s parameterSink) put(ctx context.Context, name, value string) error {
+	_, err := s.store.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     aws.String(value),
+		Type:      ssmtypes.ParameterTypeString,
+		Tier:      ssmtypes.ParameterTierAdvanced,
+		Overwrite: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write parameter %s: %w", name, err)
+	}
+	return nil
+}
+
+// deleteChunks removes the chunks of the previous config, which the
+// manifest no longer points at.
+func (s parameterSink) deleteChunks(ctx context.Context, name string, previous manifest) error {
+	var stale []string
+	for i := 1; i <= previous.chunks; i++ {
+		stale = append(stale, previous.chunkName(name, i))
+	}
+
+	for len(stale) > 0 {
+		batch := stale[:min(len(stale), deleteParametersLimit)]
+		stale = stale[len(batch):]
+		if _, err := s.store.DeleteParameters(ctx, &ssm.DeleteParametersInput{Names: batch}); err != nil {
+			return fmt.Errorf("failed to delete stale chunks of parameter %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// manifest is what a chunked parameter holds in place of its config: how
+// many chunks the config was split into, and its content hash.
+type manifest struct {
+	chunks int
+	hash   string
+}
+
+func (m manifest) String() string {
+	return fmt.Sprintf("%s%d\n%s%s\n", manifestChunksPrefix, m.chunks, manifestHashPrefix, m.hash)
+}
+
+// chunkName returns the name of chunk i, counting from 1, of the config m
+// describes at parameter name.
+func (m manifest) chunkName(name string, i int) string {
+	return fmt.Sprintf("%s/%s/%03d", name, m.hash[:min(len(m.hash), chunkHashLength)], i)
+}
+
+// parseManifest returns the manifest a parameter value holds, and whether
+// it holds one at all.
+func parseManifest(value string) (manifest, bool) {
+	countLine, rest, _ := strings.Cut(value, "\n")
+	hashLine, _, _ := strings.Cut(rest, "\n")
+
+	countText, ok := strings.CutPrefix(countLine, manifestChunksPrefix)
+	if !ok {
+		return manifest{}, false
+	}
+	count, err := strconv.Atoi(countText)
+	if err != nil || count < 1 {
+		return manifest{}, false
+	}
+	hash, ok := strings.CutPrefix(hashLine, manifestHashPrefix)
+	if !ok || hash == "" {
+		return manifest{}, false
+	}
+	return manifest{chunks: count, hash: hash}, true
+}
+
+// splitChunks splits s into pieces of at most limit bytes, breaking after a
+// newline where one falls inside the piece and never inside a UTF-8
+// sequence.
+func splitChunks(s string, limit int) []string {
+	var chunks []string
+	for len(s) > limit {
+		end := strings.LastIndexByte(s[:limit], '\n') + 1
+		if end == 0 {
+			end = limit
+			for end > 0 && !utf8.RuneStart(s[end]) {
+				end--
+			}
+		}
+		chunks = append(chunks, s[:end])
+		s = s[end:]
+	}
+	return append(chunks, s)
+}
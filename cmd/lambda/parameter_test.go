@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// fakeParameterStore is an in-memory Parameter Store.
+type fakeParameterStore struct {
+	params  map[string]string
+	getErr  error
+	failPut string // a name PutParameter fails for
+	puts    []string
+	deleted []string
+}
+
+func newFakeParameterStore() *fakeParameterStore {
+	return &fakeParameterStore{params: map[string]string{}}
+}
+
+func (f *fakeParameterStore) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	value, ok := f.params[aws.ToString(params.Name)]
+	if !ok {
+		return nil, &ssmtypes.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Name: params.Name, Value: aws.String(value)}}, nil
+}
+
+func (f *fakeParameterStore) PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	if params.Tier != ssmtypes.ParameterTierAdvanced || !aws.ToBool(params.Overwrite) {
+		return nil, errors.New("want an advanced tier overwrite")
+	}
+	name := aws.ToString(params.Name)
+	if name == f.failPut {
+		return nil, errors.New("throttled")
+	}
+	f.params[name] = aws.ToString(params.Value)
+	f.puts = append(f.puts, name)
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (f *fakeParameterStore) DeleteParameters(ctx context.Context, params *ssm.DeleteParametersInput, optFns ...func(*ssm.Options)) (*ssm.DeleteParametersOutput, error) {
+	if len(params.Names) > deleteParametersLimit {
+		return nil, errors.New("too many names")
+	}
+	for _, name := range params.Names {
+		delete(f.params, name)
+		f.deleted = append(f.deleted, name)
+	}
+	return &ssm.DeleteParametersOutput{}, nil
+}
+
+func (f *fakeParameterStore) names() []string {
+	var names []string
+	for name := range f.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// largeConfig returns a config of lines profiles, well over one
+// parameter's limit when lines is in the hundreds.
+func largeConfig(header string, lines int) []byte {
+	var b strings.Builder
+	b.WriteString(header)
+	for i := range lines {
+		b.WriteString("[profile ")
+		b.WriteString(strings.Repeat("x", i%40))
+		b.WriteString("]\nsso_role_name = ReadOnly\n")
+	}
+	return []byte(b.String())
+}
+
+func parameterArtifact(body []byte) artifact {
+	return artifact{target: target{sink: sinkSSM, key: "/setlist/aws-config"}, format: formatINI, profiles: 1, body: body}
+}
+
+func TestParameterSink_Write(t *testing.T) {
+	small := []byte("# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n")
+
+	t.Run("new parameter", func(t *testing.T) {
+		store := newFakeParameterStore()
+		result, err := parameterSink{store: store}.Write(context.Background(), parameterArtifact(small))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Status != StatusChanged || result.previous != nil {
+			t.Errorf("result = %+v", result)
+		}
+		if result.Location != "ssm:/setlist/aws-config" || result.Sink != sinkSSM || result.Bucket != "" {
+			t.Errorf("Location = %q, Sink = %q, Bucket = %q", result.Location, result.Sink, result.Bucket)
+		}
+		if got := store.params["/setlist/aws-config"]; got != string(small) {
+			t.Errorf("parameter = %q", got)
+		}
+	})
+
+	t.Run("unchanged apart from the timestamp", func(t *testing.T) {
+		store := newFakeParameterStore()
+		store.params["/setlist/aws-config"] = strings.Replace(string(small), "2024-01-01", "2023-06-30", 1)
+
+		result, err := parameterSink{store: store}.Write(context.Background(), parameterArtifact(small))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Status != StatusUnchanged || len(store.puts) != 0 {
+			t.Errorf("status = %q with %d puts", result.Status, len(store.puts))
+		}
+	})
+
+	t.Run("too large is chunked and reads back", func(t *testing.T) {
+		store := newFakeParameterStore()
+		store.params["/setlist/aws-config"] = "old"
+		body := largeConfig("# Generated on: 2024-01-01T00:00:00 UTC\n", 600)
+		sink := parameterSink{store: store}
+
+		result, err := sink.Write(context.Background(), parameterArtifact(body))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(result.previous) != "old" {
+			t.Errorf("previous = %q, want old", result.previous)
+		}
+
+		m, ok := parseManifest(store.params["/setlist/aws-config"])
+		if !ok || m.chunks < 3 {
+			t.Fatalf("manifest = %q", store.params["/setlist/aws-config"])
+		}
+		if m.hash != result.Hash {
+			t.Errorf("manifest does not carry the hash: %q", store.params["/setlist/aws-config"])
+		}
+		if _, ok := store.params["/setlist/aws-config/"+result.Hash[:chunkHashLength]+"/001"]; !ok {
+			t.Errorf("chunks aren't named after the hash: %v", store.names())
+		}
+		if last := store.puts[len(store.puts)-1]; last != "/setlist/aws-config" {
+			t.Errorf("last write = %s, want the manifest after its chunks", last)
+		}
+		for name, value := range store.params {
+			if len(value) > parameterValueLimit {
+				t.Errorf("%s holds %d bytes", name, len(value))
+			}
+		}
+
+		got, read, err := sink.read(context.Background(), "/setlist/aws-config")
+		if err != nil || read != m || string(got) != string(body) {
+			t.Errorf("read back %d bytes with %+v (err %v), want %d bytes with %+v", len(got), read, err, len(body), m)
+		}
+
+		again, err := sink.Write(context.Background(), parameterArtifact(body))
+		if err != nil || again.Status != StatusUnchanged {
+			t.Errorf("second write = %q, %v; want unchanged", again.Status, err)
+		}
+	})
+
+	t.Run("shrinking deletes stale chunks", func(t *testing.T) {
+		store := newFakeParameterStore()
+		sink := parameterSink{store: store}
+		if _, err := sink.Write(context.Background(), parameterArtifact(largeConfig("", 600))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		before := len(store.params) - 1
+
+		if _, err := sink.Write(context.Background(), parameterArtifact(largeConfig("", 300))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		after, _ := parseManifest(store.params["/setlist/aws-config"])
+		if len(store.params)-1 != after.chunks || after.chunks >= before {
+			t.Errorf("chunks went from %d to %d, parameters now %v", before, after.chunks, store.names())
+		}
+
+		if _, err := sink.Write(context.Background(), parameterArtifact(small)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(store.names(), []string{"/setlist/aws-config"}) {
+			t.Errorf("parameters = %v, want only the config", store.names())
+		}
+	})
+
+	t.Run("missing chunk", func(t *testing.T) {
+		store := newFakeParameterStore()
+		m := manifest{chunks: 2, hash: setlist.ContentHash([]byte("part"))}
+		store.params["/setlist/aws-config"] = m.String()
+		store.params[m.chunkName("/setlist/aws-config", 1)] = "part"
+
+		_, err := parameterSink{store: store}.Write(context.Background(), parameterArtifact(small))
+		if err == nil || !strings.Contains(err.Error(), "missing chunk 2 of 2") {
+			t.Errorf("expected missing chunk error, got %v", err)
+		}
+	})
+
+	t.Run("chunks that don't match the manifest", func(t *testing.T) {
+		store := newFakeParameterStore()
+		m := manifest{chunks: 2, hash: setlist.ContentHash([]byte("[default]\n[profile a]\n"))}
+		store.params["/setlist/aws-config"] = m.String()
+		store.params[m.chunkName("/setlist/aws-config", 1)] = "[default]\n"
+		store.params[m.chunkName("/setlist/aws-config", 2)] = "[profile b]\n"
+
+		_, _, err := parameterSink{store: store}.read(context.Background(), "/setlist/aws-config")
+		if err == nil || !strings.Contains(err.Error(), "don't match the manifest's hash") {
+			t.Errorf("expected a hash mismatch, got %v", err)
+		}
+	})
+
+	t.Run("read fails", func(t *testing.T) {
+		store := newFakeParameterStore()
+		store.getErr = errors.New("access denied")
+
+		_, err := parameterSink{store: store}.Write(context.Background(), parameterArtifact(small))
+		if err == nil || !strings.Contains(err.Error(), "access denied") || len(store.puts) != 0 {
+			t.Errorf("expected access denied without writes, got %v", err)
+		}
+	})
+}
+
+func TestParameterSink_InterruptedWrite(t *testing.T) {
+	old := largeConfig("# Generated on: 2024-01-01T00:00:00 UTC\n", 600)
+	next := largeConfig("# Generated on: 2024-01-02T00:00:00 UTC\n", 700)
+	nextManifest := manifest{hash: setlist.ContentHash(next)}
+
+	for _, failPut := range []string{
+		nextManifest.chunkName("/setlist/aws-config", 2),
+		"/setlist/aws-config",
+	} {
+		t.Run(failPut, func(t *testing.T) {
+			store := newFakeParameterStore()
+			sink := parameterSink{store: store}
+			if _, err := sink.Write(context.Background(), parameterArtifact(old)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The write stops partway, leaving some of the new chunks
+			// written but the manifest still pointing at the old ones.
+			store.failPut = failPut
+			if _, err := sink.Write(context.Background(), parameterArtifact(next)); err == nil {
+				t.Fatal("expected the write to fail")
+			}
+			if got, _, err := sink.read(context.Background(), "/setlist/aws-config"); err != nil || string(got) != string(old) {
+				t.Errorf("read during the write = %d bytes (err %v), want the old config", len(got), err)
+			}
+
+			// The next run finishes the write and removes the old chunks.
+			store.failPut = ""
+			if _, err := sink.Write(context.Background(), parameterArtifact(next)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, m, err := sink.read(context.Background(), "/setlist/aws-config")
+			if err != nil || string(got) != string(next) {
+				t.Fatalf("read after the write = %d bytes (err %v), want the new config", len(got), err)
+			}
+			if len(store.params) != m.chunks+1 {
+				t.Errorf("parameters = %v, want the manifest and its %d chunks", store.names(), m.chunks)
+			}
+		})
+	}
+}
+
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		limit int
+		want  []string
+	}{
+		{name: "fits", input: "a\nb\n", limit: 8, want: []string{"a\nb\n"}},
+		{name: "breaks after newline", input: "aaa\nbbb\nccc\n", limit: 8, want: []string{"aaa\nbbb\n", "ccc\n"}},
+		{name: "long line", input: "abcdefghij", limit: 4, want: []string{"abcd", "efgh", "ij"}},
+		{name: "multibyte rune", input: "aaé", limit: 3, want: []string{"aa", "é"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := splitChunks(tc.input, tc.limit)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("splitChunks() = %q, want %q", got, tc.want)
+			}
+			for _, chunk := range got {
+				if len(chunk) > tc.limit || !utf8.ValidString(chunk) {
+					t.Errorf("chunk %q is over the limit or invalid UTF-8", chunk)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/scottbrown/setlist"
+)
+
+// keyFormat selects how the generated config file is rendered.
+const keyFormat = "format"
+
+// Output formats.
+const (
+	formatINI  = "ini"
+	formatJSON = "json"
+)
+
+// contentTypes maps each format to the Content-Type it is uploaded with.
+var contentTypes = map[string]string{
+	formatINI:       "text/plain",
+	formatJSON:      "application/json",
+	formatSignature: "text/plain",
+}
+
+// validateFormat checks that format is one the function can render.
+func validateFormat(format string) error {
+	switch format {
+	case formatINI, formatJSON:
+		return nil
+	}
+	return fmt.Errorf("unsupported format %q (supported: %s, %s)", format, formatINI, formatJSON)
+}
+
+// render writes the config file in the given format.
+func render(configFile setlist.ConfigFile, format string) ([]byte, error) {
+	switch format {
+	case formatINI:
+		return renderINI(configFile)
+	case formatJSON:
+		return renderJSON(configFile)
+	}
+	return nil, validateFormat(format)
+}
+
+// renderINI writes the config file as a shared AWS config file.
+func renderINI(configFile setlist.ConfigFile) ([]byte, error) {
+	builder := setlist.NewFileBuilder(configFile)
+	payload, err := builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build config file: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := payload.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write config to buffer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// inventory is the JSON rendering of a config file, for tools that would
+// rather not parse INI. It carries no timestamp, so unchanged profiles
+// render identically.
+type inventory struct {
+	SSOSession  string             `json:"sso_session"`
+	SSOStartURL string             `json:"sso_start_url"`
+	SSORegion   string             `json:"sso_region"`
+	Profiles    []inventoryProfile `json:"profiles"`
+}
+
+// inventoryProfile is one account and permission set pair, along with the
+// two profile names the INI rendering gives it.
+type inventoryProfile struct {
+	Profile         string `json:"profile"`
+	NicknameProfile string `json:"nickname_profile"`
+	AccountId       string `json:"account_id"`
+	Nickname        string `json:"nickname"`
+	RoleName        string `json:"role_name"`
+	Description     string `json:"description"`
+	SessionDuration string `json:"session_duration"`
+}
+
+// renderJSON writes the config file as an inventory.
+func renderJSON(configFile setlist.ConfigFile) ([]byte, error) {
+	inv := inventory{
+		SSOSession:  configFile.SessionName,
+		SSOStartURL: configFile.StartURL(),
+		SSORegion:   configFile.Region.String(),
+		Profiles:    []inventoryProfile{},
+	}
+
+	for _, p := range configFile.Profiles {
+		nickname := configFile.Nickname(p.AccountId.String())
+		inv.Profiles = append(inv.Profiles, inventoryProfile{
+			Profile:         fmt.Sprintf("%s-%s", p.AccountId, p.RoleName),
+			NicknameProfile: fmt.Sprintf("%s-%s", nickname, p.RoleName),
+			AccountId:       p.AccountId.String(),
+			Nickname:        nickname,
+			RoleName:        p.RoleName.String(),
+			Description:     p.Description.String(),
+			SessionDuration: p.SessionDuration.String(),
+		})
+	}
+
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write inventory: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// profileNames returns the profile names in a rendered config file, other
+// than [default].
+func profileNames(data []byte, format string) (map[string]bool, error) {
+	names := map[string]bool{}
+	if len(data) == 0 {
+		return names, nil
+	}
+
+	switch format {
+	case formatJSON:
+		var inv inventory
+		if err := json.Unmarshal(data, &inv); err != nil {
+			return nil, err
+		}
+		for _, p := range inv.Profiles {
+			names[p.Profile] = true
+			names[p.NicknameProfile] = true
+		}
+	default:
+		cfg, err := setlist.ParseSharedConfig(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range cfg.ProfileNames() {
+			if name != "default" {
+				names[name] = true
+			}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderJSON(t *testing.T) {
+	body, err := render(testConfigFile(), formatJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var inv inventory
+	if err := json.Unmarshal(body, &inv); err != nil {
+		t.Fatalf("inventory is not JSON: %v", err)
+	}
+
+	if inv.SSOSession != "corp" || inv.SSORegion != "us-east-1" || inv.SSOStartURL != "https://d-1234567890.awsapps.com/start" {
+		t.Errorf("inventory header = %+v", inv)
+	}
+	if len(inv.Profiles) != 3 {
+		t.Fatalf("got %d profiles, want 3", len(inv.Profiles))
+	}
+	want := inventoryProfile{
+		Profile:         "111111111111-ReadOnly",
+		NicknameProfile: "prod-ReadOnly",
+		AccountId:       "111111111111",
+		Nickname:        "prod",
+		RoleName:        "ReadOnly",
+		Description:     "desc",
+		SessionDuration: "PT1H",
+	}
+	if inv.Profiles[0] != want {
+		t.Errorf("profile = %+v, want %+v", inv.Profiles[0], want)
+	}
+}
+
+func TestProfileNames_MatchAcrossFormats(t *testing.T) {
+	cf := testConfigFile()
+
+	names := map[string]map[string]bool{}
+	for _, format := range []string{formatINI, formatJSON} {
+		body, err := render(cf, format)
+		if err != nil {
+			t.Fatalf("render(%s): %v", format, err)
+		}
+		if names[format], err = profileNames(body, format); err != nil {
+			t.Fatalf("profileNames(%s): %v", format, err)
+		}
+	}
+
+	if !reflect.DeepEqual(names[formatINI], names[formatJSON]) {
+		t.Errorf("ini names %v differ from json names %v", names[formatINI], names[formatJSON])
+	}
+	if !names[formatINI]["prod-Admin"] || names[formatINI]["default"] {
+		t.Errorf("unexpected names %v", names[formatINI])
+	}
+}
+
+func TestRender_UnsupportedFormat(t *testing.T) {
+	if _, err := render(testConfigFile(), "toml"); err == nil || !strings.Contains(err.Error(), "unsupported format") {
+		t.Errorf("expected unsupported format error, got %v", err)
+	}
+}
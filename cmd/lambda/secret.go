@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// secretValueLimit is the most bytes a secret's value holds.
+const secretValueLimit = 65536
+
+// secretStore is the subset of the Secrets Manager API the function uses.
+type secretStore interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+}
+
+// secretSink writes artifacts as Secrets Manager secret strings, creating
+// the secret on first write. Each change is stored as a new version.
+type secretSink struct {
+	store secretStore
+}
+
+func (s secretSink) Write(ctx context.Context, a artifact) (Result, error) {
+	name := a.key
+	result := newResult(a)
+
+	if len(a.body) > secretValueLimit {
+		return result, fmt.Errorf("config for secret %s is %d bytes, more than the %d a secret holds", name, len(a.body), secretValueLimit)
+	}
+
+	previous, err := readSecret(ctx, s.store, name)
+	var notFound *smtypes.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		slog.Info("Creating secret", "secret", name, "hash", result.Hash)
+		_, err = s.store.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+			Name:         aws.String(name),
+			Description:  aws.String("AWS config file generated by setlist"),
+			SecretString: aws.String(string(a.body)),
+		})
+	case err != nil:
+		return result, err
+	case setlist.ContentHash([]byte(previous)) == result.Hash:
+		slog.Info("Config file unchanged, skipping write", "secret", name, "hash", result.Hash)
+		result.Status = StatusUnchanged
+		return result, nil
+	default:
+		result.previous = []byte(previous)
+		slog.Info("Writing config to Secrets Manager", "secret", name, "hash", result.Hash)
+		_, err = s.store.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+			SecretId:     aws.String(name),
+			SecretString: aws.String(string(a.body)),
+		})
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to write secret %s: %w", name, err)
+	}
+
+	result.Status = StatusChanged
+	result.Changed = true
+	return result, nil
+}
+
+func (s secretSink) Stored(ctx context.Context, t target) (string, error) {
+	value, err := s.Read(ctx, t)
+	if err != nil || value == nil {
+		return "", err
+	}
+	return setlist.ContentHash(value), nil
+}
+
+func (s secretSink) Read(ctx context.Context, t target) ([]byte, error) {
+	value, err := readSecret(ctx, s.store, t.key)
+	var notFound *smtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// readSecret returns the current string value of a secret.
+func readSecret(ctx context.Context, store secretStore, id string) (string, error) {
+	out, err := store.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", id, err)
+	}
+	return aws.ToString(out.SecretString), nil
+}
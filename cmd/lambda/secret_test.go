@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// fakeSecretStore is an in-memory Secrets Manager.
+type fakeSecretStore struct {
+	secrets map[string]string
+	getErr  error
+	created []string
+	updated []string
+}
+
+func newFakeSecretStore() *fakeSecretStore {
+	return &fakeSecretStore{secrets: map[string]string{}}
+}
+
+func (f *fakeSecretStore) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	value, ok := f.secrets[aws.ToString(params.SecretId)]
+	if !ok {
+		return nil, &smtypes.ResourceNotFoundException{}
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+func (f *fakeSecretStore) CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+	name := aws.ToString(params.Name)
+	if _, ok := f.secrets[name]; ok {
+		return nil, &smtypes.ResourceExistsException{}
+	}
+	f.secrets[name] = aws.ToString(params.SecretString)
+	f.created = append(f.created, name)
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func (f *fakeSecretStore) PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	id := aws.ToString(params.SecretId)
+	if _, ok := f.secrets[id]; !ok {
+		return nil, &smtypes.ResourceNotFoundException{}
+	}
+	f.secrets[id] = aws.ToString(params.SecretString)
+	f.updated = append(f.updated, id)
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func TestSecretSink_Write(t *testing.T) {
+	body := []byte("# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n")
+	secretArtifact := func(body []byte) artifact {
+		return artifact{target: target{sink: sinkSecretsManager, key: "setlist/aws-config"}, format: formatINI, profiles: 1, body: body}
+	}
+
+	tests := []struct {
+		name         string
+		existing     map[string]string
+		getErr       error
+		body         []byte
+		wantStatus   string
+		wantCreated  int
+		wantUpdated  int
+		wantPrevious string
+		wantErr      string
+	}{
+		{name: "new secret", body: body, wantStatus: StatusChanged, wantCreated: 1},
+		{
+			name:       "unchanged",
+			existing:   map[string]string{"setlist/aws-config": strings.Replace(string(body), "2024", "2023", 1)},
+			body:       body,
+			wantStatus: StatusUnchanged,
+		},
+		{
+			name:         "changed",
+			existing:     map[string]string{"setlist/aws-config": "old"},
+			body:         body,
+			wantStatus:   StatusChanged,
+			wantUpdated:  1,
+			wantPrevious: "old",
+		},
+		{name: "read fails", getErr: errors.New("access denied"), body: body, wantErr: "access denied"},
+		{name: "too large", body: make([]byte, secretValueLimit+1), wantErr: "more than the 65536 a secret holds"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeSecretStore()
+			for k, v := range tc.existing {
+				store.secrets[k] = v
+			}
+			store.getErr = tc.getErr
+
+			result, err := secretSink{store: store}.Write(context.Background(), secretArtifact(tc.body))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.Status != tc.wantStatus {
+				t.Errorf("Status = %q, want %q", result.Status, tc.wantStatus)
+			}
+			if len(store.created) != tc.wantCreated || len(store.updated) != tc.wantUpdated {
+				t.Errorf("created %d and updated %d, want %d and %d", len(store.created), len(store.updated), tc.wantCreated, tc.wantUpdated)
+			}
+			if string(result.previous) != tc.wantPrevious {
+				t.Errorf("previous = %q, want %q", result.previous, tc.wantPrevious)
+			}
+			if result.Location != "secretsmanager:setlist/aws-config" {
+				t.Errorf("Location = %q", result.Location)
+			}
+			if tc.wantStatus == StatusChanged && store.secrets["setlist/aws-config"] != string(tc.body) {
+				t.Errorf("secret = %q", store.secrets["setlist/aws-config"])
+			}
+		})
+	}
+}
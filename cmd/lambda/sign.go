@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// keySigningKeyID names the KMS key outputs are signed with. It may be a
+// key ID, key ARN, alias name or alias ARN.
+const keySigningKeyID = "signing-key-id"
+
+// formatSignature is the format of the detached signature written next to
+// each output.
+const formatSignature = "signature"
+
+// signatureKeyComment starts the comment line naming the key a signature
+// was made with, so a run can tell when the key has changed.
+const signatureKeyComment = "# Key: "
+
+// kmsSignAPI is the subset of the KMS API the function signs with.
+type kmsSignAPI interface {
+	Sign(ctx context.Context, params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error)
+	DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error)
+}
+
+// kmsSigner signs with an ECC_NIST_EDWARDS25519 KMS key, so the private key
+// never leaves KMS.
+type kmsSigner struct {
+	client kmsSignAPI
+	keyID  string
+
+	arn string // keyID resolved by keyArn
+}
+
+// keyArn returns the ARN of the key s signs with. An alias is resolved to
+// the key it points at, so pointing it at a new key counts as a new key.
+// The ARN is looked up once per signer.
+func (s *kmsSigner) keyArn(ctx context.Context) (string, error) {
+	if s.arn != "" {
+		return s.arn, nil
+	}
+	out, err := s.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(s.keyID)})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe signing key %s: %w", s.keyID, err)
+	}
+	s.arn = aws.ToString(out.KeyMetadata.Arn)
+	return s.arn, nil
+}
+
+func (s *kmsSigner) Sign(ctx context.Context, digest []byte) ([]byte, error) {
+	out, err := s.client.Sign(ctx, &kms.SignInput{
+		KeyId:            aws.String(s.keyID),
+		Message:          digest,
+		MessageType:      kmstypes.MessageTypeDigest,
+		SigningAlgorithm: kmstypes.SigningAlgorithmSpecEd25519PhSha512,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Signature, nil
+}
+
+// signerFor returns the signer outputs are signed with, or nil when no
+// signing key is configured.
+func signerFor(resolved settings.Resolved, clients setlist.Clients) *kmsSigner {
+	keyID := resolved.Get(keySigningKeyID)
+	if keyID == "" {
+		return nil
+	}
+	return &kmsSigner{client: clients.KMS, keyID: keyID}
+}
+
+// signIfChanged writes the detached signature of a next to where a is
+// written, and returns its location. The signature goes first, so an output
+// whose write fails is signed again, with its new content, on the next run.
+// An output that is unchanged and already signed with the same key isn't
+// signed again: an Ed25519 signature of the same content with the same key
+// is the same. A signature made with another key, or one that doesn't name
+// its key, is replaced.
+func signIfChanged(ctx context.Context, sinks sinkSet, signer *kmsSigner, a artifact) (target, error) {
+	sigTarget := a.target
+	sigTarget.key += setlist.SignatureExtension
+
+	keyArn, err := signer.keyArn(ctx)
+	if err != nil {
+		return sigTarget, err
+	}
+	stored, err := sinks.Stored(ctx, a.target)
+	if err != nil {
+		return sigTarget, err
+	}
+	if stored == setlist.ContentHash(a.body) {
+		signed, err := sinks.Read(ctx, sigTarget)
+		if err != nil {
+			return sigTarget, err
+		}
+		if key := signedWith(signed); key != "" && key == keyArn {
+			slog.Info("Config file unchanged, skipping signing", "location", a.target.String())
+			return sigTarget, nil
+		}
+	}
+
+	sig, err := signatureArtifact(ctx, signer, a)
+	if err != nil {
+		return sigTarget, err
+	}
+	if _, err := sinks.Write(ctx, sig); err != nil {
+		return sigTarget, err
+	}
+	return sigTarget, nil
+}
+
+// signatureArtifact returns the detached signature of a, to be written next
+// to it, with a comment naming the key it was made with.
+func signatureArtifact(ctx context.Context, signer *kmsSigner, a artifact) (artifact, error) {
+	keyArn, err := signer.keyArn(ctx)
+	if err != nil {
+		return artifact{}, err
+	}
+	sig, err := setlist.SignConfig(ctx, signer, a.body)
+	if err != nil {
+		return artifact{}, err
+	}
+	t := a.target
+	t.key += setlist.SignatureExtension
+	body := append([]byte(signatureKeyComment+keyArn+"\n"), sig...)
+	return artifact{target: t, format: formatSignature, profiles: a.profiles, body: body}, nil
+}
+
+// signedWith returns the key a stored signature names, or "" when it names
+// none.
+func signedWith(signature []byte) string {
+	for _, line := range bytes.Split(signature, []byte("\n")) {
+		if key, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte(signatureKeyComment)); ok {
+			return string(key)
+		}
+	}
+	return ""
+}
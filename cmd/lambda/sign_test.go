@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// testKeyArn is the key fakeKMS describes unless told otherwise.
+const testKeyArn = "arn:aws:kms:us-east-1:111111111111:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+// fakeKMS signs digests with a local Ed25519 key the way KMS signs with an
+// ECC_NIST_EDWARDS25519 key.
+type fakeKMS struct {
+	key ed25519.PrivateKey
+	arn string
+	err error
+
+	input     *kms.SignInput
+	describes int
+}
+
+func (f *fakeKMS) DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	f.describes++
+	arn := f.arn
+	if arn == "" {
+		arn = testKeyArn
+	}
+	return &kms.DescribeKeyOutput{KeyMetadata: &kmstypes.KeyMetadata{Arn: aws.String(arn)}}, nil
+}
+
+func (f *fakeKMS) Sign(ctx context.Context, params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error) {
+	f.input = params
+	if f.err != nil {
+		return nil, f.err
+	}
+	sig, err := f.key.Sign(nil, params.Message, &ed25519.Options{Hash: crypto.SHA512})
+	if err != nil {
+		return nil, err
+	}
+	return &kms.SignOutput{Signature: sig}, nil
+}
+
+func TestSignatureArtifact(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeKMS{key: priv}
+	signer := &kmsSigner{client: client, keyID: "alias/setlist"}
+
+	a := artifact{
+		target:   target{sink: sinkS3, bucket: "configs", key: "aws.config"},
+		format:   formatINI,
+		profiles: 2,
+		body:     []byte("# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n"),
+	}
+	sig, err := signatureArtifact(context.Background(), signer, a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := sig.target.String(); got != "s3://configs/aws.config.sig" {
+		t.Errorf("location = %q", got)
+	}
+	if sig.format != formatSignature || contentTypes[sig.format] != "text/plain" {
+		t.Errorf("format = %q", sig.format)
+	}
+	if err := setlist.VerifyConfig(pub, a.body, sig.body); err != nil {
+		t.Errorf("VerifyConfig() = %v", err)
+	}
+	if got := signedWith(sig.body); got != testKeyArn {
+		t.Errorf("signature names key %q, want %q", got, testKeyArn)
+	}
+
+	in := client.input
+	if aws.ToString(in.KeyId) != "alias/setlist" || in.MessageType != kmstypes.MessageTypeDigest || in.SigningAlgorithm != kmstypes.SigningAlgorithmSpecEd25519PhSha512 {
+		t.Errorf("sign input = %+v", in)
+	}
+}
+
+func TestSignatureArtifact_KMSFails(t *testing.T) {
+	signer := &kmsSigner{client: &fakeKMS{err: errors.New("AccessDeniedException")}, keyID: "alias/setlist"}
+	_, err := signatureArtifact(context.Background(), signer, artifact{body: []byte("[default]\n")})
+	if err == nil || !strings.Contains(err.Error(), "AccessDeniedException") {
+		t.Errorf("expected the KMS error, got %v", err)
+	}
+}
+
+func TestSignIfChanged(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte("# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n")
+	rebuilt := []byte(strings.Replace(string(body), "2024", "2025", 1))
+	a := parameterArtifact(body)
+	signature := a.key + setlist.SignatureExtension
+	signedBy := func(keyArn string) string { return signatureKeyComment + keyArn + "\nsig\n" }
+
+	tests := []struct {
+		name     string
+		stored   map[string]string
+		wantSign bool
+	}{
+		{name: "new output", stored: map[string]string{}, wantSign: true},
+		{name: "unchanged and signed", stored: map[string]string{a.key: string(rebuilt), signature: signedBy(testKeyArn)}},
+		{name: "unchanged but unsigned", stored: map[string]string{a.key: string(body)}, wantSign: true},
+		{name: "unchanged but signed with another key", stored: map[string]string{a.key: string(body), signature: signedBy("arn:aws:kms:us-east-1:111111111111:key/old")}, wantSign: true},
+		{name: "unchanged but signature names no key", stored: map[string]string{a.key: string(body), signature: "sig\n"}, wantSign: true},
+		{name: "changed", stored: map[string]string{a.key: "[default]\n", signature: signedBy(testKeyArn)}, wantSign: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeParameterStore()
+			maps.Copy(store.params, tc.stored)
+			client := &fakeKMS{key: priv}
+			sinks := sinkSet{sinkSSM: parameterSink{store: store}}
+
+			sig, err := signIfChanged(context.Background(), sinks, &kmsSigner{client: client, keyID: "alias/setlist"}, a)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := sig.String(); got != "ssm:/setlist/aws-config.sig" {
+				t.Errorf("signature location = %q", got)
+			}
+			if signed := client.input != nil; signed != tc.wantSign {
+				t.Errorf("signed = %v, want %v", signed, tc.wantSign)
+			}
+			if wrote := slices.Contains(store.puts, signature); wrote != tc.wantSign {
+				t.Errorf("signature written = %v, want %v", wrote, tc.wantSign)
+			}
+			if slices.Contains(store.puts, a.key) {
+				t.Error("the output was written along with its signature")
+			}
+		})
+	}
+}
+
+func TestSignIfChanged_KMSFails(t *testing.T) {
+	store := newFakeParameterStore()
+	sinks := sinkSet{sinkSSM: parameterSink{store: store}}
+	signer := &kmsSigner{client: &fakeKMS{err: errors.New("AccessDeniedException")}, keyID: "alias/setlist"}
+
+	if _, err := signIfChanged(context.Background(), sinks, signer, parameterArtifact([]byte("[default]\n"))); err == nil {
+		t.Fatal("expected the KMS error")
+	}
+	if len(store.puts) != 0 {
+		t.Errorf("wrote %v without a signature", store.puts)
+	}
+}
+
+func TestSignIfChanged_KeyRotated(t *testing.T) {
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPub, newKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newFakeParameterStore()
+	sinks := sinkSet{sinkSSM: parameterSink{store: store}}
+	a := parameterArtifact([]byte("# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n"))
+	signature := a.key + setlist.SignatureExtension
+
+	run := func(client *fakeKMS) {
+		t.Helper()
+		signer := &kmsSigner{client: client, keyID: "alias/setlist"}
+		if _, err := signIfChanged(context.Background(), sinks, signer, a); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := sinks.Write(context.Background(), a); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	oldKMS := &fakeKMS{key: oldKey, arn: "arn:aws:kms:us-east-1:111111111111:key/old"}
+	run(oldKMS)
+	run(oldKMS)
+	var writes int
+	for _, name := range store.puts {
+		if name == signature {
+			writes++
+		}
+	}
+	if writes != 1 {
+		t.Fatalf("signature written %d times before rotating, want once", writes)
+	}
+
+	// The alias now points at a new key: the unchanged output is signed
+	// again, with it.
+	newKMS := &fakeKMS{key: newKey}
+	run(newKMS)
+	if newKMS.input == nil {
+		t.Fatal("unchanged output wasn't signed again after the key changed")
+	}
+	if err := setlist.VerifyConfig(newPub, a.body, []byte(store.params[signature])); err != nil {
+		t.Errorf("VerifyConfig() with the new key = %v", err)
+	}
+
+	newKMS.input = nil
+	run(newKMS)
+	if newKMS.input != nil {
+		t.Error("signed again with the key it is already signed with")
+	}
+	if newKMS.describes != 2 {
+		t.Errorf("described the key %d times, want once per signer", newKMS.describes)
+	}
+}
+
+func TestSignerFor(t *testing.T) {
+	if signer := signerFor(settings.Resolve(), setlist.Clients{}); signer != nil {
+		t.Errorf("signerFor() = %v, want nil without a key", signer)
+	}
+
+	resolved := loadSettings(Event{}, lookupFrom(map[string]string{"SIGNING_KEY_ID": "alias/setlist"}))
+	signer := signerFor(resolved, setlist.Clients{})
+	if signer == nil || signer.keyID != "alias/setlist" {
+		t.Errorf("signerFor() = %+v", signer)
+	}
+}
+
+func TestValidateFormat_Signature(t *testing.T) {
+	if err := validateFormat(formatSignature); err == nil {
+		t.Error("signature accepted as an output format")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scottbrown/setlist"
+)
+
+// Sinks an output can be written to.
+const (
+	sinkS3             = "s3"
+	sinkSSM            = "ssm"
+	sinkSecretsManager = "secretsmanager"
+	sinkGit            = "git"
+)
+
+// sinkNames lists the sinks in the order they are documented.
+var sinkNames = []string{sinkS3, sinkSSM, sinkSecretsManager, sinkGit}
+
+// OutputSink writes artifacts somewhere consumers can read them. Write
+// leaves the destination alone when what it holds has the same content
+// hash as the artifact, and otherwise records the content it replaced in
+// the result so the change can be diffed. Stored returns the content hash
+// of what a target holds, or "" when it holds nothing, and Read returns
+// what it holds, or nil.
+type OutputSink interface {
+	Write(ctx context.Context, a artifact) (Result, error)
+	Stored(ctx context.Context, t target) (string, error)
+	Read(ctx context.Context, t target) ([]byte, error)
+}
+
+// pruner is implemented by sinks that can delete what a split output wrote
+// for groups that no longer exist.
+type pruner interface {
+	// Prune deletes what setlist wrote under t, whose key is a prefix, for
+	// which stale reports true, and returns the locations deleted.
+	Prune(ctx context.Context, t target, stale func(key string) bool) ([]string, error)
+}
+
+// pruningSinks lists the sinks that implement pruner, and so can hold split
+// outputs.
+var pruningSinks = []string{sinkS3, sinkGit}
+
+// newResult returns the result of writing a, before its status is known.
+func newResult(a artifact) Result {
+	return Result{
+		Sink:         a.sink,
+		Location:     a.target.String(),
+		Bucket:       a.bucket,
+		Key:          a.key,
+		Format:       a.format,
+		Hash:         setlist.ContentHash(a.body),
+		ProfileCount: a.profiles,
+	}
+}
+
+// sinkSet routes each artifact to the sink for its target.
+type sinkSet map[string]OutputSink
+
+func (s sinkSet) Write(ctx context.Context, a artifact) (Result, error) {
+	sink, ok := s[a.sink]
+	if !ok {
+		return newResult(a), fmt.Errorf("no sink configured for %s", a.target)
+	}
+	return sink.Write(ctx, a)
+}
+
+func (s sinkSet) Stored(ctx context.Context, t target) (string, error) {
+	sink, ok := s[t.sink]
+	if !ok {
+		return "", fmt.Errorf("no sink configured for %s", t)
+	}
+	return sink.Stored(ctx, t)
+}
+
+func (s sinkSet) Read(ctx context.Context, t target) ([]byte, error) {
+	sink, ok := s[t.sink]
+	if !ok {
+		return nil, fmt.Errorf("no sink configured for %s", t)
+	}
+	return sink.Read(ctx, t)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/scottbrown/setlist"
+)
+
+func TestSinkSet_Write(t *testing.T) {
+	store := newFakeParameterStore()
+	sinks := sinkSet{sinkSSM: parameterSink{store: store}}
+
+	result, err := sinks.Write(context.Background(), parameterArtifact([]byte("[default]\n")))
+	if err != nil || result.Status != StatusChanged || len(store.puts) != 1 {
+		t.Errorf("ssm write = %+v, %v", result, err)
+	}
+
+	_, err = sinks.Write(context.Background(), secretArtifactFor("setlist/aws-config"))
+	if err == nil || !strings.Contains(err.Error(), "no sink configured for secretsmanager:setlist/aws-config") {
+		t.Errorf("expected missing sink error, got %v", err)
+	}
+}
+
+func secretArtifactFor(name string) artifact {
+	return artifact{target: target{sink: sinkSecretsManager, key: name}, format: formatINI, body: []byte("[default]\n")}
+}
+
+func TestWithDiscovery(t *testing.T) {
+	own := setlist.NewClients(aws.Config{Region: "eu-west-1"}, setlist.Endpoints{})
+	assumed := setlist.NewClients(aws.Config{Region: "us-east-1"}, setlist.Endpoints{})
+	clients := withDiscovery(own, assumed)
+
+	if clients.Organizations != assumed.Organizations || clients.SSOAdmin != assumed.SSOAdmin {
+		t.Error("discovery should use the assumed role's clients")
+	}
+
+	resolved := loadSettings(Event{}, lookupFrom(map[string]string{
+		"SNS_TOPIC_ARN":  "arn:aws:sns:eu-west-1:222222222222:setlist",
+		"EVENT_BUS_NAME": "setlist",
+		"SIGNING_KEY_ID": "alias/setlist",
+	}))
+	sinks := outputSinks(resolved, clients)
+	if s3, ok := sinks[sinkS3].(s3Sink); !ok || s3.store != own.S3 {
+		t.Errorf("s3 sink = %#v, want the function's own client", sinks[sinkS3])
+	}
+	if ssm, ok := sinks[sinkSSM].(parameterSink); !ok || ssm.store != own.SSM {
+		t.Errorf("ssm sink = %#v, want the function's own client", sinks[sinkSSM])
+	}
+	if secret, ok := sinks[sinkSecretsManager].(secretSink); !ok || secret.store != own.SecretsManager {
+		t.Errorf("secretsmanager sink = %#v, want the function's own client", sinks[sinkSecretsManager])
+	}
+
+	ps := publishers(resolved, clients)
+	if len(ps) != 2 {
+		t.Fatalf("publishers = %#v", ps)
+	}
+	if sns, ok := ps[0].(snsPublisher); !ok || sns.client != own.SNS {
+		t.Errorf("sns publisher = %#v, want the function's own client", ps[0])
+	}
+	if events, ok := ps[1].(eventBridgePublisher); !ok || events.client != own.EventBridge {
+		t.Errorf("eventbridge publisher = %#v, want the function's own client", ps[1])
+	}
+
+	if signer := signerFor(resolved, clients); signer == nil || signer.client != own.KMS {
+		t.Errorf("signer = %#v, want the function's own client", signer)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// What started an invocation.
+const (
+	triggerManual   = "manual"
+	triggerSchedule = "schedule"
+	triggerChange   = "change"
+)
+
+// scheduledEventType is the detail-type of EventBridge schedule events.
+const scheduledEventType = "Scheduled Event"
+
+// sqsEventSource identifies SQS records in a Lambda event.
+const sqsEventSource = "aws:sqs"
+
+// changeEvents lists, by EventBridge source, the CloudTrail event names
+// that can add or remove profiles.
+var changeEvents = map[string]map[string]bool{
+	"aws.organizations": {
+		"CreateAccountResult": true,
+		"MoveAccount":         true,
+	},
+	"aws.sso": {
+		"CreatePermissionSet":     true,
+		"ProvisionPermissionSet":  true,
+		"CreateAccountAssignment": true,
+	},
+}
+
+// invocation is what a payload asks the function to do.
+type invocation struct {
+	event   Event    // overrides, only from a direct invocation
+	trigger string   // triggerManual, triggerSchedule or triggerChange
+	changes []string // the change events behind a triggerChange run
+	skip    bool     // nothing relevant happened, so don't regenerate
+}
+
+// eventBridgeEvent is the envelope of an event delivered by EventBridge.
+type eventBridgeEvent struct {
+	DetailType string          `json:"detail-type"`
+	Source     string          `json:"source"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// cloudTrailDetail is the part of a CloudTrail event's detail that decides
+// whether it changed anything.
+type cloudTrailDetail struct {
+	EventName           string `json:"eventName"`
+	ErrorCode           string `json:"errorCode"`
+	ServiceEventDetails struct {
+		CreateAccountStatus struct {
+			State string `json:"state"`
+		} `json:"createAccountStatus"`
+	} `json:"serviceEventDetails"`
+}
+
+// sqsEvent is a batch of SQS messages, each holding an EventBridge event
+// forwarded by a rule.
+type sqsEvent struct {
+	Records []struct {
+		EventSource string `json:"eventSource"`
+		Body        string `json:"body"`
+	} `json:"Records"`
+}
+
+// parseInvocation works out why the function was invoked. A schedule always
+// regenerates. Organizations and Identity Center events regenerate only
+// when they can have changed the profiles. An SQS batch of such events, as
+// delivered by the template's debounce queue, regenerates once for the
+// whole batch. Anything else is a direct invocation whose payload is an
+// Event.
+func parseInvocation(payload []byte) (invocation, error) {
+	var envelope map[string]json.RawMessage
+	if trimmed := bytes.TrimSpace(payload); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &envelope); err != nil {
+			return invocation{}, fmt.Errorf("invalid event: %w", err)
+		}
+	}
+
+	if _, ok := envelope["detail-type"]; ok {
+		return classifyEvents([][]byte{payload})
+	}
+
+	if _, ok := envelope["Records"]; ok {
+		var batch sqsEvent
+		if err := json.Unmarshal(payload, &batch); err != nil {
+			return invocation{}, fmt.Errorf("invalid SQS event: %w", err)
+		}
+
+		var events [][]byte
+		for _, r := range batch.Records {
+			if r.EventSource != sqsEventSource {
+				return invocation{}, fmt.Errorf("unsupported record source %q", r.EventSource)
+			}
+			events = append(events, []byte(r.Body))
+		}
+		return classifyEvents(events)
+	}
+
+	event, err := parseEvent(payload)
+	if err != nil {
+		return invocation{}, err
+	}
+	return invocation{event: event, trigger: triggerManual}, nil
+}
+
+// classifyEvents decides what a set of EventBridge events asks for. A
+// schedule among them wins; otherwise the run is a change run if any event
+// is a relevant change, and skipped if none is.
+func classifyEvents(events [][]byte) (invocation, error) {
+	inv := invocation{trigger: triggerChange}
+
+	for _, data := range events {
+		var e eventBridgeEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return invocation{}, fmt.Errorf("invalid EventBridge event: %w", err)
+		}
+
+		if e.DetailType == scheduledEventType {
+			return invocation{trigger: triggerSchedule}, nil
+		}
+
+		change, err := changeName(e)
+		if err != nil {
+			return invocation{}, err
+		}
+		if change == "" {
+			slog.Info("Ignoring event", "source", e.Source, "detail_type", e.DetailType)
+			continue
+		}
+		inv.changes = append(inv.changes, change)
+	}
+
+	inv.skip = len(inv.changes) == 0
+	return inv, nil
+}
+
+// changeName returns "source eventName" for an event that can have changed
+// the profiles, or "" for one that can't: an unlisted event, a failed API
+// call, or an account creation that did not succeed.
+func changeName(e eventBridgeEvent) (string, error) {
+	names, ok := changeEvents[e.Source]
+	if !ok || len(e.Detail) == 0 {
+		return "", nil
+	}
+
+	var detail cloudTrailDetail
+	if err := json.Unmarshal(e.Detail, &detail); err != nil {
+		return "", fmt.Errorf("invalid %s event detail: %w", e.Source, err)
+	}
+
+	if !names[detail.EventName] || detail.ErrorCode != "" {
+		return "", nil
+	}
+	if state := detail.ServiceEventDetails.CreateAccountStatus.State; detail.EventName == "CreateAccountResult" && state != "SUCCEEDED" {
+		return "", nil
+	}
+
+	return e.Source + " " + detail.EventName, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseInvocation_Fixtures(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		wantTrigger string
+		wantChanges []string
+		wantSkip    bool
+	}{
+		{fixture: "scheduled.json", wantTrigger: triggerSchedule},
+		{fixture: "create_account_result.json", wantTrigger: triggerChange, wantChanges: []string{"aws.organizations CreateAccountResult"}},
+		{fixture: "create_account_result_failed.json", wantTrigger: triggerChange, wantSkip: true},
+		{fixture: "move_account.json", wantTrigger: triggerChange, wantChanges: []string{"aws.organizations MoveAccount"}},
+		{fixture: "create_permission_set.json", wantTrigger: triggerChange, wantChanges: []string{"aws.sso CreatePermissionSet"}},
+		{fixture: "provision_permission_set.json", wantTrigger: triggerChange, wantChanges: []string{"aws.sso ProvisionPermissionSet"}},
+		{fixture: "create_account_assignment.json", wantTrigger: triggerChange, wantChanges: []string{"aws.sso CreateAccountAssignment"}},
+		{fixture: "create_account_assignment_denied.json", wantTrigger: triggerChange, wantSkip: true},
+		{fixture: "describe_account.json", wantTrigger: triggerChange, wantSkip: true},
+		{
+			fixture:     "sqs_changes.json",
+			wantTrigger: triggerChange,
+			wantChanges: []string{"aws.sso CreatePermissionSet", "aws.sso ProvisionPermissionSet"},
+		},
+		{fixture: "sqs_irrelevant.json", wantTrigger: triggerChange, wantSkip: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.fixture, func(t *testing.T) {
+			inv, err := parseInvocation(readFixture(t, tc.fixture))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if inv.trigger != tc.wantTrigger {
+				t.Errorf("trigger = %q, want %q", inv.trigger, tc.wantTrigger)
+			}
+			if !reflect.DeepEqual(inv.changes, tc.wantChanges) {
+				t.Errorf("changes = %v, want %v", inv.changes, tc.wantChanges)
+			}
+			if inv.skip != tc.wantSkip {
+				t.Errorf("skip = %v, want %v", inv.skip, tc.wantSkip)
+			}
+			if inv.event != (Event{}) {
+				t.Errorf("event = %+v, want no overrides", inv.event)
+			}
+		})
+	}
+}
+
+func TestParseInvocation_Direct(t *testing.T) {
+	inv, err := parseInvocation([]byte(`{"s3_key":"readonly.config"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inv.trigger != triggerManual || inv.skip || inv.event.S3Key != "readonly.config" {
+		t.Errorf("invocation = %+v", inv)
+	}
+
+	inv, err = parseInvocation(nil)
+	if err != nil || inv.trigger != triggerManual || inv.skip {
+		t.Errorf("empty payload = %+v, %v", inv, err)
+	}
+}
+
+func TestParseInvocation_ScheduleInBatchWins(t *testing.T) {
+	batch := `{"Records":[{"eventSource":"aws:sqs","body":` + quote(readFixture(t, "describe_account.json")) + `},` +
+		`{"eventSource":"aws:sqs","body":` + quote(readFixture(t, "scheduled.json")) + `}]}`
+
+	inv, err := parseInvocation([]byte(batch))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inv.trigger != triggerSchedule || inv.skip {
+		t.Errorf("invocation = %+v, want a schedule run", inv)
+	}
+}
+
+func TestParseInvocation_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{name: "bad record source", payload: `{"Records":[{"eventSource":"aws:sns","body":"{}"}]}`, wantErr: `unsupported record source "aws:sns"`},
+		{name: "bad record body", payload: `{"Records":[{"eventSource":"aws:sqs","body":"not json"}]}`, wantErr: "invalid EventBridge event"},
+		{name: "bad detail", payload: `{"detail-type":"AWS API Call via CloudTrail","source":"aws.sso","detail":"oops"}`, wantErr: "invalid aws.sso event detail"},
+		{name: "truncated", payload: `{"detail-type":`, wantErr: "invalid event"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseInvocation([]byte(tc.payload))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+// quote returns data as a JSON string literal.
+func quote(data []byte) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range string(data) {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// hashMetadataKey is the object metadata (x-amz-meta-setlist-hash) holding
+// the content hash of the uploaded config file.
+const hashMetadataKey = "setlist-hash"
+
+// Statuses reported in Result and Response. StatusSkipped means the
+// invocation's events could not have changed anything, so nothing was
+// generated.
+const (
+	StatusChanged   = "changed"
+	StatusUnchanged = "unchanged"
+	StatusSkipped   = "skipped"
+)
+
+// Response is what the function returns to its invoker, shaped for use as
+// a Step Functions task output.
+type Response struct {
+	Status       string   `json:"status"`
+	Changed      bool     `json:"changed"`
+	Trigger      string   `json:"trigger"`
+	Changes      []string `json:"changes,omitempty"`
+	ProfileCount int      `json:"profile_count"`
+	Outputs      []Result `json:"outputs"`
+	Deleted      []string `json:"deleted,omitempty"`
+}
+
+// add records an output's result. The response is changed when any output
+// is.
+func (r *Response) add(result Result) {
+	r.Outputs = append(r.Outputs, result)
+	if result.Changed {
+		r.Status = StatusChanged
+		r.Changed = true
+	}
+}
+
+// delete records the stale split outputs removed. Removing any changes the
+// response.
+func (r *Response) delete(locations []string) {
+	r.Deleted = append(r.Deleted, locations...)
+	if len(locations) > 0 {
+		r.Status = StatusChanged
+		r.Changed = true
+	}
+}
+
+// Result is the outcome of writing one output. Bucket is only set for the
+// s3 sink, and Signature only when outputs are signed.
+type Result struct {
+	Status       string `json:"status"`
+	Changed      bool   `json:"changed"`
+	Sink         string `json:"sink"`
+	Location     string `json:"location"`
+	Bucket       string `json:"bucket,omitempty"`
+	Key          string `json:"key"`
+	Format       string `json:"format"`
+	Hash         string `json:"hash"`
+	ProfileCount int    `json:"profile_count"`
+	Signature    string `json:"signature,omitempty"`
+
+	// previous is the config file that was replaced, empty when nothing
+	// was there or the write was skipped.
+	previous []byte
+}
+
+// objectStore is the subset of the S3 API the function uploads with.
+type objectStore interface {
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// s3Sink writes artifacts as S3 objects. With readPrevious set, the
+// content an upload replaces is read first, for change notifications to
+// diff against.
+type s3Sink struct {
+	store        objectStore
+	readPrevious bool
+}
+
+func (s s3Sink) Write(ctx context.Context, a artifact) (Result, error) {
+	return uploadIfChanged(ctx, s.store, a, s.readPrevious)
+}
+
+// Stored reads the hash from the object's metadata. An object uploaded
+// without it reports its ETag, which never matches a content hash.
+func (s s3Sink) Stored(ctx context.Context, t target) (string, error) {
+	head, err := s.store.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(t.bucket), Key: aws.String(t.key)})
+	if isNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect s3://%s/%s: %w", t.bucket, t.key, err)
+	}
+	if hash := head.Metadata[hashMetadataKey]; hash != "" {
+		return hash, nil
+	}
+	return aws.ToString(head.ETag), nil
+}
+
+func (s s3Sink) Read(ctx context.Context, t target) ([]byte, error) {
+	data, err := readVersion(ctx, s.store, t.bucket, t.key, nil)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Prune deletes the stale objects under the prefix that carry the hash
+// metadata every upload sets, so objects setlist didn't write are left
+// alone.
+func (s s3Sink) Prune(ctx context.Context, t target, stale func(key string) bool) ([]string, error) {
+	var deleted []string
+	pages := s3.NewListObjectsV2Paginator(s.store, &s3.ListObjectsV2Input{
+		Bucket: aws.String(t.bucket),
+		Prefix: aws.String(t.key),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to list s3://%s/%s: %w", t.bucket, t.key, err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if !stale(key) {
+				continue
+			}
+			head, err := s.store.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(t.bucket), Key: aws.String(key)})
+			if err != nil {
+				return deleted, fmt.Errorf("failed to inspect s3://%s/%s: %w", t.bucket, key, err)
+			}
+			if head.Metadata[hashMetadataKey] == "" {
+				continue
+			}
+
+			slog.Info("Deleting stale config", "bucket", t.bucket, "key", key)
+			if _, err := s.store.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(t.bucket), Key: aws.String(key)}); err != nil {
+				return deleted, fmt.Errorf("failed to delete s3://%s/%s: %w", t.bucket, key, err)
+			}
+			deleted = append(deleted, "s3://"+t.bucket+"/"+key)
+		}
+	}
+	return deleted, nil
+}
+
+// uploadIfChanged uploads an artifact unless the object already there
+// carries the same content hash, which is checked with HeadObject so an
+// unchanged object is never downloaded. The upload is conditional on the
+// object not having changed since it was inspected: If-Match on its ETag
+// when it exists, If-None-Match: * when it does not. Objects written
+// without the hash metadata are always replaced. With readPrevious set, the
+// object being replaced is downloaded into the result.
+func uploadIfChanged(ctx context.Context, store objectStore, a artifact, readPrevious bool) (Result, error) {
+	bucket, key := a.bucket, a.key
+	result := newResult(a)
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(a.body),
+		ContentType: aws.String(contentTypes[a.format]),
+		Metadata:    map[string]string{hashMetadataKey: result.Hash},
+	}
+
+	current, err := store.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	switch {
+	case isNotFound(err):
+		input.IfNoneMatch = aws.String("*")
+	case err != nil:
+		return result, fmt.Errorf("failed to inspect s3://%s/%s: %w", bucket, key, err)
+	case current.Metadata[hashMetadataKey] == result.Hash:
+		slog.Info("Config file unchanged, skipping upload", "bucket", bucket, "key", key, "hash", result.Hash)
+		result.Status = StatusUnchanged
+		return result, nil
+	default:
+		input.IfMatch = current.ETag
+		if readPrevious {
+			if result.previous, err = readVersion(ctx, store, bucket, key, current.ETag); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	slog.Info("Uploading config to S3", "bucket", bucket, "key", key, "hash", result.Hash)
+	if _, err := store.PutObject(ctx, input); err != nil {
+		if isPreconditionFailed(err) {
+			return result, fmt.Errorf("s3://%s/%s was modified during the upload; it will be retried on the next run: %w", bucket, key, err)
+		}
+		return result, fmt.Errorf("failed to upload to S3: %w", err)
+	}
+
+	result.Status = StatusChanged
+	result.Changed = true
+	return result, nil
+}
+
+// readVersion downloads the object with the given ETag, failing if it has
+// been replaced since.
+func readVersion(ctx context.Context, store objectStore, bucket, key string, etag *string) ([]byte, error) {
+	out, err := store.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		IfMatch: etag,
+	})
+	if isPreconditionFailed(err) {
+		return nil, fmt.Errorf("s3://%s/%s was modified during the upload; it will be retried on the next run: %w", bucket, key, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3://%s/%s: %w", bucket, key, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3://%s/%s: %w", bucket, key, err)
+	}
+	return data, nil
+}
+
+// isNotFound reports whether err is a GetObject or HeadObject miss.
+func isNotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NoSuchKey" || apiErr.ErrorCode() == "NotFound")
+}
+
+// isPreconditionFailed reports whether err is a failed If-Match or
+// If-None-Match condition. S3 answers 409 ConditionalRequestConflict when a
+// competing conditional write is in flight.
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	}
+	return false
+}
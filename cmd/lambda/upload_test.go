@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// stubObjectStore holds at most one object, get. HeadObject describes it,
+// or fails with headErr or, when there is no object, getErr.
+type stubObjectStore struct {
+	get     *s3.GetObjectOutput
+	getErr  error
+	headErr error
+	putErr  error
+
+	gets    []*s3.GetObjectInput
+	put     *s3.PutObjectInput
+	putBody string
+}
+
+func (s *stubObjectStore) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if s.headErr != nil {
+		return nil, s.headErr
+	}
+	if s.get == nil {
+		return nil, s.getErr
+	}
+	return &s3.HeadObjectOutput{ETag: s.get.ETag, Metadata: s.get.Metadata}, nil
+}
+
+func (s *stubObjectStore) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	s.gets = append(s.gets, params)
+	return s.get, s.getErr
+}
+
+func (s *stubObjectStore) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return &s3.ListObjectsV2Output{}, nil
+}
+
+func (s *stubObjectStore) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	return nil, errors.New("stubObjectStore does not delete")
+}
+
+func existingObject(etag, hash, body string) *s3.GetObjectOutput {
+	out := &s3.GetObjectOutput{
+		ETag: aws.String(etag),
+		Body: io.NopCloser(strings.NewReader(body)),
+	}
+	if hash != "" {
+		out.Metadata = map[string]string{hashMetadataKey: hash}
+	}
+	return out
+}
+
+func (s *stubObjectStore) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	s.put = params
+	body, _ := io.ReadAll(params.Body)
+	s.putBody = string(body)
+	return &s3.PutObjectOutput{}, s.putErr
+}
+
+func TestUploadIfChanged(t *testing.T) {
+	body := []byte("# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = my-sso\n")
+	hash := setlist.ContentHash(body)
+
+	tests := []struct {
+		name            string
+		store           *stubObjectStore
+		readPrevious    bool
+		wantStatus      string
+		wantGets        int
+		wantPut         bool
+		wantIfMatch     string
+		wantIfNoneMatch string
+		wantPrevious    string
+		wantErr         string
+	}{
+		{
+			name:            "new object",
+			store:           &stubObjectStore{getErr: &types.NoSuchKey{}},
+			wantStatus:      StatusChanged,
+			wantPut:         true,
+			wantIfNoneMatch: "*",
+		},
+		{
+			name:            "new object, head miss",
+			store:           &stubObjectStore{headErr: &types.NotFound{}},
+			readPrevious:    true,
+			wantStatus:      StatusChanged,
+			wantPut:         true,
+			wantIfNoneMatch: "*",
+		},
+		{
+			name:         "unchanged",
+			store:        &stubObjectStore{get: existingObject(`"abc"`, hash, "")},
+			readPrevious: true,
+			wantStatus:   StatusUnchanged,
+		},
+		{
+			name:         "changed",
+			store:        &stubObjectStore{get: existingObject(`"abc"`, "stale", "old")},
+			readPrevious: true,
+			wantStatus:   StatusChanged,
+			wantGets:     1,
+			wantPut:      true,
+			wantIfMatch:  `"abc"`,
+			wantPrevious: "old",
+		},
+		{
+			name:        "changed, nothing to diff",
+			store:       &stubObjectStore{get: existingObject(`"abc"`, "stale", "old")},
+			wantStatus:  StatusChanged,
+			wantPut:     true,
+			wantIfMatch: `"abc"`,
+		},
+		{
+			name:         "written without hash metadata",
+			store:        &stubObjectStore{get: existingObject(`"abc"`, "", "old")},
+			readPrevious: true,
+			wantStatus:   StatusChanged,
+			wantGets:     1,
+			wantPut:      true,
+			wantIfMatch:  `"abc"`,
+			wantPrevious: "old",
+		},
+		{
+			name:    "head fails",
+			store:   &stubObjectStore{headErr: errors.New("access denied")},
+			wantErr: "failed to inspect s3://bucket/key",
+		},
+		{
+			name: "replaced between head and get",
+			store: &stubObjectStore{
+				get:    existingObject(`"abc"`, "stale", "old"),
+				getErr: &smithy.GenericAPIError{Code: "PreconditionFailed"},
+			},
+			readPrevious: true,
+			wantGets:     1,
+			wantErr:      "was modified during the upload",
+		},
+		{
+			name: "modified concurrently",
+			store: &stubObjectStore{
+				get:    existingObject(`"abc"`, "", "old"),
+				putErr: &smithy.GenericAPIError{Code: "PreconditionFailed"},
+			},
+			readPrevious: true,
+			wantGets:     1,
+			wantPut:      true,
+			wantIfMatch:  `"abc"`,
+			wantPrevious: "old",
+			wantErr:      "was modified during the upload",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := uploadIfChanged(context.Background(), tc.store, artifact{target: target{sink: sinkS3, bucket: "bucket", key: "key"}, format: formatINI, profiles: 1, body: body}, tc.readPrevious)
+
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.Status != tc.wantStatus {
+				t.Errorf("Status = %q, want %q", result.Status, tc.wantStatus)
+			}
+			if result.Changed != (tc.wantStatus == StatusChanged) {
+				t.Errorf("Changed = %v with status %q", result.Changed, result.Status)
+			}
+			if string(result.previous) != tc.wantPrevious {
+				t.Errorf("previous = %q, want %q", result.previous, tc.wantPrevious)
+			}
+			if result.ProfileCount != 1 || result.Format != formatINI {
+				t.Errorf("ProfileCount = %d, Format = %q", result.ProfileCount, result.Format)
+			}
+			if result.Hash != hash {
+				t.Errorf("Hash = %q, want %q", result.Hash, hash)
+			}
+
+			if len(tc.store.gets) != tc.wantGets {
+				t.Errorf("GetObject called %d times, want %d", len(tc.store.gets), tc.wantGets)
+			}
+			for _, get := range tc.store.gets {
+				if aws.ToString(get.IfMatch) != `"abc"` {
+					t.Errorf("GetObject IfMatch = %q, want the ETag HeadObject saw", aws.ToString(get.IfMatch))
+				}
+			}
+
+			if (tc.store.put != nil) != tc.wantPut {
+				t.Fatalf("PutObject called = %v, want %v", tc.store.put != nil, tc.wantPut)
+			}
+			if !tc.wantPut {
+				return
+			}
+
+			if got := aws.ToString(tc.store.put.IfMatch); got != tc.wantIfMatch {
+				t.Errorf("IfMatch = %q, want %q", got, tc.wantIfMatch)
+			}
+			if got := aws.ToString(tc.store.put.IfNoneMatch); got != tc.wantIfNoneMatch {
+				t.Errorf("IfNoneMatch = %q, want %q", got, tc.wantIfNoneMatch)
+			}
+			if got := tc.store.put.Metadata[hashMetadataKey]; got != hash {
+				t.Errorf("hash metadata = %q, want %q", got, hash)
+			}
+			if got := aws.ToString(tc.store.put.ContentType); got != "text/plain" {
+				t.Errorf("ContentType = %q, want text/plain", got)
+			}
+			if tc.store.putBody != string(body) {
+				t.Errorf("uploaded body = %q, want %q", tc.store.putBody, body)
+			}
+		})
+	}
+}
+
+// fakeBucket holds objects by key, with their metadata, and lists them a
+// page at a time.
+type fakeBucket struct {
+	stubObjectStore
+	objects  map[string]map[string]string
+	pageSize int
+	deleted  []string
+}
+
+func (b *fakeBucket) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	metadata, ok := b.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{Metadata: metadata}, nil
+}
+
+func (b *fakeBucket) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for key := range b.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) && key > aws.ToString(params.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsV2Output{}
+	if len(keys) > b.pageSize {
+		keys = keys[:b.pageSize]
+		out.IsTruncated = aws.Bool(true)
+		out.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+	}
+	return out, nil
+}
+
+func (b *fakeBucket) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	key := aws.ToString(params.Key)
+	delete(b.objects, key)
+	b.deleted = append(b.deleted, key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3Sink_Prune(t *testing.T) {
+	written := map[string]string{hashMetadataKey: "abc"}
+	bucket := &fakeBucket{pageSize: 2, objects: map[string]map[string]string{
+		"ou/Root/Prod.config":     written,
+		"ou/Root/Gone.config":     written,
+		"ou/Root/Gone.config.sig": written,
+		"ou/Root/Manual.config":   {},
+		"ou/notes.txt":            written,
+		"all.config":              written,
+	}}
+	sink := s3Sink{store: bucket}
+
+	stale := func(key string) bool {
+		key = strings.TrimSuffix(key, setlist.SignatureExtension)
+		return strings.HasSuffix(key, ".config") && key != "ou/Root/Prod.config"
+	}
+	deleted, err := sink.Prune(context.Background(), target{sink: sinkS3, bucket: "configs", key: "ou/"}, stale)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"s3://configs/ou/Root/Gone.config", "s3://configs/ou/Root/Gone.config.sig"}
+	if !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted = %v, want %v", deleted, want)
+	}
+	if _, ok := bucket.objects["ou/Root/Manual.config"]; !ok {
+		t.Error("deleted an object setlist didn't write")
+	}
+}
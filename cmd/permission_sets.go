@@ -7,7 +7,6 @@ import (
 
 	"github.com/scottbrown/setlist"
 
-	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
 	ssotypes "github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
 	"github.com/spf13/cobra"
 )
@@ -23,6 +22,8 @@ var permissionSetsCmd = &cobra.Command{
 }
 
 func init() {
+	addInstanceFlags(permissionSetsCmd)
+
 	rootCmd.AddCommand(permissionSetsCmd)
 }
 
@@ -36,16 +37,19 @@ func handlePermissionSetsCommand(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	ssoClient := ssoadmin.NewFromConfig(cfg)
+	ssoClient, _, err := newAPIClients(ctx, cfg)
+	if err != nil {
+		return err
+	}
 
 	return handleListPermissionSetsFlow(ctx, ssoClient)
 }
 
 func handleListPermissionSetsFlow(ctx context.Context, ssoClient setlist.SSOAdminClient) error {
 	slog.Info("Retrieving SSO instance")
-	instance, err := setlist.SsoInstance(ctx, ssoClient)
+	instance, err := selectInstance(ctx, ssoClient)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve SSO instance: %w", err)
+		return err
 	}
 
 	return handleListPermissionSets(ctx, ssoClient, instance)
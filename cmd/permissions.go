@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/scottbrown/setlist"
 
@@ -11,21 +12,46 @@ import (
 var permissionsCmd = &cobra.Command{
 	Use:   "permissions",
 	Short: "Listing required AWS permissions",
-	Long:  "Listing all AWS IAM permissions required by this tool to function correctly",
+	Long:  "Listing all AWS IAM permissions required by this tool to function correctly, followed by those only some options need. With --filter, the permissions that filter needs are included.",
 	RunE:  handlePermissionsCommand,
 }
 
 func init() {
+	permissionsCmd.Flags().StringVar(&filter, FlagFilter, "", "CEL expression to list the additional permissions of")
 	rootCmd.AddCommand(permissionsCmd)
 }
 
 func handlePermissionsCommand(cmd *cobra.Command, args []string) error {
-	return handleListPermissions()
+	return handleListPermissions(cmd.OutOrStdout())
 }
 
-func handleListPermissions() error {
-	for _, p := range setlist.ListPermissionsRequired() {
-		fmt.Println(p)
+// handleListPermissions writes one permission per line. Permissions needed
+// only with some options follow under a "#" comment naming the option.
+// When a filter is set, the permissions it needs are listed with the rest
+// instead.
+func handleListPermissions(w io.Writer) error {
+	perms := setlist.ListPermissionsRequired()
+	if filter != "" {
+		f, err := setlist.NewFilter(filter)
+		if err != nil {
+			return err
+		}
+		perms = append(perms, setlist.FilterPermissionsRequired(f)...)
+	}
+	for _, p := range perms {
+		fmt.Fprintln(w, p)
+	}
+
+	fmt.Fprintf(w, "\n# With --%s, on the credentials that assume the role:\n", FlagAssumeRoleArn)
+	for _, p := range setlist.ListAssumeRolePermissionsRequired() {
+		fmt.Fprintln(w, p)
+	}
+
+	if filter == "" {
+		fmt.Fprintf(w, "\n# With a --%s that references tags or ou_path:\n", FlagFilter)
+		for _, p := range setlist.ListFilterPermissionsRequired() {
+			fmt.Fprintln(w, p)
+		}
 	}
 	return nil
 }
@@ -2,26 +2,15 @@ package main
 
 import (
 	"bytes"
-	"os"
 	"strings"
 	"testing"
 )
 
 func TestHandleListPermissions(t *testing.T) {
-	oldStdout := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	err := handleListPermissions()
-	w.Close()
-	os.Stdout = oldStdout
-
-	if err != nil {
+	var buf bytes.Buffer
+	if err := handleListPermissions(&buf); err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
-
-	var buf bytes.Buffer
-	buf.ReadFrom(r)
 	output := buf.String()
 
 	expected := []string{
@@ -30,6 +19,7 @@ func TestHandleListPermissions(t *testing.T) {
 		"sso:ListPermissionSets",
 		"sso:ListPermissionSetsProvisionedToAccount",
 		"sso:DescribePermissionSet",
+		"sts:GetCallerIdentity",
 	}
 
 	for _, perm := range expected {
@@ -37,4 +27,71 @@ func TestHandleListPermissions(t *testing.T) {
 			t.Errorf("Expected output to contain %q, got: %s", perm, output)
 		}
 	}
+
+	// sts:AssumeRole is listed, but only under the option that needs it.
+	_, conditional, ok := strings.Cut(output, "# With --assume-role-arn")
+	if !ok || !strings.Contains(conditional, "sts:AssumeRole") {
+		t.Errorf("Expected sts:AssumeRole under --assume-role-arn, got: %s", output)
+	}
+}
+
+func TestHandleListPermissions_Filter(t *testing.T) {
+	t.Cleanup(resetGlobals)
+
+	tests := []struct {
+		name    string
+		filter  string
+		want    []string
+		notWant []string
+		wantErr bool
+	}{
+		{
+			name: "no filter",
+			want: []string{"# With a --filter that references tags or ou_path:", "organizations:ListTagsForResource", "organizations:ListParents"},
+		},
+		{
+			name:    "tags",
+			filter:  `account.tags.env == "prod"`,
+			want:    []string{"organizations:ListTagsForResource", "sso:ListTagsForResource"},
+			notWant: []string{"# With a --filter", "organizations:ListParents"},
+		},
+		{
+			name:    "no extra permissions",
+			filter:  `account.name.contains("tags")`,
+			notWant: []string{"# With a --filter", "ListTagsForResource", "organizations:ListParents"},
+		},
+		{
+			name:    "invalid",
+			filter:  `account.name ==`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filter = tc.filter
+			var buf bytes.Buffer
+			err := handleListPermissions(&buf)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			output := buf.String()
+			for _, s := range tc.want {
+				if !strings.Contains(output, s) {
+					t.Errorf("Expected output to contain %q, got: %s", s, output)
+				}
+			}
+			for _, s := range tc.notWant {
+				if strings.Contains(output, s) {
+					t.Errorf("Expected output not to contain %q, got: %s", s, output)
+				}
+			}
+		})
+	}
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
@@ -10,6 +11,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/spf13/cobra"
 )
 
@@ -24,7 +26,22 @@ func init() {
 	rootCmd.SetVersionTemplate(fmt.Sprintf("{{.Name}} version %s (%s)\n", setlist.VERSION, setlist.COMMIT))
 }
 
+// endpoints returns the endpoint overrides set by the --*endpoint-url flags.
+func endpoints() setlist.Endpoints {
+	return setlist.Endpoints{
+		Default:       endpointURL,
+		Organizations: orgEndpointURL,
+		SSOAdmin:      ssoAdminEndpointURL,
+		S3:            s3EndpointURL,
+		STS:           stsEndpointURL,
+	}
+}
+
 func loadAWSConfig(ctx context.Context) (aws.Config, error) {
+	if err := endpoints().Validate(); err != nil {
+		return aws.Config{}, err
+	}
+
 	opts := []func(*config.LoadOptions) error{
 		config.WithRegion(ssoRegion),
 		config.WithRetryMaxAttempts(10),
@@ -42,9 +59,66 @@ func loadAWSConfig(ctx context.Context) (aws.Config, error) {
 		}
 		return aws.Config{}, fmt.Errorf("failed to load AWS configuration: %w", err)
 	}
+
+	if assumeRoleArn == "" {
+		return cfg, nil
+	}
+
+	slog.Info("Assuming role", "role_arn", assumeRoleArn, "session_name", roleSessionName)
+	cfg, err = setlist.AssumeRole(cfg, setlist.NewClients(cfg, endpoints()).STS, setlist.AssumeRoleInput{
+		RoleArn:         assumeRoleArn,
+		ExternalId:      externalId,
+		RoleSessionName: roleSessionName,
+		MFASerial:       mfaSerial,
+		TokenProvider:   mfaTokenProvider(os.Stdin, os.Stderr),
+	})
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to assume role %s: %w", assumeRoleArn, err)
+	}
 	return cfg, nil
 }
 
+// mfaTokenProvider returns --mfa-token if set, and otherwise prompts for
+// the code on out.
+func mfaTokenProvider(in io.Reader, out io.Writer) func() (string, error) {
+	return func() (string, error) {
+		if mfaToken != "" {
+			return mfaToken, nil
+		}
+		return newPrompter(in, out).ask(fmt.Sprintf("MFA code for %s", mfaSerial), "")
+	}
+}
+
+// newAPIClients builds the SSO Admin and Organizations clients for cfg,
+// honouring the endpoint overrides. Unless caching is disabled, both are
+// wrapped with an on-disk cache scoped to the account that owns the
+// credentials and the configured region.
+func newAPIClients(ctx context.Context, cfg aws.Config) (setlist.SSOAdminClient, setlist.OrganizationsClient, error) {
+	clients := setlist.NewClients(cfg, endpoints())
+	var ssoClient setlist.SSOAdminClient = clients.SSOAdmin
+	var orgClient setlist.OrganizationsClient = clients.Organizations
+
+	if noCache {
+		return ssoClient, orgClient, nil
+	}
+
+	identity, err := clients.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to determine caller identity: %w", err)
+	}
+
+	dir, err := setlist.DefaultCacheDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cache := setlist.NewCache(dir, setlist.CacheScope(aws.ToString(identity.Account), cfg.Region), cacheTTL)
+	cache.Refresh = refreshCache
+	slog.Info("Using API response cache", "dir", dir, "scope", cache.Scope, "ttl", cache.TTL.String(), "refresh", cache.Refresh)
+
+	return setlist.NewCachedSSOAdminClient(ssoClient, cache), setlist.NewCachedOrganizationsClient(orgClient, cache), nil
+}
+
 func outputConfig(configFile setlist.ConfigFile) error {
 	builder := setlist.NewFileBuilder(configFile)
 	payload, err := builder.Build()
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -142,3 +143,87 @@ func TestOutputConfigInvalidConfig(t *testing.T) {
 		t.Error("Expected error for invalid config, got nil")
 	}
 }
+
+func TestLoadAWSConfig_AssumeRole(t *testing.T) {
+	t.Cleanup(resetGlobals)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	t.Run("invalid role", func(t *testing.T) {
+		resetGlobals()
+		ssoRegion = "us-east-1"
+		assumeRoleArn = "SetlistReader"
+
+		_, err := loadAWSConfig(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "failed to assume role SetlistReader") {
+			t.Errorf("error = %v, want an assume role error", err)
+		}
+	})
+
+	t.Run("valid role", func(t *testing.T) {
+		resetGlobals()
+		ssoRegion = "us-east-1"
+		assumeRoleArn = "arn:aws:iam::123456789012:role/SetlistReader"
+
+		cfg, err := loadAWSConfig(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := cfg.Credentials.(*aws.CredentialsCache); !ok {
+			t.Errorf("Credentials = %T, want the assumed role provider", cfg.Credentials)
+		}
+	})
+}
+
+func TestMFATokenProvider(t *testing.T) {
+	t.Cleanup(resetGlobals)
+
+	t.Run("flag", func(t *testing.T) {
+		resetGlobals()
+		mfaToken = "111111"
+		token, err := mfaTokenProvider(strings.NewReader(""), &bytes.Buffer{})()
+		if err != nil || token != "111111" {
+			t.Errorf("token = %q, %v, want the flag value", token, err)
+		}
+	})
+
+	t.Run("prompt", func(t *testing.T) {
+		resetGlobals()
+		mfaSerial = "arn:aws:iam::123456789012:mfa/alice"
+		var out bytes.Buffer
+		token, err := mfaTokenProvider(strings.NewReader("222222\n"), &out)()
+		if err != nil || token != "222222" {
+			t.Errorf("token = %q, %v, want the prompted value", token, err)
+		}
+		if !strings.Contains(out.String(), "MFA code for arn:aws:iam::123456789012:mfa/alice") {
+			t.Errorf("prompt = %q", out.String())
+		}
+	})
+}
+
+func TestEndpoints(t *testing.T) {
+	t.Cleanup(resetGlobals)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+
+	t.Run("flags", func(t *testing.T) {
+		resetGlobals()
+		endpointURL = "http://localhost:4566"
+		ssoAdminEndpointURL = "http://localhost:9000"
+
+		got := endpoints()
+		if got.Default != "http://localhost:4566" || got.SSOAdmin != "http://localhost:9000" || got.Organizations != "" {
+			t.Errorf("endpoints() = %+v", got)
+		}
+	})
+
+	t.Run("invalid endpoint", func(t *testing.T) {
+		resetGlobals()
+		ssoRegion = "us-east-1"
+		s3EndpointURL = "localhost:4566"
+
+		_, err := loadAWSConfig(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "s3: invalid endpoint") {
+			t.Errorf("error = %v, want an invalid endpoint error", err)
+		}
+	})
+}
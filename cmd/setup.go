@@ -1,11 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
-	"strings"
+
+	"github.com/scottbrown/setlist"
 
 	"github.com/spf13/cobra"
 )
@@ -15,18 +17,18 @@ func validateRequiredFlags(cmd *cobra.Command) error {
 		return fmt.Errorf("required flag --%s not set", FlagSSOSession)
 	}
 
-	if ssoRegion == "" {
-		return fmt.Errorf("required flag --%s not set", FlagSSORegion)
+	if err := validateRegionOnly(); err != nil {
+		return err
+	}
+
+	if ssoStartURL != "" {
+		if err := setlist.ValidateStartURL(ssoStartURL); err != nil {
+			return err
+		}
 	}
 
-	if !strings.HasPrefix(ssoRegion, "us-") &&
-		!strings.HasPrefix(ssoRegion, "eu-") &&
-		!strings.HasPrefix(ssoRegion, "ap-") &&
-		!strings.HasPrefix(ssoRegion, "sa-") &&
-		!strings.HasPrefix(ssoRegion, "ca-") &&
-		!strings.HasPrefix(ssoRegion, "me-") &&
-		!strings.HasPrefix(ssoRegion, "af-") {
-		return fmt.Errorf("invalid region format: %s", ssoRegion)
+	if stdout && signKey != "" {
+		return fmt.Errorf("--%s needs an output file to sign, not --%s", FlagSignKey, FlagStdout)
 	}
 
 	if !stdout {
@@ -59,14 +61,16 @@ func validateRegionOnly() error {
 		return fmt.Errorf("required flag --%s not set", FlagSSORegion)
 	}
 
-	if !strings.HasPrefix(ssoRegion, "us-") &&
-		!strings.HasPrefix(ssoRegion, "eu-") &&
-		!strings.HasPrefix(ssoRegion, "ap-") &&
-		!strings.HasPrefix(ssoRegion, "sa-") &&
-		!strings.HasPrefix(ssoRegion, "ca-") &&
-		!strings.HasPrefix(ssoRegion, "me-") &&
-		!strings.HasPrefix(ssoRegion, "af-") {
-		return fmt.Errorf("invalid region format: %s", ssoRegion)
+	newRegion := setlist.NewRegion
+	if allowUnknownRegion {
+		newRegion = setlist.NewRegionAllowUnknown
+	}
+
+	if _, err := newRegion(ssoRegion); err != nil {
+		if errors.Is(err, setlist.ErrUnknownRegion) {
+			return fmt.Errorf("%w (use --%s for a region newer than this release)", err, FlagAllowUnknownRegion)
+		}
+		return err
 	}
 
 	return nil
@@ -98,9 +102,24 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&ssoSession, FlagSSOSession, "s", "", "Nickname to give the SSO Session (e.g. org name) (required)")
 	rootCmd.PersistentFlags().StringVarP(&profile, FlagProfile, "p", "", "Profile")
 	rootCmd.PersistentFlags().StringVarP(&ssoRegion, FlagSSORegion, "r", "", "AWS region where AWS SSO resides (required)")
+	rootCmd.PersistentFlags().BoolVar(&allowUnknownRegion, FlagAllowUnknownRegion, false, "Accept an --sso-region this release does not know about yet")
+	rootCmd.PersistentFlags().StringVar(&assumeRoleArn, FlagAssumeRoleArn, "", "IAM role to assume before calling AWS (e.g. in the management account)")
+	rootCmd.PersistentFlags().StringVar(&externalId, FlagExternalId, "", "External ID to pass when assuming --assume-role-arn")
+	rootCmd.PersistentFlags().StringVar(&roleSessionName, FlagRoleSessionName, setlist.DefaultRoleSessionName, "Session name for the assumed role")
+	rootCmd.PersistentFlags().StringVar(&mfaSerial, FlagMFASerial, "", "MFA device serial or ARN required by the assumed role")
+	rootCmd.PersistentFlags().StringVar(&mfaToken, FlagMFAToken, "", "MFA code for --mfa-serial (prompted for when omitted)")
+	rootCmd.PersistentFlags().StringVar(&endpointURL, FlagEndpointURL, "", "Endpoint URL for every AWS service (e.g. http://localhost:4566 for LocalStack)")
+	rootCmd.PersistentFlags().StringVar(&orgEndpointURL, FlagOrgEndpointURL, "", "Endpoint URL for AWS Organizations (overrides --endpoint-url)")
+	rootCmd.PersistentFlags().StringVar(&ssoAdminEndpointURL, FlagSSOAdminEndpointURL, "", "Endpoint URL for SSO Admin (overrides --endpoint-url)")
+	rootCmd.PersistentFlags().StringVar(&s3EndpointURL, FlagS3EndpointURL, "", "Endpoint URL for S3 (overrides --endpoint-url)")
+	rootCmd.PersistentFlags().StringVar(&stsEndpointURL, FlagSTSEndpointURL, "", "Endpoint URL for STS (overrides --endpoint-url)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, FlagVerbose, "v", false, "Enable verbose logging output")
 	rootCmd.PersistentFlags().StringVar(&logFormat, FlagLogFormat, "plain", "Log output format: \"plain\" or \"json\"")
 	rootCmd.PersistentFlags().StringVarP(&configFile, FlagConfig, "c", "", "Path to config file (default: ~/.setlist.yaml)")
+	rootCmd.PersistentFlags().StringVar(&contextName, FlagContext, "", "Named context from the config file to use (default: current-context)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, FlagNoCache, false, "Disable the on-disk cache of AWS API responses")
+	rootCmd.PersistentFlags().BoolVar(&refreshCache, FlagRefresh, false, "Ignore cached AWS API responses and fetch fresh ones")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, FlagCacheTTL, setlist.DefaultCacheTTL, "How long cached AWS API responses remain valid")
 
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		if err := configureLogging(); err != nil {
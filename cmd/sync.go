@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/scottbrown/setlist"
+	"github.com/scottbrown/setlist/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Downloading a generated config file",
+	Long:  "Downloading a config file published to S3, such as by the Lambda, and with --verify-key, checking its signature before the local copy is replaced",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		_, _, err := parseS3Location(syncFrom)
+		return err
+	},
+	RunE: handleSync,
+}
+
+func init() {
+	syncCmd.Flags().StringVar(&syncFrom, FlagFrom, "", "s3://bucket/key of the config file to download (required)")
+	syncCmd.Flags().StringVarP(&filename, FlagOutput, "o", DEFAULT_FILENAME, "Where the AWS config file will be written")
+	syncCmd.Flags().StringVar(&verifyKey, FlagVerifyKey, "", "PEM-encoded Ed25519 public key; the download is only written if its signature matches")
+	_ = syncCmd.MarkFlagRequired(FlagFrom)
+
+	rootCmd.AddCommand(syncCmd)
+}
+
+func handleSync(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DEFAULT_TIMEOUT)
+	defer cancel()
+
+	var key ed25519.PublicKey
+	if verifyKey != "" {
+		var err error
+		if key, err = setlist.LoadEd25519PublicKey(verifyKey); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := loadAWSConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	return syncConfig(ctx, cmd.OutOrStdout(), setlist.NewClients(cfg, endpoints()).S3, syncFrom, filename, key)
+}
+
+// syncConfig downloads the config file at location to path. When key is
+// set, the signature next to the config is downloaded too, and path is left
+// untouched unless it matches. Where it was written is reported to w.
+func syncConfig(ctx context.Context, w io.Writer, client settings.S3GetObjectClient, location, path string, key ed25519.PublicKey) error {
+	bucket, objectKey, err := parseS3Location(location)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Downloading config file", "location", location)
+	data, err := getObject(ctx, client, bucket, objectKey)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", location, err)
+	}
+
+	if key != nil {
+		sigLocation := location + setlist.SignatureExtension
+		slog.Info("Downloading signature", "location", sigLocation)
+		sig, err := getObject(ctx, client, bucket, objectKey+setlist.SignatureExtension)
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", sigLocation, err)
+		}
+		if err := verifySignature(key, location, data, sig); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Fprintf(w, "Wrote to %s\n", path)
+	return nil
+}
+
+// parseS3Location splits an s3://bucket/key location.
+func parseS3Location(location string) (bucket, key string, err error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "s3" || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return "", "", fmt.Errorf("invalid --%s %q: want s3://bucket/key", FlagFrom, location)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+func getObject(ctx context.Context, client settings.S3GetObjectClient, bucket, key string) ([]byte, error) {
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeS3 serves objects from one bucket.
+type fakeS3 struct {
+	bucket  string
+	objects map[string]string
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	body, ok := f.objects[aws.ToString(params.Key)]
+	if aws.ToString(params.Bucket) != f.bucket || !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestSyncConfig(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := writeKeyPair(t, dir)
+	priv, err := setlist.LoadEd25519PrivateKey(privPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := setlist.LoadEd25519PublicKey(pubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := setlist.SignConfig(context.Background(), setlist.Ed25519Signer{Key: priv}, []byte(signedConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		objects map[string]string
+		verify  bool
+		wantErr string
+	}{
+		{name: "unverified", objects: map[string]string{"aws.config": signedConfig}},
+		{name: "verified", objects: map[string]string{"aws.config": signedConfig, "aws.config.sig": string(sig)}, verify: true},
+		{
+			name:    "tampered",
+			objects: map[string]string{"aws.config": strings.Replace(signedConfig, "ReadOnly", "Admin", 2), "aws.config.sig": string(sig)},
+			verify:  true,
+			wantErr: "modified after signing",
+		},
+		{name: "unsigned", objects: map[string]string{"aws.config": signedConfig}, verify: true, wantErr: "failed to download s3://configs/aws.config.sig"},
+		{name: "missing", objects: map[string]string{}, wantErr: "failed to download s3://configs/aws.config"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte("previous"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			key := pub
+			if !tc.verify {
+				key = nil
+			}
+
+			var out bytes.Buffer
+			err := syncConfig(context.Background(), &out, &fakeS3{bucket: "configs", objects: tc.objects}, "s3://configs/aws.config", path, key)
+
+			got, readErr := os.ReadFile(path)
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("syncConfig() = %v, want an error containing %q", err, tc.wantErr)
+				}
+				if string(got) != "previous" {
+					t.Errorf("local config was replaced after a failed sync: %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("syncConfig() = %v", err)
+			}
+			if !bytes.Equal(got, []byte(signedConfig)) {
+				t.Errorf("local config = %q", got)
+			}
+			if want := "Wrote to " + path + "\n"; out.String() != want {
+				t.Errorf("output = %q, want %q", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestParseS3Location(t *testing.T) {
+	tests := []struct {
+		location   string
+		wantBucket string
+		wantKey    string
+		wantErr    bool
+	}{
+		{location: "s3://configs/aws.config", wantBucket: "configs", wantKey: "aws.config"},
+		{location: "s3://configs/team/aws.config", wantBucket: "configs", wantKey: "team/aws.config"},
+		{location: "s3://configs/", wantErr: true},
+		{location: "s3:///aws.config", wantErr: true},
+		{location: "https://example.com/aws.config", wantErr: true},
+		{location: "", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.location, func(t *testing.T) {
+			bucket, key, err := parseS3Location(tc.location)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseS3Location() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if bucket != tc.wantBucket || key != tc.wantKey {
+				t.Errorf("parseS3Location() = %q, %q; want %q, %q", bucket, key, tc.wantBucket, tc.wantKey)
+			}
+		})
+	}
+}
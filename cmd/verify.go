@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify FILE",
+	Short: "Verifying a config file's signature",
+	Long:  "Checking a config file against the detached signature written next to it by generate --sign-key or the Lambda",
+	Args:  cobra.ExactArgs(1),
+	RunE:  handleVerify,
+}
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifyKey, FlagKey, "", "PEM-encoded Ed25519 public key to verify with (required)")
+	verifyCmd.Flags().StringVar(&signatureFile, FlagSignature, "", "Detached signature to check (default: FILE"+setlist.SignatureExtension+")")
+	_ = verifyCmd.MarkFlagRequired(FlagKey)
+
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func handleVerify(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	sigPath := signatureFile
+	if sigPath == "" {
+		sigPath = path + setlist.SignatureExtension
+	}
+
+	key, err := setlist.LoadEd25519PublicKey(verifyKey)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path) //#nosec: G304
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	sig, err := os.ReadFile(sigPath) //#nosec: G304
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	if err := verifySignature(key, path, data, sig); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Signature OK for %s\n", path)
+	return nil
+}
+
+// verifySignature checks a config file against its signature, naming the
+// file in the error.
+func verifySignature(key ed25519.PublicKey, name string, data, sig []byte) error {
+	err := setlist.VerifyConfig(key, data, sig)
+	if errors.Is(err, setlist.ErrBadSignature) {
+		return fmt.Errorf("%s was not signed by this key, or was modified after signing: %w", name, err)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// signFile writes a detached signature of the config file at path next to
+// it, and reports where to w.
+func signFile(ctx context.Context, w io.Writer, path string, key ed25519.PrivateKey) error {
+	data, err := os.ReadFile(path) //#nosec: G304
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	sig, err := setlist.SignConfig(ctx, setlist.Ed25519Signer{Key: key}, data)
+	if err != nil {
+		return err
+	}
+
+	sigPath := path + setlist.SignatureExtension
+	if err := os.WriteFile(sigPath, sig, 0644); err != nil { //#nosec: G306
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	fmt.Fprintf(w, "Wrote signature to %s\n", sigPath)
+	return nil
+}
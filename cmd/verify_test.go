@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/spf13/cobra"
+)
+
+const signedConfig = "# Generated on: 2024-01-01T00:00:00 UTC\n[default]\nsso_session = corp\n\n[profile prod-ReadOnly]\nsso_role_name = ReadOnly\n"
+
+// writeKeyPair writes a new Ed25519 key pair to dir as signing.pem and
+// pub.pem, and returns their paths.
+func writeKeyPair(t *testing.T, dir string) (privPath, pubPath string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privPath = filepath.Join(dir, "signing.pem")
+	pubPath = filepath.Join(dir, "pub.pem")
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return privPath, pubPath
+}
+
+func TestSignFile_Verify(t *testing.T) {
+	t.Cleanup(resetGlobals)
+	dir := t.TempDir()
+	privPath, pubPath := writeKeyPair(t, dir)
+	_, otherPubPath := writeKeyPair(t, t.TempDir())
+
+	configPath := filepath.Join(dir, "aws.config")
+	if err := os.WriteFile(configPath, []byte(signedConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := setlist.LoadEd25519PrivateKey(privPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signOut bytes.Buffer
+	if err := signFile(context.Background(), &signOut, configPath, key); err != nil {
+		t.Fatalf("signFile() = %v", err)
+	}
+	if want := "Wrote signature to " + configPath + ".sig\n"; signOut.String() != want {
+		t.Errorf("signFile() output = %q, want %q", signOut.String(), want)
+	}
+	if _, err := os.Stat(configPath + ".sig"); err != nil {
+		t.Fatalf("signature not written: %v", err)
+	}
+
+	// A rebuild of the same profiles differs only in its timestamp.
+	rebuiltPath := filepath.Join(dir, "rebuilt.config")
+	if err := os.WriteFile(rebuiltPath, []byte(strings.Replace(signedConfig, "2024-01-01", "2025-06-30", 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tamperedPath := filepath.Join(dir, "tampered.config")
+	if err := os.WriteFile(tamperedPath, []byte(strings.Replace(signedConfig, "ReadOnly", "Admin", 2)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		file      string
+		key       string
+		signature string
+		wantErr   string
+	}{
+		{name: "valid", file: configPath, key: pubPath},
+		{name: "rebuilt with another timestamp", file: rebuiltPath, key: pubPath, signature: configPath + ".sig"},
+		{name: "tampered", file: tamperedPath, key: pubPath, signature: configPath + ".sig", wantErr: "modified after signing"},
+		{name: "other key", file: configPath, key: otherPubPath, wantErr: "was not signed by this key"},
+		{name: "missing signature", file: rebuiltPath, key: pubPath, wantErr: "failed to read signature"},
+		{name: "private key given", file: configPath, key: privPath, wantErr: `no "PUBLIC KEY" PEM block`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			verifyKey = tc.key
+			signatureFile = tc.signature
+
+			var out bytes.Buffer
+			verifyCmd.SetOut(&out)
+			defer verifyCmd.SetOut(nil)
+
+			err := handleVerify(verifyCmd, []string{tc.file})
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("handleVerify() = %v", err)
+				}
+				if want := "Signature OK for " + tc.file + "\n"; out.String() != want {
+					t.Errorf("output = %q, want %q", out.String(), want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("handleVerify() = %v, want an error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyCommand_RequiresKey(t *testing.T) {
+	flag := verifyCmd.Flags().Lookup(FlagKey)
+	if flag == nil {
+		t.Fatal("verify has no --key flag")
+	}
+	if _, ok := flag.Annotations[cobra.BashCompOneRequiredFlag]; !ok {
+		t.Error("--key is not marked required")
+	}
+}
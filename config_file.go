@@ -12,21 +12,34 @@ type ConfigFile struct {
 	SessionName     string            // Name of the SSO session
 	IdentityStoreId IdentityStoreId   // The unique identity store ID
 	FriendlyName    string            // Alt name used for the SSO instance
+	CustomStartURL  string            // Full start URL used as-is when set
 	Region          Region            // AWS region
 	Profiles        []Profile         // List of AWS profiles
 	NicknameMapping map[string]string // Mapping of account IDs to nicknames
 }
 
-// StartURL constructs the AWS SSO start URL based on the IdentityStoreId
-// or FriendlyName.
+// StartURL returns CustomStartURL if set, and otherwise constructs the AWS
+// SSO start URL for the Region's partition based on the IdentityStoreId or
+// FriendlyName.
 func (c *ConfigFile) StartURL() string {
-	subdomain := c.IdentityStoreId.String()
+	if c.CustomStartURL != "" {
+		return c.CustomStartURL
+	}
+
+	name := c.IdentityStoreId.String()
 
 	if c.hasFriendlyName() {
-		subdomain = c.FriendlyName
+		name = c.FriendlyName
 	}
 
-	return fmt.Sprintf("https://%s.awsapps.com/start", subdomain)
+	switch c.Region.Partition() {
+	case PartitionAWSUSGov:
+		return fmt.Sprintf("https://start.us-gov-home.awsapps.com/directory/%s", name)
+	case PartitionAWSCN:
+		return fmt.Sprintf("https://%s.awsapps.cn/start", name)
+	default:
+		return fmt.Sprintf("https://%s.awsapps.com/start", name)
+	}
 }
 
 // hasFriendlyName checks if a friendly name has been set for the SSO
@@ -41,3 +54,13 @@ func (c ConfigFile) HasNickname(accountId string) bool {
 
 	return exists
 }
+
+// Nickname returns the nickname of an account: its mapped nickname, or
+// DefaultNicknamePrefix followed by the account ID when it has none.
+func (c ConfigFile) Nickname(accountId string) string {
+	if c.HasNickname(accountId) {
+		return c.NicknameMapping[accountId]
+	}
+
+	return fmt.Sprintf("%s_%s", DefaultNicknamePrefix, accountId)
+}
@@ -9,20 +9,30 @@ func TestStartUrl(t *testing.T) {
 		name            string
 		identityStoreID string
 		ssoFriendlyName string
+		customStartURL  string
 		expected        string
 	}{
 		{
 			"has friendly name",
 			"d-012345",
 			"foo",
+			"",
 			"https://foo.awsapps.com/start",
 		},
 		{
 			"missing friendly name",
 			"d-012345",
 			"",
+			"",
 			"https://d-012345.awsapps.com/start",
 		},
+		{
+			"custom start url",
+			"d-012345",
+			"foo",
+			"https://sso.example.com/start",
+			"https://sso.example.com/start",
+		},
 	}
 
 	for _, tc := range tt {
@@ -35,6 +45,7 @@ func TestStartUrl(t *testing.T) {
 			c := ConfigFile{
 				IdentityStoreId: identityStoreId,
 				FriendlyName:    tc.ssoFriendlyName,
+				CustomStartURL:  tc.customStartURL,
 			}
 			actual := c.StartURL()
 
@@ -45,6 +56,35 @@ func TestStartUrl(t *testing.T) {
 	}
 }
 
+func TestStartUrlPartitions(t *testing.T) {
+	tt := []struct {
+		name         string
+		region       string
+		friendlyName string
+		expected     string
+	}{
+		{"commercial", "us-east-1", "", "https://d-012345.awsapps.com/start"},
+		{"govcloud", "us-gov-west-1", "", "https://start.us-gov-home.awsapps.com/directory/d-012345"},
+		{"govcloud friendly name", "us-gov-east-1", "corp", "https://start.us-gov-home.awsapps.com/directory/corp"},
+		{"china", "cn-north-1", "", "https://d-012345.awsapps.cn/start"},
+		{"china friendly name", "cn-northwest-1", "corp", "https://corp.awsapps.cn/start"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c := ConfigFile{
+				IdentityStoreId: IdentityStoreId("d-012345"),
+				FriendlyName:    tc.friendlyName,
+				Region:          Region(tc.region),
+			}
+
+			if actual := c.StartURL(); actual != tc.expected {
+				t.Errorf("unexpected output: got %v, want %v", actual, tc.expected)
+			}
+		})
+	}
+}
+
 func TestHasFriendlyName(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -143,3 +183,24 @@ func TestHasNicknameEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestNickname(t *testing.T) {
+	c := ConfigFile{NicknameMapping: map[string]string{"123456789012": "prod"}}
+
+	tests := []struct {
+		name      string
+		accountId string
+		expected  string
+	}{
+		{"mapped", "123456789012", "prod"},
+		{"unmapped", "210987654321", DefaultNicknamePrefix + "_210987654321"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Nickname(tt.accountId); got != tt.expected {
+				t.Fatalf("Expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
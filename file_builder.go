@@ -1,12 +1,18 @@
 package setlist
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 
 	"github.com/go-ini/ini"
 )
 
+// generatedOnComment starts the comment recording when a file was built.
+const generatedOnComment = "# Generated on: "
+
 // FileBuilder is responsible for generating an INI-formatted configuration file
 // based on the provided AWS SSO configuration. It handles creating sections
 // for the default profile, SSO session, and individual profiles.
@@ -71,12 +77,7 @@ func (f *FileBuilder) Build() (*ini.File, error) {
 		}
 
 		// Create section for nickname-based profile
-		var nickname string
-		if f.Config.HasNickname(p.AccountId.String()) {
-			nickname = f.Config.NicknameMapping[p.AccountId.String()]
-		} else {
-			nickname = fmt.Sprintf("%s_%s", DefaultNicknamePrefix, p.AccountId.String())
-		}
+		nickname := f.Config.Nickname(p.AccountId.String())
 
 		name, err = NewProfileName(fmt.Sprintf("%s-%s", nickname, p.RoleName.String()))
 		if err != nil {
@@ -97,7 +98,7 @@ func (f *FileBuilder) addDefaultSection(file *ini.File) error {
 	section := file.Section("default")
 
 	// Add a comment indicating when the file was generated
-	section.Comment = fmt.Sprintf("# Generated on: %s", generateTimestamp())
+	section.Comment = generatedOnComment + generateTimestamp()
 
 	if _, err := section.NewKey(SSOSessionAttrKey, f.Config.SessionName); err != nil {
 		return err
@@ -162,3 +163,35 @@ func (f *FileBuilder) addProfileSection(p Profile, file *ini.File) error {
 
 	return nil
 }
+
+// ContentHash returns the hex-encoded SHA-256 of a built config file,
+// ignoring the "Generated on" comment so that two builds of the same
+// profiles hash the same.
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(CanonicalContent(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// CanonicalContent returns a built config file without the "Generated on"
+// comment on its first line and with every line ending in "\n". It is what
+// ContentHash hashes and what signatures cover, so both survive a rebuild
+// of the same profiles and a checkout that converts line endings. Every
+// other line is kept, however long, so nothing else can change without
+// changing the hash.
+func CanonicalContent(data []byte) []byte {
+	lines := bytes.Split(data, []byte("\n"))
+	if last := len(lines) - 1; len(lines[last]) == 0 {
+		lines = lines[:last]
+	}
+	if len(lines) > 0 && bytes.HasPrefix(lines[0], []byte(generatedOnComment)) {
+		lines = lines[1:]
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	for _, line := range lines {
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
@@ -341,3 +341,52 @@ func TestFileBuilderBuildOutputContent(t *testing.T) {
 		}
 	}
 }
+
+func TestContentHash(t *testing.T) {
+	base := "[default]\nsso_session = my-sso\n\n[profile a]\nsso_role_name = ReadOnly\n"
+	stamped := func(ts string) []byte {
+		return []byte("# Generated on: " + ts + "\n" + base)
+	}
+
+	first := ContentHash(stamped("2024-01-01T00:00:00 UTC"))
+	second := ContentHash(stamped("2025-06-30T12:34:56 UTC"))
+	if first != second {
+		t.Errorf("ContentHash differs by timestamp: %s != %s", first, second)
+	}
+
+	if got := ContentHash([]byte(base)); got != first {
+		t.Errorf("ContentHash without timestamp = %s, want %s", got, first)
+	}
+
+	changed := ContentHash([]byte(strings.Replace(base, "ReadOnly", "Admin", 1)))
+	if changed == first {
+		t.Error("ContentHash did not change when the profiles changed")
+	}
+
+	// Everything after a line too long for a bufio.Scanner still counts.
+	long := base + "# " + strings.Repeat("x", 70*1024) + "\n"
+	if ContentHash([]byte(long)) == ContentHash([]byte(long+"\n[profile b]\nsso_role_name = Admin\n")) {
+		t.Error("ContentHash ignored a profile after a long line")
+	}
+
+	// Only the leading timestamp is ignored.
+	if ContentHash([]byte(base+"# Generated on: 2024-01-01T00:00:00 UTC\n")) == ContentHash([]byte(base)) {
+		t.Error("ContentHash ignored a timestamp comment after the first line")
+	}
+}
+
+func TestCanonicalContent(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"[default]":                "[default]\n",
+		"[default]\r\na = 1\r\n":   "[default]\na = 1\n",
+		"# Generated on: x\n[a]\n": "[a]\n",
+		"[a]\n# Generated on: x\n": "[a]\n# Generated on: x\n",
+		"[a]\n\n\n":                "[a]\n\n\n",
+	}
+	for input, want := range tests {
+		if got := string(CanonicalContent([]byte(input))); got != want {
+			t.Errorf("CanonicalContent(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
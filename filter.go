@@ -0,0 +1,152 @@
+package setlist
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+)
+
+// Attributes of the filter variables that cost extra API calls to look up.
+const (
+	filterFieldTags   = "tags"
+	filterFieldOUPath = "ou_path"
+)
+
+// Filter is a compiled CEL expression that decides whether a profile for
+// an account and permission set pair is generated. The expression can
+// reference the variables "account" (id, name, email, status, tags,
+// ou_path) and "ps" (name, description, session_duration, tags), for
+// example:
+//
+//	account.tags.env == "prod" && !ps.name.startsWith("Break")
+type Filter struct {
+	expression string
+	program    cel.Program
+	fields     map[string]bool
+}
+
+// NewFilter compiles a CEL expression into a Filter. The expression must
+// evaluate to a boolean.
+func NewFilter(expression string) (*Filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, ErrEmptyString
+	}
+
+	env, err := cel.NewEnv(
+		cel.Variable("account", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("ps", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create filter environment: %w", err)
+	}
+
+	ast, iss := env.Compile(expression)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("invalid filter expression: %w", iss.Err())
+	}
+
+	if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("invalid filter expression: must evaluate to a boolean, got %s", ast.OutputType())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter expression: %w", err)
+	}
+
+	return &Filter{expression: expression, program: program, fields: referencedFields(ast.NativeRep())}, nil
+}
+
+// referencedFields returns the attributes of account and ps that a checked
+// expression reads, whether as account.tags or account["tags"]. A variable
+// used whole, or indexed by a computed key, counts as reading every
+// attribute.
+func referencedFields(a *celast.AST) map[string]bool {
+	fields := map[string]bool{}
+	idents := celast.MatchDescendants(celast.NavigateAST(a), celast.KindMatcher(celast.IdentKind))
+	for _, ident := range idents {
+		if name := ident.AsIdent(); name != "account" && name != "ps" {
+			continue
+		}
+
+		parent, ok := ident.Parent()
+		if ok && parent.Kind() == celast.SelectKind {
+			fields[parent.AsSelect().FieldName()] = true
+			continue
+		}
+		if ok && parent.Kind() == celast.CallKind {
+			call := parent.AsCall()
+			args := call.Args()
+			if call.FunctionName() == operators.Index && args[0].ID() == ident.ID() && args[1].Kind() == celast.LiteralKind {
+				if key, isString := args[1].AsLiteral().Value().(string); isString {
+					fields[key] = true
+					continue
+				}
+			}
+		}
+
+		fields[filterFieldTags] = true
+		fields[filterFieldOUPath] = true
+	}
+	return fields
+}
+
+// String returns the source expression.
+func (f *Filter) String() string {
+	return f.expression
+}
+
+// NeedsTags reports whether the expression references tags, which require
+// additional API calls to look up.
+func (f *Filter) NeedsTags() bool {
+	return f.fields[filterFieldTags]
+}
+
+// NeedsOUPath reports whether the expression references the OU path, which
+// requires additional API calls to look up.
+func (f *Filter) NeedsOUPath() bool {
+	return f.fields[filterFieldOUPath]
+}
+
+// Match evaluates the expression for an account and permission set. An
+// expression that fails to evaluate, such as one referencing a tag the
+// account does not have, returns an error; use has() to guard optional
+// keys.
+func (f *Filter) Match(account AccountAttributes, ps PermissionSetAttributes) (bool, error) {
+	out, _, err := f.program.Eval(map[string]any{
+		"account": map[string]any{
+			"id":      account.Id,
+			"name":    account.Name,
+			"email":   account.Email,
+			"status":  account.Status,
+			"tags":    stringMapOrEmpty(account.Tags),
+			"ou_path": account.OUPath,
+		},
+		"ps": map[string]any{
+			"name":             ps.Name,
+			"description":      ps.Description,
+			"session_duration": ps.SessionDuration,
+			"tags":             stringMapOrEmpty(ps.Tags),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to evaluate filter: %w", err)
+	}
+
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("filter evaluated to %T, expected bool", out.Value())
+	}
+
+	return result, nil
+}
+
+func stringMapOrEmpty(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
@@ -0,0 +1,169 @@
+package setlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		expectError bool
+		errContains string
+	}{
+		{name: "simple comparison", expression: `account.name == "Prod"`},
+		{name: "combined account and ps", expression: `account.tags.env == "prod" && !ps.name.startsWith("Break")`},
+		{name: "empty expression", expression: "  ", expectError: true},
+		{name: "syntax error", expression: `account.name ==`, expectError: true, errContains: "invalid filter expression"},
+		{name: "unknown variable", expression: `acct.name == "x"`, expectError: true, errContains: "invalid filter expression"},
+		{name: "non-boolean result", expression: `"hello"`, expectError: true, errContains: "must evaluate to a boolean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.expression)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if tt.errContains != "" && !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want it to contain %q", err.Error(), tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if f.String() != tt.expression {
+				t.Errorf("String() = %q, want %q", f.String(), tt.expression)
+			}
+		})
+	}
+}
+
+func TestNewFilter_EmptyIsErrEmptyString(t *testing.T) {
+	if _, err := NewFilter(""); !errors.Is(err, ErrEmptyString) {
+		t.Errorf("error = %v, want ErrEmptyString", err)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	account := AccountAttributes{
+		Id:     "123456789012",
+		Name:   "Prod",
+		Email:  "prod@example.com",
+		Status: "ACTIVE",
+		Tags:   map[string]string{"env": "prod"},
+		OUPath: "Root/Workloads/Prod",
+	}
+
+	tests := []struct {
+		name        string
+		expression  string
+		ps          PermissionSetAttributes
+		expected    bool
+		expectError bool
+	}{
+		{
+			name:       "tag and name prefix match",
+			expression: `account.tags.env == "prod" && !ps.name.startsWith("Break")`,
+			ps:         PermissionSetAttributes{Name: "ReadOnly"},
+			expected:   true,
+		},
+		{
+			name:       "tag and name prefix excluded",
+			expression: `account.tags.env == "prod" && !ps.name.startsWith("Break")`,
+			ps:         PermissionSetAttributes{Name: "BreakGlass"},
+			expected:   false,
+		},
+		{
+			name:       "ou path",
+			expression: `account.ou_path.startsWith("Root/Workloads")`,
+			expected:   true,
+		},
+		{
+			name:       "id email and status",
+			expression: `account.id == "123456789012" && account.email.endsWith("@example.com") && account.status == "ACTIVE"`,
+			expected:   true,
+		},
+		{
+			name:       "permission set attributes",
+			expression: `ps.description.contains("admin") && ps.session_duration == "PT1H" && ps.tags.team == "platform"`,
+			ps: PermissionSetAttributes{
+				Description:     "Full admin",
+				SessionDuration: "PT1H",
+				Tags:            map[string]string{"team": "platform"},
+			},
+			expected: true,
+		},
+		{
+			name:       "has guards missing tag",
+			expression: `has(ps.tags.team) && ps.tags.team == "platform"`,
+			expected:   false,
+		},
+		{
+			name:        "missing tag errors",
+			expression:  `ps.tags.team == "platform"`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.expression)
+			if err != nil {
+				t.Fatalf("unexpected compile error: %v", err)
+			}
+
+			got, err := f.Match(account, tt.ps)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Match() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilterNeeds(t *testing.T) {
+	tests := []struct {
+		expression  string
+		needsTags   bool
+		needsOUPath bool
+	}{
+		{expression: `account.name == "x"`},
+		{expression: `account.tags.env == "prod"`, needsTags: true},
+		{expression: `account.ou_path == "Root"`, needsOUPath: true},
+		{expression: `has(ps.tags.a) && account.ou_path != ""`, needsTags: true, needsOUPath: true},
+		{expression: `account.name.contains("tags") || ps.name == "ou_path"`},
+		{expression: `account . tags . env == "prod"`, needsTags: true},
+		{expression: `account["ou_path"].startsWith("Root/Prod")`, needsOUPath: true},
+		{expression: `ps.tags.exists(k, k == "team")`, needsTags: true},
+		{expression: `[account.id].exists(tags, tags == "123456789012")`},
+		{expression: `account[ps.name] == "x"`, needsTags: true, needsOUPath: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			f, err := NewFilter(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.NeedsTags() != tt.needsTags {
+				t.Errorf("NeedsTags() = %v, want %v", f.NeedsTags(), tt.needsTags)
+			}
+			if f.NeedsOUPath() != tt.needsOUPath {
+				t.Errorf("NeedsOUPath() = %v, want %v", f.NeedsOUPath(), tt.needsOUPath)
+			}
+		})
+	}
+}
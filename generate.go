@@ -13,14 +13,19 @@ import (
 type GenerateInput struct {
 	SSOClient             SSOAdminClient
 	OrgClient             OrganizationsClient
+	Instance              InstanceSelector // Chooses the SSO instance when several exist
 	SessionName           string
 	Region                string
 	FriendlyName          string
+	AllowUnknownRegion    bool   // Accept a well-formed Region missing from this release's region table
+	StartURL              string // Full start URL, overriding the one built from the identity store ID or FriendlyName
 	NicknameMapping       string
 	IncludeAccounts       string
 	ExcludeAccounts       string
 	IncludePermissionSets string
 	ExcludePermissionSets string
+	Filter                string          // CEL expression evaluated for each account and permission set pair
+	Metrics               MetricsRecorder // Optional; receives counts of accounts and profiles
 }
 
 // Generate orchestrates the full config file generation workflow. It retrieves
@@ -28,7 +33,7 @@ type GenerateInput struct {
 // assembles a ConfigFile ready for output.
 func Generate(ctx context.Context, input GenerateInput) (ConfigFile, error) {
 	slog.Info("Retrieving SSO instance")
-	instance, err := SsoInstance(ctx, input.SSOClient)
+	instance, err := SelectSsoInstance(ctx, input.SSOClient, input.Instance)
 	if err != nil {
 		return ConfigFile{}, fmt.Errorf("failed to retrieve SSO instance: %w", err)
 	}
@@ -40,23 +45,22 @@ func Generate(ctx context.Context, input GenerateInput) (ConfigFile, error) {
 		return ConfigFile{}, fmt.Errorf("failed to list AWS accounts: %w", err)
 	}
 	slog.Info("AWS accounts retrieved", "count", len(accounts))
+	count(input.Metrics, MetricAccountsDiscovered, len(accounts))
 
-	includeList, err := ParseAccountIdList(input.IncludeAccounts)
+	includeList, err := ParseAccountPatterns(input.IncludeAccounts)
 	if err != nil {
 		return ConfigFile{}, fmt.Errorf("invalid include-accounts: %w", err)
 	}
 
-	excludeList, err := ParseAccountIdList(input.ExcludeAccounts)
+	excludeList, err := ParseAccountPatterns(input.ExcludeAccounts)
 	if err != nil {
 		return ConfigFile{}, fmt.Errorf("invalid exclude-accounts: %w", err)
 	}
 
 	beforeCount := len(accounts)
-	accounts, err = FilterAccounts(accounts, includeList, excludeList)
-	if err != nil {
-		return ConfigFile{}, fmt.Errorf("account filter error: %w", err)
-	}
+	accounts = FilterAccountsByPatterns(accounts, includeList, excludeList)
 	slog.Info("Accounts filtered", "before", beforeCount, "after", len(accounts))
+	count(input.Metrics, MetricAccountsFilteredOut, beforeCount-len(accounts))
 
 	nicknameMapping, err := ParseNicknameMapping(input.NicknameMapping)
 	if err != nil {
@@ -68,33 +72,61 @@ func Generate(ctx context.Context, input GenerateInput) (ConfigFile, error) {
 		return ConfigFile{}, err
 	}
 
-	region, err := NewRegion(input.Region)
+	newRegion := NewRegion
+	if input.AllowUnknownRegion {
+		newRegion = NewRegionAllowUnknown
+	}
+	region, err := newRegion(input.Region)
 	if err != nil {
 		return ConfigFile{}, err
 	}
 
-	includePSList, err := ParsePermissionSetList(input.IncludePermissionSets)
+	if input.StartURL != "" {
+		if err := ValidateStartURL(input.StartURL); err != nil {
+			return ConfigFile{}, err
+		}
+	}
+
+	includePSList, err := ParsePermissionSetPatterns(input.IncludePermissionSets)
 	if err != nil {
 		return ConfigFile{}, fmt.Errorf("invalid include-permission-sets: %w", err)
 	}
 
-	excludePSList, err := ParsePermissionSetList(input.ExcludePermissionSets)
+	excludePSList, err := ParsePermissionSetPatterns(input.ExcludePermissionSets)
 	if err != nil {
 		return ConfigFile{}, fmt.Errorf("invalid exclude-permission-sets: %w", err)
 	}
 
+	var filter *Filter
+	if input.Filter != "" {
+		filter, err = NewFilter(input.Filter)
+		if err != nil {
+			return ConfigFile{}, err
+		}
+	}
+
 	configFile := ConfigFile{
 		SessionName:     input.SessionName,
 		IdentityStoreId: identityStoreId,
 		FriendlyName:    input.FriendlyName,
+		CustomStartURL:  input.StartURL,
 		Region:          region,
 		NicknameMapping: nicknameMapping,
 	}
 
-	profiles, err := generateProfiles(ctx, input.SSOClient, instance, accounts, input.SessionName, includePSList, excludePSList)
+	var pf *profileFilter
+	if filter != nil {
+		pf, err = newProfileFilter(filter, input.OrgClient, input.SSOClient)
+		if err != nil {
+			return configFile, err
+		}
+	}
+
+	profiles, err := generateProfiles(ctx, input.SSOClient, instance, accounts, input.SessionName, includePSList, excludePSList, pf, input.Metrics)
 	if err != nil {
 		return configFile, err
 	}
+	count(input.Metrics, MetricProfilesGenerated, len(profiles))
 
 	configFile.Profiles = profiles
 	return configFile, nil
@@ -106,9 +138,13 @@ func generateProfiles(
 	instance ssotypes.InstanceMetadata,
 	accounts []orgtypes.Account,
 	sessionName string,
-	includePS, excludePS []string,
+	includePS, excludePS PatternList,
+	pf *profileFilter,
+	metrics MetricsRecorder,
 ) ([]Profile, error) {
 	var profiles []Profile
+	skipped := 0
+	defer func() { count(metrics, MetricPermissionSetsSkipped, skipped) }()
 
 	for _, account := range accounts {
 		if account.Id == nil {
@@ -122,42 +158,64 @@ func generateProfiles(
 			return nil, fmt.Errorf("failed to list permission sets for account %s: %w", *account.Id, err)
 		}
 
-		permissionSets, err = FilterPermissionSets(permissionSets, includePS, excludePS)
-		if err != nil {
-			return nil, fmt.Errorf("permission set filter error: %w", err)
-		}
+		permissionSets = FilterPermissionSetsByPatterns(permissionSets, includePS, excludePS)
 
 		slog.Info("Permission sets retrieved", "account_id", *account.Id, "count", len(permissionSets))
 
+		var accountAttrs AccountAttributes
+		if pf != nil {
+			accountAttrs, err = pf.accountAttributes(ctx, account)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		for _, p := range permissionSets {
 			if p.Name == nil || p.Description == nil || p.SessionDuration == nil {
 				slog.Warn("Found incomplete permission set data, skipping", "account_id", *account.Id)
+				skipped++
 				continue
 			}
 
+			if pf != nil {
+				match, err := pf.match(ctx, *instance.InstanceArn, accountAttrs, p)
+				if err != nil {
+					return nil, err
+				}
+				if !match {
+					slog.Info("Permission set excluded by filter", "account_id", *account.Id, "permission_set", *p.Name)
+					continue
+				}
+			}
+
 			profileDesc, err := NewProfileDescription(*p.Description)
 			if err != nil {
 				slog.Warn("Invalid profile description", "error", err.Error())
+				skipped++
 				continue
 			}
 			sessionDuration, err := NewSessionDuration(*p.SessionDuration)
 			if err != nil {
 				slog.Warn("Invalid session duration", "error", err.Error())
+				skipped++
 				continue
 			}
 			sName, err := NewSessionName(sessionName)
 			if err != nil {
 				slog.Warn("Invalid session name", "error", err.Error())
+				skipped++
 				continue
 			}
 			accountId, err := NewAWSAccountId(*account.Id)
 			if err != nil {
 				slog.Warn("Invalid AWS account ID", "error", err.Error())
+				skipped++
 				continue
 			}
 			roleName, err := NewRoleName(*p.Name)
 			if err != nil {
 				slog.Warn("Invalid role name", "error", err.Error())
+				skipped++
 				continue
 			}
 
@@ -173,3 +231,78 @@ func generateProfiles(
 
 	return profiles, nil
 }
+
+// profileFilter evaluates a Filter, looking up the tags and OU paths the
+// expression references.
+type profileFilter struct {
+	filter     *Filter
+	orgClient  OrganizationsMetadataClient
+	ssoClient  SSOAdminTagsClient
+	ouResolver *OUPathResolver
+}
+
+func newProfileFilter(filter *Filter, orgClient OrganizationsClient, ssoClient SSOAdminClient) (*profileFilter, error) {
+	pf := &profileFilter{filter: filter}
+
+	if filter.NeedsTags() || filter.NeedsOUPath() {
+		metaClient, ok := orgClient.(OrganizationsMetadataClient)
+		if !ok {
+			return nil, fmt.Errorf("filter references tags or ou_path: organizations %w", ErrMetadataUnsupported)
+		}
+		pf.orgClient = metaClient
+		pf.ouResolver = NewOUPathResolver(metaClient)
+	}
+
+	if filter.NeedsTags() {
+		tagsClient, ok := ssoClient.(SSOAdminTagsClient)
+		if !ok {
+			return nil, fmt.Errorf("filter references tags: SSO admin %w", ErrMetadataUnsupported)
+		}
+		pf.ssoClient = tagsClient
+	}
+
+	return pf, nil
+}
+
+func (pf *profileFilter) accountAttributes(ctx context.Context, account orgtypes.Account) (AccountAttributes, error) {
+	attrs := NewAccountAttributes(account)
+
+	if pf.filter.NeedsTags() {
+		tags, err := AccountTags(ctx, pf.orgClient, attrs.Id)
+		if err != nil {
+			return attrs, err
+		}
+		attrs.Tags = tags
+	}
+
+	if pf.filter.NeedsOUPath() {
+		path, err := pf.ouResolver.Path(ctx, attrs.Id)
+		if err != nil {
+			return attrs, err
+		}
+		attrs.OUPath = path
+	}
+
+	return attrs, nil
+}
+
+func (pf *profileFilter) match(ctx context.Context, instanceArn string, account AccountAttributes, p ssotypes.PermissionSet) (bool, error) {
+	attrs := NewPermissionSetAttributes(p)
+
+	if pf.filter.NeedsTags() && p.PermissionSetArn != nil {
+		tags, err := PermissionSetTags(ctx, pf.ssoClient, instanceArn, *p.PermissionSetArn)
+		if err != nil {
+			return false, err
+		}
+		attrs.Tags = tags
+	}
+
+	// A profile the filter can't decide on fails the run rather than being
+	// dropped, so a misspelled tag key doesn't quietly empty the config.
+	match, err := pf.filter.Match(account, attrs)
+	if err != nil {
+		return false, fmt.Errorf("filter failed for account %s and permission set %s: %w", account.Id, attrs.Name, err)
+	}
+
+	return match, nil
+}
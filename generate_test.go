@@ -155,6 +155,91 @@ func TestGenerate(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "unknown region",
+			input: GenerateInput{
+				SSOClient: &mockSSOAdminClient{
+					ListPermissionSetsProvisionedToAccountFunc: func(ctx context.Context, params *ssoadmin.ListPermissionSetsProvisionedToAccountInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsProvisionedToAccountOutput, error) {
+						return &ssoadmin.ListPermissionSetsProvisionedToAccountOutput{}, nil
+					},
+				},
+				OrgClient: &mockOrgClient{
+					ListAccountsFunc: func(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+						return &organizations.ListAccountsOutput{}, nil
+					},
+				},
+				SessionName: "test",
+				Region:      "xx-central-1",
+			},
+			expectError: true,
+			errContains: "unknown region",
+		},
+		{
+			name: "unknown region allowed",
+			input: GenerateInput{
+				SSOClient: &mockSSOAdminClient{
+					ListPermissionSetsProvisionedToAccountFunc: func(ctx context.Context, params *ssoadmin.ListPermissionSetsProvisionedToAccountInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsProvisionedToAccountOutput, error) {
+						return &ssoadmin.ListPermissionSetsProvisionedToAccountOutput{}, nil
+					},
+				},
+				OrgClient: &mockOrgClient{
+					ListAccountsFunc: func(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+						return &organizations.ListAccountsOutput{}, nil
+					},
+				},
+				SessionName:        "test",
+				Region:             "xx-central-1",
+				AllowUnknownRegion: true,
+			},
+			checkResult: func(t *testing.T, cf ConfigFile) {
+				if cf.Region != "xx-central-1" {
+					t.Errorf("Expected region xx-central-1, got %q", cf.Region)
+				}
+			},
+		},
+		{
+			name: "custom start url",
+			input: GenerateInput{
+				SSOClient: &mockSSOAdminClient{
+					ListPermissionSetsProvisionedToAccountFunc: func(ctx context.Context, params *ssoadmin.ListPermissionSetsProvisionedToAccountInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsProvisionedToAccountOutput, error) {
+						return &ssoadmin.ListPermissionSetsProvisionedToAccountOutput{}, nil
+					},
+				},
+				OrgClient: &mockOrgClient{
+					ListAccountsFunc: func(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+						return &organizations.ListAccountsOutput{}, nil
+					},
+				},
+				SessionName: "test",
+				Region:      "us-gov-west-1",
+				StartURL:    "https://sso.example.com/start",
+			},
+			checkResult: func(t *testing.T, cf ConfigFile) {
+				if cf.StartURL() != "https://sso.example.com/start" {
+					t.Errorf("Expected custom start URL, got %q", cf.StartURL())
+				}
+			},
+		},
+		{
+			name: "invalid start url",
+			input: GenerateInput{
+				SSOClient: &mockSSOAdminClient{
+					ListPermissionSetsProvisionedToAccountFunc: func(ctx context.Context, params *ssoadmin.ListPermissionSetsProvisionedToAccountInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsProvisionedToAccountOutput, error) {
+						return &ssoadmin.ListPermissionSetsProvisionedToAccountOutput{}, nil
+					},
+				},
+				OrgClient: &mockOrgClient{
+					ListAccountsFunc: func(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+						return &organizations.ListAccountsOutput{}, nil
+					},
+				},
+				SessionName: "test",
+				Region:      "us-east-1",
+				StartURL:    "http://sso.example.com/start",
+			},
+			expectError: true,
+			errContains: "must be an https:// URL",
+		},
 	}
 
 	for _, tt := range tests {
@@ -237,3 +322,145 @@ func (g *generateMockSSO) ListPermissionSetsProvisionedToAccount(ctx context.Con
 func (g *generateMockSSO) DescribePermissionSet(ctx context.Context, params *ssoadmin.DescribePermissionSetInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error) {
 	return g.inner.DescribePermissionSet(ctx, params, optFns...)
 }
+
+// filterMockSSO serves a single instance with two complete, tagged
+// permission sets provisioned to every account.
+type filterMockSSO struct{}
+
+func (f *filterMockSSO) ListInstances(ctx context.Context, params *ssoadmin.ListInstancesInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListInstancesOutput, error) {
+	return &ssoadmin.ListInstancesOutput{
+		Instances: []types.InstanceMetadata{
+			{InstanceArn: aws.String("arn:aws:sso:::instance/ssoins-1"), IdentityStoreId: aws.String("d-1234567890")},
+		},
+	}, nil
+}
+
+func (f *filterMockSSO) ListPermissionSets(ctx context.Context, params *ssoadmin.ListPermissionSetsInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *filterMockSSO) ListPermissionSetsProvisionedToAccount(ctx context.Context, params *ssoadmin.ListPermissionSetsProvisionedToAccountInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsProvisionedToAccountOutput, error) {
+	return &ssoadmin.ListPermissionSetsProvisionedToAccountOutput{
+		PermissionSets: []string{"arn:ps/ReadOnly", "arn:ps/BreakGlass"},
+	}, nil
+}
+
+func (f *filterMockSSO) DescribePermissionSet(ctx context.Context, params *ssoadmin.DescribePermissionSetInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error) {
+	name := strings.TrimPrefix(*params.PermissionSetArn, "arn:ps/")
+	return &ssoadmin.DescribePermissionSetOutput{
+		PermissionSet: &types.PermissionSet{
+			Name:             aws.String(name),
+			Description:      aws.String(name + " access"),
+			SessionDuration:  aws.String("PT1H"),
+			PermissionSetArn: params.PermissionSetArn,
+		},
+	}, nil
+}
+
+type taggedFilterMockSSO struct {
+	filterMockSSO
+}
+
+func (f *taggedFilterMockSSO) ListTagsForResource(ctx context.Context, params *ssoadmin.ListTagsForResourceInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListTagsForResourceOutput, error) {
+	return &ssoadmin.ListTagsForResourceOutput{
+		Tags: []types.Tag{{Key: aws.String("tier"), Value: aws.String("standard")}},
+	}, nil
+}
+
+func TestGenerate_Filter(t *testing.T) {
+	tests := []struct {
+		name        string
+		sso         SSOAdminClient
+		org         OrganizationsClient
+		filter      string
+		expected    []string
+		errContains string
+	}{
+		{
+			name:     "tags and name prefix",
+			sso:      &taggedFilterMockSSO{},
+			org:      newMockOrgHierarchy(),
+			filter:   `account.tags.env == "prod" && !ps.name.startsWith("Break")`,
+			expected: []string{"111111111111/ReadOnly"},
+		},
+		{
+			name:     "ou path",
+			sso:      &taggedFilterMockSSO{},
+			org:      newMockOrgHierarchy(),
+			filter:   `account.ou_path == "Root"`,
+			expected: []string{"222222222222/ReadOnly", "222222222222/BreakGlass"},
+		},
+		{
+			name:     "no metadata needed",
+			sso:      &filterMockSSO{},
+			org:      &mockOrgClient{ListAccountsFunc: newMockOrgHierarchy().ListAccounts},
+			filter:   `account.name == "Sandbox" && ps.name == "BreakGlass"`,
+			expected: []string{"222222222222/BreakGlass"},
+		},
+		{
+			name:     "permission set tags",
+			sso:      &taggedFilterMockSSO{},
+			org:      newMockOrgHierarchy(),
+			filter:   `ps.tags.tier == "standard" && account.id == "111111111111"`,
+			expected: []string{"111111111111/ReadOnly", "111111111111/BreakGlass"},
+		},
+		{
+			name:        "org client without metadata support",
+			sso:         &filterMockSSO{},
+			org:         &mockOrgClient{ListAccountsFunc: newMockOrgHierarchy().ListAccounts},
+			filter:      `account.ou_path == "Root"`,
+			errContains: "does not support",
+		},
+		{
+			name:        "invalid expression",
+			sso:         &filterMockSSO{},
+			org:         newMockOrgHierarchy(),
+			filter:      `account.name ==`,
+			errContains: "invalid filter expression",
+		},
+		{
+			name:        "misspelled tag key",
+			sso:         &taggedFilterMockSSO{},
+			org:         newMockOrgHierarchy(),
+			filter:      `account.tags.enviroment == "prod"`,
+			errContains: "filter failed for account 111111111111 and permission set ReadOnly",
+		},
+		{
+			name:     "optional tag guarded by has",
+			sso:      &taggedFilterMockSSO{},
+			org:      newMockOrgHierarchy(),
+			filter:   `has(account.tags.enviroment) && account.tags.enviroment == "prod"`,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cf, err := Generate(context.Background(), GenerateInput{
+				SSOClient:   tt.sso,
+				OrgClient:   tt.org,
+				SessionName: "my-org",
+				Region:      "us-east-1",
+				Filter:      tt.filter,
+			})
+
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, p := range cf.Profiles {
+				got = append(got, p.AccountId.String()+"/"+p.RoleName.String())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("profiles = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
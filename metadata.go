@@ -0,0 +1,201 @@
+package setlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
+)
+
+// rootPathName is the name used for the organization root in OU paths.
+const rootPathName = "Root"
+
+// OrganizationsMetadataClient is implemented by Organizations clients that
+// can also look up account tags and the organizational unit hierarchy.
+type OrganizationsMetadataClient interface {
+	OrganizationsClient
+	ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error)
+	ListParents(ctx context.Context, params *organizations.ListParentsInput, optFns ...func(*organizations.Options)) (*organizations.ListParentsOutput, error)
+	DescribeOrganizationalUnit(ctx context.Context, params *organizations.DescribeOrganizationalUnitInput, optFns ...func(*organizations.Options)) (*organizations.DescribeOrganizationalUnitOutput, error)
+}
+
+// SSOAdminTagsClient is implemented by SSO Admin clients that can also look
+// up permission set tags.
+type SSOAdminTagsClient interface {
+	SSOAdminClient
+	ListTagsForResource(ctx context.Context, params *ssoadmin.ListTagsForResourceInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListTagsForResourceOutput, error)
+}
+
+var ErrMetadataUnsupported = errors.New("client does not support tag and organizational unit lookups")
+
+// AccountAttributes describes an AWS account for filter expressions. Tags
+// and OUPath are only populated when a filter needs them.
+type AccountAttributes struct {
+	Id     string
+	Name   string
+	Email  string
+	Status string
+	Tags   map[string]string
+	OUPath string // Slash-separated OU names from the root, e.g. "Root/Workloads/Prod"
+}
+
+// PermissionSetAttributes describes a permission set for filter
+// expressions. Tags are only populated when a filter needs them.
+type PermissionSetAttributes struct {
+	Name            string
+	Description     string
+	SessionDuration string
+	Tags            map[string]string
+}
+
+// NewAccountAttributes copies the basic attributes of an account.
+func NewAccountAttributes(a orgtypes.Account) AccountAttributes {
+	return AccountAttributes{
+		Id:     aws.ToString(a.Id),
+		Name:   aws.ToString(a.Name),
+		Email:  aws.ToString(a.Email),
+		Status: string(a.Status),
+		Tags:   map[string]string{},
+	}
+}
+
+// NewPermissionSetAttributes copies the basic attributes of a permission set.
+func NewPermissionSetAttributes(p ssotypes.PermissionSet) PermissionSetAttributes {
+	return PermissionSetAttributes{
+		Name:            aws.ToString(p.Name),
+		Description:     aws.ToString(p.Description),
+		SessionDuration: aws.ToString(p.SessionDuration),
+		Tags:            map[string]string{},
+	}
+}
+
+// AccountTags retrieves all tags attached to an account.
+func AccountTags(ctx context.Context, client OrganizationsMetadataClient, accountId string) (map[string]string, error) {
+	tags := map[string]string{}
+
+	var token *string
+	for {
+		resp, err := client.ListTagsForResource(ctx, &organizations.ListTagsForResourceInput{
+			ResourceId: aws.String(accountId),
+			NextToken:  token,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags for account %s: %w", accountId, err)
+		}
+
+		for _, t := range resp.Tags {
+			tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+		}
+
+		if resp.NextToken == nil {
+			break
+		}
+		token = resp.NextToken
+	}
+
+	return tags, nil
+}
+
+// PermissionSetTags retrieves all tags attached to a permission set.
+func PermissionSetTags(ctx context.Context, client SSOAdminTagsClient, instanceArn, permissionSetArn string) (map[string]string, error) {
+	tags := map[string]string{}
+
+	var token *string
+	for {
+		resp, err := client.ListTagsForResource(ctx, &ssoadmin.ListTagsForResourceInput{
+			InstanceArn: aws.String(instanceArn),
+			ResourceArn: aws.String(permissionSetArn),
+			NextToken:   token,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags for permission set %s: %w", permissionSetArn, err)
+		}
+
+		for _, t := range resp.Tags {
+			tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+		}
+
+		if resp.NextToken == nil {
+			break
+		}
+		token = resp.NextToken
+	}
+
+	return tags, nil
+}
+
+// OUPathResolver builds slash-separated OU paths for accounts. OU names are
+// remembered so each OU is only described once.
+type OUPathResolver struct {
+	client OrganizationsMetadataClient
+	names  map[string]string
+}
+
+// NewOUPathResolver creates an OUPathResolver using client.
+func NewOUPathResolver(client OrganizationsMetadataClient) *OUPathResolver {
+	return &OUPathResolver{client: client, names: map[string]string{}}
+}
+
+// Path returns the OU path of an account, starting at the root.
+func (r *OUPathResolver) Path(ctx context.Context, accountId string) (string, error) {
+	var segments []string
+
+	child := accountId
+	for {
+		resp, err := r.client.ListParents(ctx, &organizations.ListParentsInput{ChildId: aws.String(child)})
+		if err != nil {
+			return "", fmt.Errorf("failed to list parents of %s: %w", child, err)
+		}
+
+		if len(resp.Parents) == 0 {
+			return "", fmt.Errorf("no parent found for %s", child)
+		}
+
+		parent := resp.Parents[0]
+		if parent.Type == orgtypes.ParentTypeRoot {
+			segments = append(segments, rootPathName)
+			break
+		}
+
+		name, err := r.name(ctx, aws.ToString(parent.Id))
+		if err != nil {
+			return "", err
+		}
+		segments = append(segments, name)
+		child = aws.ToString(parent.Id)
+	}
+
+	// Segments were collected from the account upwards
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+func (r *OUPathResolver) name(ctx context.Context, ouId string) (string, error) {
+	if name, ok := r.names[ouId]; ok {
+		return name, nil
+	}
+
+	resp, err := r.client.DescribeOrganizationalUnit(ctx, &organizations.DescribeOrganizationalUnitInput{
+		OrganizationalUnitId: aws.String(ouId),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe organizational unit %s: %w", ouId, err)
+	}
+
+	if resp.OrganizationalUnit == nil {
+		return "", fmt.Errorf("nil organizational unit returned for %s", ouId)
+	}
+
+	name := aws.ToString(resp.OrganizationalUnit.Name)
+	r.names[ouId] = name
+	return name, nil
+}
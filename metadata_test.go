@@ -0,0 +1,149 @@
+package setlist
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
+)
+
+// mockOrgMetadataClient serves accounts, tags and a fixed OU hierarchy.
+type mockOrgMetadataClient struct {
+	accounts      []orgtypes.Account
+	tags          map[string]map[string]string
+	parents       map[string]orgtypes.Parent
+	ouNames       map[string]string
+	describeCalls int
+}
+
+func (m *mockOrgMetadataClient) ListAccounts(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	return &organizations.ListAccountsOutput{Accounts: m.accounts}, nil
+}
+
+func (m *mockOrgMetadataClient) ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	var tags []orgtypes.Tag
+	for k, v := range m.tags[*params.ResourceId] {
+		tags = append(tags, orgtypes.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return &organizations.ListTagsForResourceOutput{Tags: tags}, nil
+}
+
+func (m *mockOrgMetadataClient) ListParents(ctx context.Context, params *organizations.ListParentsInput, optFns ...func(*organizations.Options)) (*organizations.ListParentsOutput, error) {
+	parent, ok := m.parents[*params.ChildId]
+	if !ok {
+		return &organizations.ListParentsOutput{}, nil
+	}
+	return &organizations.ListParentsOutput{Parents: []orgtypes.Parent{parent}}, nil
+}
+
+func (m *mockOrgMetadataClient) DescribeOrganizationalUnit(ctx context.Context, params *organizations.DescribeOrganizationalUnitInput, optFns ...func(*organizations.Options)) (*organizations.DescribeOrganizationalUnitOutput, error) {
+	m.describeCalls++
+	name, ok := m.ouNames[*params.OrganizationalUnitId]
+	if !ok {
+		return nil, errors.New("ou not found")
+	}
+	return &organizations.DescribeOrganizationalUnitOutput{
+		OrganizationalUnit: &orgtypes.OrganizationalUnit{Id: params.OrganizationalUnitId, Name: aws.String(name)},
+	}, nil
+}
+
+func newMockOrgHierarchy() *mockOrgMetadataClient {
+	return &mockOrgMetadataClient{
+		accounts: []orgtypes.Account{
+			{Id: aws.String("111111111111"), Name: aws.String("Prod"), Status: orgtypes.AccountStatusActive},
+			{Id: aws.String("222222222222"), Name: aws.String("Sandbox"), Status: orgtypes.AccountStatusActive},
+		},
+		tags: map[string]map[string]string{
+			"111111111111": {"env": "prod"},
+			"222222222222": {"env": "dev"},
+		},
+		parents: map[string]orgtypes.Parent{
+			"111111111111": {Id: aws.String("ou-prod"), Type: orgtypes.ParentTypeOrganizationalUnit},
+			"222222222222": {Id: aws.String("r-root"), Type: orgtypes.ParentTypeRoot},
+			"ou-prod":      {Id: aws.String("ou-workloads"), Type: orgtypes.ParentTypeOrganizationalUnit},
+			"ou-workloads": {Id: aws.String("r-root"), Type: orgtypes.ParentTypeRoot},
+		},
+		ouNames: map[string]string{
+			"ou-prod":      "Prod",
+			"ou-workloads": "Workloads",
+		},
+	}
+}
+
+func TestAccountTags(t *testing.T) {
+	client := newMockOrgHierarchy()
+
+	tags, err := AccountTags(context.Background(), client, "111111111111")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tags["env"] != "prod" {
+		t.Errorf("tags = %v, want env=prod", tags)
+	}
+}
+
+func TestOUPathResolver(t *testing.T) {
+	client := newMockOrgHierarchy()
+	resolver := NewOUPathResolver(client)
+
+	tests := []struct {
+		accountId string
+		expected  string
+	}{
+		{accountId: "111111111111", expected: "Root/Workloads/Prod"},
+		{accountId: "222222222222", expected: "Root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accountId, func(t *testing.T) {
+			got, err := resolver.Path(context.Background(), tt.accountId)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Path() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := resolver.Path(context.Background(), "111111111111"); err != nil {
+		t.Fatal(err)
+	}
+	if client.describeCalls != 2 {
+		t.Errorf("OU names should be remembered, DescribeOrganizationalUnit called %d times", client.describeCalls)
+	}
+
+	if _, err := resolver.Path(context.Background(), "333333333333"); err == nil {
+		t.Error("expected error for account without parents")
+	}
+}
+
+type mockSSOTagsClient struct {
+	generateMockSSO
+	tags map[string]map[string]string
+}
+
+func (m *mockSSOTagsClient) ListTagsForResource(ctx context.Context, params *ssoadmin.ListTagsForResourceInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListTagsForResourceOutput, error) {
+	var tags []ssotypes.Tag
+	for k, v := range m.tags[*params.ResourceArn] {
+		tags = append(tags, ssotypes.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return &ssoadmin.ListTagsForResourceOutput{Tags: tags}, nil
+}
+
+func TestPermissionSetTags(t *testing.T) {
+	client := &mockSSOTagsClient{tags: map[string]map[string]string{"arn:ps": {"team": "platform"}}}
+
+	tags, err := PermissionSetTags(context.Background(), client, "arn:instance", "arn:ps")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tags["team"] != "platform" {
+		t.Errorf("tags = %v, want team=platform", tags)
+	}
+}
@@ -0,0 +1,64 @@
+package setlist
+
+import (
+	"context"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Metric names recorded by Generate and by clients built from a config
+// passed through WithMetrics.
+const (
+	MetricAccountsDiscovered    = "AccountsDiscovered"
+	MetricAccountsFilteredOut   = "AccountsFilteredOut"
+	MetricPermissionSetsSkipped = "PermissionSetsSkipped"
+	MetricProfilesGenerated     = "ProfilesGenerated"
+	MetricAPICalls              = "APICalls"
+	MetricThrottles             = "Throttles"
+)
+
+// MetricsRecorder receives counts of what a run found, skipped and called.
+// Count may be called several times for the same name, and the values add
+// up. API calls are made concurrently, so implementations must be safe for
+// concurrent use.
+type MetricsRecorder interface {
+	Count(name string, value int)
+}
+
+// count records value under name when recorder is set.
+func count(recorder MetricsRecorder, name string, value int) {
+	if recorder != nil {
+		recorder.Count(name, value)
+	}
+}
+
+// metricsMiddlewareID identifies the API call counter in a client's
+// middleware stack.
+const metricsMiddlewareID = "SetlistMetrics"
+
+// WithMetrics returns a copy of cfg whose clients count every API call
+// attempt, retries included, as MetricAPICalls, and every throttled attempt
+// as MetricThrottles.
+func WithMetrics(cfg aws.Config, recorder MetricsRecorder) aws.Config {
+	cfg = cfg.Copy()
+	throttles := retry.IsErrorThrottles(retry.DefaultThrottles)
+
+	counter := middleware.FinalizeMiddlewareFunc(metricsMiddlewareID, func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleFinalize(ctx, in)
+		recorder.Count(MetricAPICalls, 1)
+		if err != nil && throttles.IsErrorThrottle(err) == aws.TrueTernary {
+			recorder.Count(MetricThrottles, 1)
+		}
+		return out, metadata, err
+	})
+
+	// Clipped so the append never writes into the original's backing array.
+	cfg.APIOptions = append(slices.Clip(cfg.APIOptions), func(stack *middleware.Stack) error {
+		// After the retry middleware, so each attempt passes through.
+		return stack.Finalize.Insert(counter, "Retry", middleware.After)
+	})
+	return cfg
+}
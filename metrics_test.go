@@ -0,0 +1,136 @@
+package setlist
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+)
+
+type recordingMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (r *recordingMetrics) Count(name string, value int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = map[string]int{}
+	}
+	r.counts[name] += value
+}
+
+// incompleteMockSSO serves filterMockSSO's permission sets, with
+// BreakGlass missing its description.
+type incompleteMockSSO struct {
+	filterMockSSO
+}
+
+func (m *incompleteMockSSO) DescribePermissionSet(ctx context.Context, params *ssoadmin.DescribePermissionSetInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error) {
+	out, err := m.filterMockSSO.DescribePermissionSet(ctx, params, optFns...)
+	if err == nil && strings.HasSuffix(*params.PermissionSetArn, "BreakGlass") {
+		out.PermissionSet.Description = nil
+	}
+	return out, err
+}
+
+func TestGenerate_Metrics(t *testing.T) {
+	metrics := &recordingMetrics{}
+	_, err := Generate(context.Background(), GenerateInput{
+		SSOClient: &incompleteMockSSO{},
+		OrgClient: &mockOrgClient{
+			ListAccountsFunc: func(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+				return &organizations.ListAccountsOutput{Accounts: []orgtypes.Account{
+					{Id: aws.String("111111111111"), Name: aws.String("prod")},
+					{Id: aws.String("222222222222"), Name: aws.String("dev")},
+					{Id: aws.String("333333333333"), Name: aws.String("sandbox")},
+				}}, nil
+			},
+		},
+		SessionName:     "corp",
+		Region:          "us-east-1",
+		ExcludeAccounts: "333333333333",
+		Metrics:         metrics,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]int{
+		MetricAccountsDiscovered:    3,
+		MetricAccountsFilteredOut:   1,
+		MetricPermissionSetsSkipped: 2,
+		MetricProfilesGenerated:     2,
+	}
+	if !reflect.DeepEqual(metrics.counts, want) {
+		t.Errorf("counts = %v, want %v", metrics.counts, want)
+	}
+}
+
+// throttlingTransport answers the first throttles requests with a
+// throttling error and the rest with an empty account list.
+type throttlingTransport struct {
+	throttles int
+	requests  int
+}
+
+func (tr *throttlingTransport) Do(req *http.Request) (*http.Response, error) {
+	tr.requests++
+	status, body := http.StatusOK, `{"Accounts":[]}`
+	if tr.requests <= tr.throttles {
+		status, body = http.StatusBadRequest, `{"__type":"TooManyRequestsException","Message":"Rate exceeded"}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/x-amz-json-1.1"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+type noBackoff struct{}
+
+func (noBackoff) BackoffDelay(int, error) (time.Duration, error) { return 0, nil }
+
+func TestWithMetrics(t *testing.T) {
+	transport := &throttlingTransport{throttles: 2}
+	base := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		HTTPClient:  transport,
+		Retryer: func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.MaxAttempts = 5
+				o.Backoff = noBackoff{}
+				o.RateLimiter = ratelimit.None
+			})
+		},
+	}
+
+	metrics := &recordingMetrics{}
+	client := organizations.NewFromConfig(WithMetrics(base, metrics))
+	if _, err := client.ListAccounts(context.Background(), &organizations.ListAccountsInput{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]int{MetricAPICalls: 3, MetricThrottles: 2}
+	if !reflect.DeepEqual(metrics.counts, want) {
+		t.Errorf("counts = %v, want %v", metrics.counts, want)
+	}
+	if len(base.APIOptions) != 0 {
+		t.Error("WithMetrics modified the config it was given")
+	}
+}
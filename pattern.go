@@ -0,0 +1,248 @@
+package setlist
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// PatternRegexPrefix marks a pattern as a regular expression, e.g.
+// "re:^Platform-.*".
+const PatternRegexPrefix = "re:"
+
+// PatternFilePrefix marks an entry as a reference to a file holding one
+// pattern per line, e.g. "@path/to/list.txt".
+const PatternFilePrefix = "@"
+
+// patternCommentPrefix starts a comment line in a pattern file.
+const patternCommentPrefix = "#"
+
+// globMetaChars are the characters that make a pattern a glob rather than an
+// exact name.
+const globMetaChars = "*?["
+
+// Pattern matches a value by exact name, shell-style glob or regular
+// expression.
+type Pattern struct {
+	raw   string
+	glob  bool
+	regex *regexp.Regexp
+}
+
+// PatternList is a list of patterns. A value matches the list if it matches
+// any of its patterns.
+type PatternList []Pattern
+
+// ParsePattern parses a single pattern. Entries starting with "re:" are
+// regular expressions, entries containing any of "*?[" are globs, and
+// anything else must match exactly.
+func ParsePattern(s string) (Pattern, error) {
+	if s == "" {
+		return Pattern{}, ErrEmptyString
+	}
+
+	if expr, ok := strings.CutPrefix(s, PatternRegexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return Pattern{}, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+		}
+		return Pattern{raw: s, regex: re}, nil
+	}
+
+	if strings.ContainsAny(s, globMetaChars) {
+		if _, err := path.Match(s, ""); err != nil {
+			return Pattern{}, fmt.Errorf("invalid glob %q: %w", s, err)
+		}
+		return Pattern{raw: s, glob: true}, nil
+	}
+
+	return Pattern{raw: s}, nil
+}
+
+// Match reports whether value matches the pattern.
+func (p Pattern) Match(value string) bool {
+	switch {
+	case p.regex != nil:
+		return p.regex.MatchString(value)
+	case p.glob:
+		matched, _ := path.Match(p.raw, value)
+		return matched
+	default:
+		return p.raw == value
+	}
+}
+
+// IsLiteral reports whether the pattern matches a single exact value.
+func (p Pattern) IsLiteral() bool {
+	return p.regex == nil && !p.glob
+}
+
+// String returns the pattern as it was written.
+func (p Pattern) String() string {
+	return p.raw
+}
+
+// Match reports whether value matches any pattern in the list.
+func (l PatternList) Match(value string) bool {
+	for _, p := range l {
+		if p.Match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternEntry is a raw pattern along with where it came from, used to
+// build error messages.
+type patternEntry struct {
+	value    string
+	location string
+}
+
+// expandPatternEntries splits a comma-delimited string into trimmed entries,
+// replacing "@file" references with the non-blank, non-comment lines of
+// that file.
+func expandPatternEntries(s string) ([]patternEntry, error) {
+	var entries []patternEntry
+
+	for i, token := range splitPatternList(s) {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		if !strings.HasPrefix(token, PatternRegexPrefix) {
+			// A regular expression keeps the backslash, since \, matches a
+			// comma there too.
+			token = strings.ReplaceAll(token, `\,`, ",")
+		}
+
+		filename, ok := strings.CutPrefix(token, PatternFilePrefix)
+		if !ok {
+			entries = append(entries, patternEntry{value: token, location: fmt.Sprintf("position %d", i+1)})
+			continue
+		}
+
+		fileEntries, err := readPatternFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	return entries, nil
+}
+
+// splitPatternList splits a comma-delimited pattern list at the commas
+// separatorIndexes finds.
+func splitPatternList(s string) []string {
+	var tokens []string
+	start := 0
+	for _, i := range separatorIndexes(s) {
+		tokens = append(tokens, s[start:i])
+		start = i + 1
+	}
+	return append(tokens, s[start:])
+}
+
+// HasPatternFile reports whether a comma-delimited pattern list holds an
+// "@file" reference, so callers parsing untrusted lists can refuse them
+// rather than read local files.
+func HasPatternFile(s string) bool {
+	for _, token := range splitPatternList(s) {
+		if strings.HasPrefix(strings.TrimSpace(token), PatternFilePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// JoinPatterns joins patterns into a comma-delimited list that parses back
+// into the same patterns, escaping the commas that would otherwise separate
+// entries.
+func JoinPatterns(patterns []string) string {
+	escaped := make([]string, len(patterns))
+	for n, p := range patterns {
+		var b strings.Builder
+		start := 0
+		for _, i := range separatorIndexes(p) {
+			b.WriteString(p[start:i])
+			b.WriteString(`\,`)
+			start = i + 1
+		}
+		b.WriteString(p[start:])
+		escaped[n] = b.String()
+	}
+	return strings.Join(escaped, ",")
+}
+
+// separatorIndexes returns the offsets of the commas in s that separate
+// pattern entries. A comma escaped with a backslash belongs to its entry,
+// and so does a comma inside the {} or [] of a "re:" entry, e.g.
+// "re:^[0-9]{3,4}$".
+func separatorIndexes(s string) []int {
+	var (
+		indexes []int
+		start   int
+		escaped bool
+		inClass bool // inside a [] character class of a regex
+		braces  int  // open {} repetitions of a regex
+	)
+
+	for i, r := range s {
+		regex := strings.HasPrefix(strings.TrimSpace(s[start:i]), PatternRegexPrefix)
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',' && !inClass && braces == 0:
+			indexes = append(indexes, i)
+			start = i + 1
+		case !regex:
+		case r == '[' && !inClass:
+			inClass = true
+		case r == ']' && inClass:
+			inClass = false
+		case r == '{' && !inClass:
+			braces++
+		case r == '}' && !inClass && braces > 0:
+			braces--
+		}
+	}
+
+	return indexes
+}
+
+func readPatternFile(filename string) ([]patternEntry, error) {
+	f, err := os.Open(filename) //#nosec: G304
+	if err != nil {
+		return nil, fmt.Errorf("unable to read pattern file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []patternEntry
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, patternCommentPrefix) {
+			continue
+		}
+
+		if strings.HasPrefix(text, PatternFilePrefix) {
+			return nil, fmt.Errorf("%s line %d: nested file references are not supported", filename, line)
+		}
+
+		entries = append(entries, patternEntry{value: text, location: fmt.Sprintf("%s line %d", filename, line)})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read pattern file: %w", err)
+	}
+
+	return entries, nil
+}
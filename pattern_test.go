@@ -0,0 +1,261 @@
+package setlist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		literal     bool
+		matches     []string
+		nonMatches  []string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:       "exact name",
+			input:      "ReadOnly",
+			literal:    true,
+			matches:    []string{"ReadOnly"},
+			nonMatches: []string{"ReadOnlyAccess", "readonly"},
+		},
+		{
+			name:       "glob suffix",
+			input:      "ReadOnly*",
+			matches:    []string{"ReadOnly", "ReadOnlyAccess"},
+			nonMatches: []string{"AdminReadOnly"},
+		},
+		{
+			name:       "glob character class",
+			input:      "1[0-2]*",
+			matches:    []string{"111111111111", "123456789012"},
+			nonMatches: []string{"333333333333"},
+		},
+		{
+			name:       "regex",
+			input:      "re:^Platform-.*",
+			matches:    []string{"Platform-Admin", "Platform-"},
+			nonMatches: []string{"MyPlatform-Admin"},
+		},
+		{
+			name:        "invalid regex",
+			input:       "re:(",
+			wantErr:     true,
+			errContains: "invalid regular expression",
+		},
+		{
+			name:        "invalid glob",
+			input:       "Read[",
+			wantErr:     true,
+			errContains: "invalid glob",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePattern(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want it to contain %q", err.Error(), tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if p.IsLiteral() != tt.literal {
+				t.Errorf("IsLiteral() = %v, want %v", p.IsLiteral(), tt.literal)
+			}
+			if p.String() != tt.input {
+				t.Errorf("String() = %q, want %q", p.String(), tt.input)
+			}
+			for _, v := range tt.matches {
+				if !p.Match(v) {
+					t.Errorf("expected %q to match %q", tt.input, v)
+				}
+			}
+			for _, v := range tt.nonMatches {
+				if p.Match(v) {
+					t.Errorf("expected %q not to match %q", tt.input, v)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePattern_Empty(t *testing.T) {
+	if _, err := ParsePattern(""); !errors.Is(err, ErrEmptyString) {
+		t.Errorf("error = %v, want ErrEmptyString", err)
+	}
+}
+
+func writePatternFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParsePermissionSetPatterns_FileReference(t *testing.T) {
+	path := writePatternFile(t, `# Read-only roles
+ReadOnly*
+
+  re:^Audit-
+# trailing comment
+`)
+
+	result, err := ParsePermissionSetPatterns("AdminAccess,@" + path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, p := range result {
+		got = append(got, p.String())
+	}
+	if strings.Join(got, ",") != "AdminAccess,ReadOnly*,re:^Audit-" {
+		t.Errorf("patterns = %v", got)
+	}
+
+	if !result.Match("Audit-Logs") || result.Match("PowerUser") {
+		t.Error("file patterns not applied")
+	}
+}
+
+func TestParseAccountPatterns_FileReference(t *testing.T) {
+	path := writePatternFile(t, "111111111111\n# comment\nbad-id\n")
+
+	_, err := ParseAccountPatterns("@" + path)
+	if err == nil {
+		t.Fatal("expected error for invalid account ID in file")
+	}
+	if !strings.Contains(err.Error(), path+" line 3") {
+		t.Errorf("error = %q, want it to reference the file and line", err.Error())
+	}
+}
+
+func TestParsePatternList_FileErrors(t *testing.T) {
+	nested := writePatternFile(t, "@other.txt\n")
+
+	tests := []struct {
+		name        string
+		input       string
+		errContains string
+	}{
+		{name: "missing file", input: "@/nonexistent/list.txt", errContains: "unable to read pattern file"},
+		{name: "nested reference", input: "@" + nested, errContains: "nested file references"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePermissionSetPatterns(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestParsePermissionSetPatterns_Commas(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		entries []string
+		matches map[string]bool
+	}{
+		{
+			name:    "regex repetition",
+			input:   `re:^Team-[0-9]{2,3}$,AdminAccess`,
+			entries: []string{`re:^Team-[0-9]{2,3}$`, "AdminAccess"},
+			matches: map[string]bool{"Team-12": true, "Team-1234": false, "AdminAccess": true},
+		},
+		{
+			name:    "regex character class",
+			input:   `re:^a[,;]b$, ReadOnly`,
+			entries: []string{`re:^a[,;]b$`, "ReadOnly"},
+			matches: map[string]bool{"a,b": true, "a;b": true, "ReadOnly": true},
+		},
+		{
+			name:    "escaped comma in regex",
+			input:   `re:^a\,b$,c`,
+			entries: []string{`re:^a\,b$`, "c"},
+			matches: map[string]bool{"a,b": true, "c": true},
+		},
+		{
+			name:    "escaped comma in name",
+			input:   `Ops\,Team,Dev*`,
+			entries: []string{"Ops,Team", "Dev*"},
+			matches: map[string]bool{"Ops,Team": true, "Ops": false, "Developer": true},
+		},
+		{
+			name:    "escaped brace in regex",
+			input:   `re:^a\{,b`,
+			entries: []string{`re:^a\{`, "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := ParsePermissionSetPatterns(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, p := range list {
+				got = append(got, p.String())
+			}
+			if strings.Join(got, "|") != strings.Join(tt.entries, "|") {
+				t.Errorf("entries = %q, want %q", got, tt.entries)
+			}
+			for value, want := range tt.matches {
+				if list.Match(value) != want {
+					t.Errorf("Match(%q) = %v, want %v", value, !want, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHasPatternFile(t *testing.T) {
+	tests := map[string]bool{
+		"":                      false,
+		"1234*,re:^5678":        false,
+		"re:^@admin":            false,
+		`Ops\,@Team`:            false,
+		"@accounts.txt":         true,
+		"1234*, @/proc/self/fd": true,
+	}
+	for patterns, want := range tests {
+		if got := HasPatternFile(patterns); got != want {
+			t.Errorf("HasPatternFile(%q) = %v, want %v", patterns, got, want)
+		}
+	}
+}
+
+func TestJoinPatterns(t *testing.T) {
+	patterns := []string{"Ops,Team", `re:^[0-9]{2,3}$`, "re:^a,b$", "Dev*"}
+	list, err := ParsePermissionSetPatterns(JoinPatterns(patterns))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list) != len(patterns) {
+		t.Fatalf("JoinPatterns(%q) parsed into %d patterns", patterns, len(list))
+	}
+	for _, value := range []string{"Ops,Team", "123", "a,b", "Developer"} {
+		if !list.Match(value) {
+			t.Errorf("%q did not match", value)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package setlist
 
 // ListPermissionsRequired returns a slice of AWS IAM permission strings
 // that are required for this application to function correctly.
-// These permissions are needed to access AWS Organizations and SSO Admin APIs.
+// These permissions are needed to access AWS Organizations and SSO Admin APIs,
+// and sts:GetCallerIdentity to scope the API response cache to the account
+// that owns the credentials.
 func ListPermissionsRequired() []string {
 	return []string{
 		"organizations:ListAccounts",
@@ -10,5 +12,41 @@ func ListPermissionsRequired() []string {
 		"sso:ListPermissionSets",
 		"sso:ListPermissionSetsProvisionedToAccount",
 		"sso:DescribePermissionSet",
+		"sts:GetCallerIdentity",
 	}
 }
+
+// ListAssumeRolePermissionsRequired returns the AWS IAM permissions needed
+// by the credentials that assume a role before calling AWS. The other
+// permissions then belong on the assumed role.
+func ListAssumeRolePermissionsRequired() []string {
+	return []string{
+		"sts:AssumeRole",
+	}
+}
+
+// ListFilterPermissionsRequired returns the additional AWS IAM permissions
+// needed when a filter expression references tags or OU paths.
+func ListFilterPermissionsRequired() []string {
+	return []string{
+		"organizations:ListTagsForResource",
+		"organizations:ListParents",
+		"organizations:DescribeOrganizationalUnit",
+		"sso:ListTagsForResource",
+	}
+}
+
+// FilterPermissionsRequired returns the subset of
+// ListFilterPermissionsRequired that filter needs: the tag permissions
+// when it references tags, and the OU permissions when it references
+// ou_path.
+func FilterPermissionsRequired(filter *Filter) []string {
+	var perms []string
+	if filter.NeedsTags() {
+		perms = append(perms, "organizations:ListTagsForResource", "sso:ListTagsForResource")
+	}
+	if filter.NeedsOUPath() {
+		perms = append(perms, "organizations:ListParents", "organizations:DescribeOrganizationalUnit")
+	}
+	return perms
+}
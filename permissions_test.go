@@ -1,6 +1,7 @@
 package setlist
 
 import (
+	"slices"
 	"testing"
 )
 
@@ -10,4 +11,49 @@ func TestListPermissions(t *testing.T) {
 	if len(result) == 0 {
 		t.Errorf("ListPermissionsRequired() must have at least one permission")
 	}
+
+	// The API response cache is scoped by the caller's account.
+	if !slices.Contains(result, "sts:GetCallerIdentity") {
+		t.Errorf("ListPermissionsRequired() = %v, want sts:GetCallerIdentity", result)
+	}
+}
+
+func TestListAssumeRolePermissions(t *testing.T) {
+	result := ListAssumeRolePermissionsRequired()
+
+	if !slices.Contains(result, "sts:AssumeRole") {
+		t.Errorf("ListAssumeRolePermissionsRequired() = %v, want sts:AssumeRole", result)
+	}
+	if slices.Contains(ListPermissionsRequired(), "sts:AssumeRole") {
+		t.Error("sts:AssumeRole is only needed with a role to assume")
+	}
+}
+
+func TestFilterPermissionsRequired(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       []string
+	}{
+		{expression: `account.name == "prod"`},
+		{expression: `account.tags.env == "prod"`, want: []string{"organizations:ListTagsForResource", "sso:ListTagsForResource"}},
+		{expression: `account.ou_path.startsWith("Root/Prod")`, want: []string{"organizations:ListParents", "organizations:DescribeOrganizationalUnit"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expression, func(t *testing.T) {
+			f, err := NewFilter(tc.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := FilterPermissionsRequired(f)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("FilterPermissionsRequired() = %v, want %v", got, tc.want)
+			}
+			for _, p := range got {
+				if !slices.Contains(ListFilterPermissionsRequired(), p) {
+					t.Errorf("%s is missing from ListFilterPermissionsRequired()", p)
+				}
+			}
+		})
+	}
 }
@@ -0,0 +1,144 @@
+package setlist
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidRegionFormat is returned for a name that is not shaped like an
+// AWS region.
+var ErrInvalidRegionFormat = errors.New("invalid region format")
+
+// ErrUnknownRegion is returned for a well-formed region name that is not in
+// this release's region table.
+var ErrUnknownRegion = errors.New("unknown region")
+
+// AWS partitions. The partition decides the domain of the SSO start URL and
+// of the service endpoints.
+const (
+	PartitionAWS      = "aws"
+	PartitionAWSUSGov = "aws-us-gov"
+	PartitionAWSCN    = "aws-cn"
+	PartitionAWSISO   = "aws-iso"
+	PartitionAWSISOB  = "aws-iso-b"
+	PartitionAWSISOE  = "aws-iso-e"
+	PartitionAWSISOF  = "aws-iso-f"
+	PartitionAWSEUSC  = "aws-eusc"
+)
+
+// regions maps every region this release knows about to its partition.
+var regions = map[string]string{
+	"af-south-1":     PartitionAWS,
+	"ap-east-1":      PartitionAWS,
+	"ap-east-2":      PartitionAWS,
+	"ap-northeast-1": PartitionAWS,
+	"ap-northeast-2": PartitionAWS,
+	"ap-northeast-3": PartitionAWS,
+	"ap-south-1":     PartitionAWS,
+	"ap-south-2":     PartitionAWS,
+	"ap-southeast-1": PartitionAWS,
+	"ap-southeast-2": PartitionAWS,
+	"ap-southeast-3": PartitionAWS,
+	"ap-southeast-4": PartitionAWS,
+	"ap-southeast-5": PartitionAWS,
+	"ap-southeast-7": PartitionAWS,
+	"ca-central-1":   PartitionAWS,
+	"ca-west-1":      PartitionAWS,
+	"eu-central-1":   PartitionAWS,
+	"eu-central-2":   PartitionAWS,
+	"eu-north-1":     PartitionAWS,
+	"eu-south-1":     PartitionAWS,
+	"eu-south-2":     PartitionAWS,
+	"eu-west-1":      PartitionAWS,
+	"eu-west-2":      PartitionAWS,
+	"eu-west-3":      PartitionAWS,
+	"il-central-1":   PartitionAWS,
+	"me-central-1":   PartitionAWS,
+	"me-south-1":     PartitionAWS,
+	"mx-central-1":   PartitionAWS,
+	"sa-east-1":      PartitionAWS,
+	"us-east-1":      PartitionAWS,
+	"us-east-2":      PartitionAWS,
+	"us-west-1":      PartitionAWS,
+	"us-west-2":      PartitionAWS,
+
+	"us-gov-east-1": PartitionAWSUSGov,
+	"us-gov-west-1": PartitionAWSUSGov,
+
+	"cn-north-1":     PartitionAWSCN,
+	"cn-northwest-1": PartitionAWSCN,
+}
+
+// partitionPrefixes places regions missing from the table by the prefix of
+// their name. Longer prefixes come first, so us-isob- is not read as us-iso-.
+var partitionPrefixes = []struct {
+	prefix    string
+	partition string
+}{
+	{"us-gov-", PartitionAWSUSGov},
+	{"us-isob-", PartitionAWSISOB},
+	{"us-isof-", PartitionAWSISOF},
+	{"us-iso-", PartitionAWSISO},
+	{"eu-isoe-", PartitionAWSISOE},
+	{"eusc-", PartitionAWSEUSC},
+	{"cn-", PartitionAWSCN},
+}
+
+// regionFormat matches the shape of an AWS region name: lowercase words
+// joined by hyphens and ending in a number, e.g. eu-west-1, us-gov-east-1,
+// us-isob-east-1 or eusc-de-east-1.
+var regionFormat = regexp.MustCompile(`^[a-z]+(-[a-z]+)+-[0-9]+$`)
+
+type Region string
+
+// NewRegion returns region if it is a region this release knows about.
+// Well-formed names missing from the table return ErrUnknownRegion; use
+// NewRegionAllowUnknown to accept regions launched after this release.
+func NewRegion(region string) (Region, error) {
+	r, err := NewRegionAllowUnknown(region)
+	if err != nil {
+		return Region(""), err
+	}
+
+	if _, ok := regions[region]; !ok {
+		return Region(""), fmt.Errorf("%w: %s", ErrUnknownRegion, region)
+	}
+
+	return r, nil
+}
+
+// NewRegionAllowUnknown returns region if it is shaped like an AWS region
+// name, whether or not this release knows about it.
+func NewRegionAllowUnknown(region string) (Region, error) {
+	if region == "" {
+		return Region(""), ErrEmptyString
+	}
+
+	if !regionFormat.MatchString(region) {
+		return Region(""), fmt.Errorf("%w: %s", ErrInvalidRegionFormat, region)
+	}
+
+	return Region(region), nil
+}
+
+func (r Region) String() string {
+	return string(r)
+}
+
+// Partition returns the partition the region belongs to. Regions missing
+// from the table are placed by their prefix, and those with a prefix this
+// release doesn't recognise are assumed to be in the commercial partition.
+func (r Region) Partition() string {
+	if p, ok := regions[string(r)]; ok {
+		return p
+	}
+
+	for _, pp := range partitionPrefixes {
+		if strings.HasPrefix(string(r), pp.prefix) {
+			return pp.partition
+		}
+	}
+	return PartitionAWS
+}
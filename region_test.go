@@ -0,0 +1,183 @@
+package setlist
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewRegion(t *testing.T) {
+	tt := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "valid region",
+			input:   "ca-central-1",
+			want:    "ca-central-1",
+			wantErr: nil,
+		},
+		{
+			name:    "israel",
+			input:   "il-central-1",
+			want:    "il-central-1",
+			wantErr: nil,
+		},
+		{
+			name:    "mexico",
+			input:   "mx-central-1",
+			want:    "mx-central-1",
+			wantErr: nil,
+		},
+		{
+			name:    "govcloud",
+			input:   "us-gov-west-1",
+			want:    "us-gov-west-1",
+			wantErr: nil,
+		},
+		{
+			name:    "china",
+			input:   "cn-north-1",
+			want:    "cn-north-1",
+			wantErr: nil,
+		},
+		{
+			name:    "empty string",
+			input:   "",
+			want:    "",
+			wantErr: ErrEmptyString,
+		},
+		{
+			name:    "malformed",
+			input:   "invalid-region",
+			want:    "",
+			wantErr: ErrInvalidRegionFormat,
+		},
+		{
+			name:    "unknown region",
+			input:   "xx-central-1",
+			want:    "",
+			wantErr: ErrUnknownRegion,
+		},
+		{
+			name:    "unknown sovereign cloud region",
+			input:   "eusc-de-east-1",
+			want:    "",
+			wantErr: ErrUnknownRegion,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewRegion(tc.input)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("NewRegion(%q) error = %v, want %v", tc.input, err, tc.wantErr)
+			}
+
+			if got.String() != tc.want {
+				t.Errorf("NewRegion(%q) = %q, want %q", tc.input, got.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestNewRegionAllowUnknown(t *testing.T) {
+	tt := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "known region",
+			input: "us-east-1",
+			want:  "us-east-1",
+		},
+		{
+			name:  "unknown region",
+			input: "xx-central-1",
+			want:  "xx-central-1",
+		},
+		{
+			name:  "sovereign cloud prefix",
+			input: "eusc-de-east-1",
+			want:  "eusc-de-east-1",
+		},
+		{
+			name:  "iso-b prefix",
+			input: "us-isob-east-1",
+			want:  "us-isob-east-1",
+		},
+		{
+			name:  "several words",
+			input: "xx-north-central-2",
+			want:  "xx-north-central-2",
+		},
+		{
+			name:    "no number",
+			input:   "us-east",
+			wantErr: ErrInvalidRegionFormat,
+		},
+		{
+			name:    "uppercase",
+			input:   "US-EAST-1",
+			wantErr: ErrInvalidRegionFormat,
+		},
+		{
+			name:    "empty string",
+			input:   "",
+			wantErr: ErrEmptyString,
+		},
+		{
+			name:    "malformed",
+			input:   "mars-1",
+			wantErr: ErrInvalidRegionFormat,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewRegionAllowUnknown(tc.input)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("NewRegionAllowUnknown(%q) error = %v, want %v", tc.input, err, tc.wantErr)
+			}
+
+			if got.String() != tc.want {
+				t.Errorf("NewRegionAllowUnknown(%q) = %q, want %q", tc.input, got.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestRegionPartition(t *testing.T) {
+	tt := []struct {
+		region string
+		want   string
+	}{
+		{"us-east-1", PartitionAWS},
+		{"il-central-1", PartitionAWS},
+		{"us-gov-west-1", PartitionAWSUSGov},
+		{"cn-north-1", PartitionAWSCN},
+		{"cn-northwest-1", PartitionAWSCN},
+		{"us-gov-north-1", PartitionAWSUSGov},
+		{"cn-south-1", PartitionAWSCN},
+		{"xx-central-1", PartitionAWS},
+		{"eusc-de-east-1", PartitionAWSEUSC},
+		{"us-isob-east-1", PartitionAWSISOB},
+		{"us-iso-east-1", PartitionAWSISO},
+		{"us-isof-south-1", PartitionAWSISOF},
+		{"eu-isoe-west-1", PartitionAWSISOE},
+		{"zz-new-east-1", PartitionAWS},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.region, func(t *testing.T) {
+			if got := Region(tc.region).Partition(); got != tc.want {
+				t.Errorf("Region(%q).Partition() = %q, want %q", tc.region, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+// Package settings resolves setlist options from their possible sources:
+// command-line flags, SETLIST_* environment variables, a named context in
+// .setlist.yaml, the top-level values of .setlist.yaml and built-in
+// defaults.
+//
+// Every option is identified by its key, which matches the CLI flag name
+// (e.g. "sso-region"). Values are carried as the strings a flag would
+// accept, so the same resolution is shared by the CLI and the Lambda.
+package settings
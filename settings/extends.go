@@ -0,0 +1,320 @@
+package settings
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scottbrown/setlist"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"gopkg.in/yaml.v3"
+)
+
+// extendsKey names the key that points a config file at its base.
+const extendsKey = "extends"
+
+// DefaultFetchTimeout bounds each request for a remote base file when the
+// Loader has no HTTP client of its own.
+const DefaultFetchTimeout = 30 * time.Second
+
+// S3GetObjectClient is the part of the S3 API needed to fetch a base file
+// from an s3:// location.
+type S3GetObjectClient interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// SSMGetParameterClient is the part of the SSM API needed to read a config
+// file from an ssm: location.
+type SSMGetParameterClient interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// Loader reads config files, following extends: references to local
+// paths, https:// URLs, s3:// locations and ssm: Parameter Store
+// parameters (e.g. ssm:/setlist/config). The clients are used only when a
+// file is remote.
+type Loader struct {
+	HTTPClient setlist.HTTPDoer
+	S3Client   S3GetObjectClient
+	SSMClient  SSMGetParameterClient
+}
+
+// Load reads the config file at location and every base it extends,
+// merging each file over its base. Maps merge key by key, lists are
+// appended to the base list without duplicates, and any other value
+// replaces the base value.
+func (l *Loader) Load(ctx context.Context, location string) (*File, error) {
+	merged, err := l.loadRaw(ctx, location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("unable to merge config file %s: %w", location, err)
+	}
+
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", location, err)
+	}
+	return cfg, nil
+}
+
+// loadRaw reads location as a YAML map node and merges it over its base.
+// chain holds the files already being loaded, to detect cycles.
+func (l *Loader) loadRaw(ctx context.Context, location string, chain []string) (*yaml.Node, error) {
+	for _, seen := range chain {
+		if seen == location {
+			return nil, fmt.Errorf("config extends cycle: %s", strings.Join(append(chain, location), " -> "))
+		}
+	}
+	chain = append(chain, location)
+
+	data, err := l.fetch(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the file on its own first so errors point at the right file
+	// and line.
+	if _, err := Parse(data); err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", location, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", location, err)
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s is not a YAML map", location)
+	}
+
+	ref := removeMappingKey(root, extendsKey)
+	if ref == nil {
+		return root, nil
+	}
+	if ref.Kind != yaml.ScalarNode || ref.Value == "" {
+		return nil, fmt.Errorf("config file %s line %d: %s must be a path or URL", location, ref.Line, extendsKey)
+	}
+
+	baseLocation, err := resolveLocation(location, ref.Value)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", location, err)
+	}
+
+	base, err := l.loadRaw(ctx, baseLocation, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeNodes(base, root), nil
+}
+
+// fetch returns the contents of a local path, https:// URL, s3:// location
+// or ssm: parameter.
+func (l *Loader) fetch(ctx context.Context, location string) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// Not a URL, or a Windows drive letter.
+		data, err := os.ReadFile(location) //#nosec: G304
+		if err != nil {
+			return nil, fmt.Errorf("unable to read config file %s: %w", location, err)
+		}
+		return data, nil
+	}
+
+	switch u.Scheme {
+	case "https":
+		return l.fetchHTTPS(ctx, location)
+	case "s3":
+		return l.fetchS3(ctx, u)
+	case "ssm":
+		return l.fetchSSM(ctx, u)
+	default:
+		return nil, fmt.Errorf("unable to read config file %s: unsupported scheme %q (use a path, https://, s3:// or ssm:)", location, u.Scheme)
+	}
+}
+
+func (l *Loader) fetchHTTPS(ctx context.Context, location string) ([]byte, error) {
+	client := l.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: DefaultFetchTimeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config file %s: %w", location, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config file %s: %w", location, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch config file %s: status %d", location, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config file %s: %w", location, err)
+	}
+	return data, nil
+}
+
+func (l *Loader) fetchS3(ctx context.Context, u *url.URL) ([]byte, error) {
+	if l.S3Client == nil {
+		return nil, fmt.Errorf("unable to fetch config file %s: no S3 client configured", u)
+	}
+
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	out, err := l.S3Client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config file %s: %w", u, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config file %s: %w", u, err)
+	}
+	return data, nil
+}
+
+// fetchSSM reads a Parameter Store parameter, decrypting SecureStrings. The
+// parameter is named by the path of an ssm:/name/with/slashes location or
+// the opaque part of ssm:name.
+func (l *Loader) fetchSSM(ctx context.Context, u *url.URL) ([]byte, error) {
+	if l.SSMClient == nil {
+		return nil, fmt.Errorf("unable to fetch config file %s: no SSM client configured", u)
+	}
+
+	name := u.Opaque
+	if name == "" {
+		name = u.Path
+	}
+	if name == "" {
+		return nil, fmt.Errorf("unable to fetch config file %s: no parameter name", u)
+	}
+
+	out, err := l.SSMClient.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config file %s: %w", u, err)
+	}
+	if out.Parameter == nil {
+		return nil, fmt.Errorf("unable to fetch config file %s: parameter has no value", u)
+	}
+	return []byte(aws.ToString(out.Parameter.Value)), nil
+}
+
+// resolveLocation resolves ref relative to the file that refers to it.
+// Absolute paths and URLs are returned as-is, and "~/" expands to the home
+// directory.
+func resolveLocation(from, ref string) (string, error) {
+	if u, err := url.Parse(ref); err == nil && len(u.Scheme) > 1 {
+		return ref, nil
+	}
+
+	if rest, ok := strings.CutPrefix(ref, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("unable to determine home directory: %w", err)
+		}
+		return filepath.Join(home, rest), nil
+	}
+
+	if fromURL, err := url.Parse(from); err == nil && len(fromURL.Scheme) > 1 {
+		refURL, err := url.Parse(ref)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s reference %q: %w", extendsKey, ref, err)
+		}
+		return fromURL.ResolveReference(refURL).String(), nil
+	}
+
+	if filepath.IsAbs(ref) {
+		return ref, nil
+	}
+	return filepath.Join(filepath.Dir(from), ref), nil
+}
+
+// removeMappingKey deletes key from the YAML map m, returning its value
+// node or nil if absent.
+func removeMappingKey(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			value := m.Content[i+1]
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return value
+		}
+	}
+	return nil
+}
+
+// mergeNodes returns base with over merged into it. Maps merge key by key
+// and recursively, lists are appended without duplicates, and any other
+// value in over replaces the one in base.
+func mergeNodes(base, over *yaml.Node) *yaml.Node {
+	switch {
+	case base.Kind == yaml.MappingNode && over.Kind == yaml.MappingNode:
+		result := *base
+		result.Content = append([]*yaml.Node{}, base.Content...)
+		for i := 0; i+1 < len(over.Content); i += 2 {
+			key, value := over.Content[i], over.Content[i+1]
+
+			found := false
+			for j := 0; j+1 < len(result.Content); j += 2 {
+				if result.Content[j].Value == key.Value {
+					result.Content[j+1] = mergeNodes(result.Content[j+1], value)
+					found = true
+					break
+				}
+			}
+			if !found {
+				result.Content = append(result.Content, key, value)
+			}
+		}
+		return &result
+
+	case base.Kind == yaml.SequenceNode && over.Kind == yaml.SequenceNode:
+		result := *base
+		result.Content = append([]*yaml.Node{}, base.Content...)
+		for _, item := range over.Content {
+			if item.Kind != yaml.ScalarNode || !containsScalar(result.Content, item.Value) {
+				result.Content = append(result.Content, item)
+			}
+		}
+		return &result
+
+	default:
+		return over
+	}
+}
+
+// containsScalar reports whether nodes holds a scalar with the given value.
+func containsScalar(nodes []*yaml.Node, value string) bool {
+	for _, n := range nodes {
+		if n.Kind == yaml.ScalarNode && n.Value == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoader_Load_LocalChain(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "team/base.yaml", `sso-session: corp
+sso-region: us-east-1
+mapping:
+  "111111111111": prod
+include-permission-sets:
+  - ReadOnly*
+contexts:
+  prod:
+    profile: prod-admin
+`)
+	writeFile(t, dir, "team/shared.yaml", `extends: base.yaml
+include-permission-sets:
+  - Admin*
+`)
+	path := writeFile(t, dir, "me.yaml", `extends: team/shared.yaml
+sso-region: ca-central-1
+mapping:
+  "222222222222": dev
+include-permission-sets:
+  - ReadOnly*
+  - Billing
+contexts:
+  prod:
+    sso-region: us-west-2
+`)
+
+	cfg, err := (&Loader{}).Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.SSOSession != "corp" {
+		t.Errorf("SSOSession = %q, want base value", cfg.SSOSession)
+	}
+	if cfg.SSORegion != "ca-central-1" {
+		t.Errorf("SSORegion = %q, want override", cfg.SSORegion)
+	}
+	if cfg.Mapping != "111111111111=prod,222222222222=dev" {
+		t.Errorf("Mapping = %q, want maps merged", cfg.Mapping)
+	}
+	if cfg.IncludePermissionSets != "ReadOnly*,Admin*,Billing" {
+		t.Errorf("IncludePermissionSets = %q, want lists appended without duplicates", cfg.IncludePermissionSets)
+	}
+	if prod := cfg.Contexts["prod"]; prod.Profile != "prod-admin" || prod.SSORegion != "us-west-2" {
+		t.Errorf("prod context = %+v, want contexts merged", prod)
+	}
+	if cfg.Extends != "" {
+		t.Errorf("Extends = %q, want it consumed by the merge", cfg.Extends)
+	}
+}
+
+func TestLoader_Load_Cycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.yaml", "extends: b.yaml\n")
+	writeFile(t, dir, "b.yaml", "extends: a.yaml\n")
+
+	_, err := (&Loader{}).Load(context.Background(), filepath.Join(dir, "a.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "extends cycle") {
+		t.Errorf("error = %v, want a cycle error", err)
+	}
+}
+
+func TestLoader_Load_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name        string
+		content     string
+		errContains string
+	}{
+		{name: "missing base", content: "extends: missing.yaml\n", errContains: "missing.yaml"},
+		{name: "empty extends", content: "extends: \"\"\n", errContains: "must be a path or URL"},
+		{name: "unsupported scheme", content: "extends: ftp://example.com/base.yaml\n", errContains: "unsupported scheme"},
+		{name: "s3 without client", content: "extends: s3://bucket/base.yaml\n", errContains: "no S3 client"},
+		{name: "ssm without client", content: "extends: ssm:/setlist/base\n", errContains: "no SSM client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, dir, "config.yaml", tt.content)
+			_, err := (&Loader{}).Load(context.Background(), path)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+type stubHTTPClient struct {
+	files    map[string]string
+	requests []string
+}
+
+func (c *stubHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests = append(c.requests, req.URL.String())
+	body, ok := c.files[req.URL.String()]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+type stubS3Client struct {
+	objects map[string]string
+}
+
+func (c *stubS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	body, ok := c.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestLoader_Load_Remote(t *testing.T) {
+	httpClient := &stubHTTPClient{files: map[string]string{
+		"https://config.example.com/setlist/team.yaml": "extends: s3://platform/setlist/base.yaml\nsso-region: eu-west-1\n",
+	}}
+	s3Client := &stubS3Client{objects: map[string]string{
+		"platform/setlist/base.yaml": "sso-session: corp\nsso-region: us-east-1\n",
+	}}
+
+	path := writeFile(t, t.TempDir(), "me.yaml", "extends: https://config.example.com/setlist/team.yaml\nprofile: me\n")
+
+	cfg, err := (&Loader{HTTPClient: httpClient, S3Client: s3Client}).Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.SSOSession != "corp" || cfg.SSORegion != "eu-west-1" || cfg.Profile != "me" {
+		t.Errorf("merged config = %+v", cfg.Values)
+	}
+	if len(httpClient.requests) != 1 {
+		t.Errorf("HTTP requests = %v, want one", httpClient.requests)
+	}
+}
+
+func TestLoader_Load_RemoteRelative(t *testing.T) {
+	httpClient := &stubHTTPClient{files: map[string]string{
+		"https://config.example.com/setlist/team.yaml": "extends: base.yaml\n",
+		"https://config.example.com/setlist/base.yaml": "sso-session: corp\n",
+	}}
+
+	path := writeFile(t, t.TempDir(), "me.yaml", "extends: https://config.example.com/setlist/team.yaml\n")
+
+	cfg, err := (&Loader{HTTPClient: httpClient}).Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SSOSession != "corp" {
+		t.Errorf("SSOSession = %q, want value from relative remote base", cfg.SSOSession)
+	}
+}
+
+func TestLoader_Load_RemoteNotFound(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "me.yaml", "extends: https://config.example.com/missing.yaml\n")
+
+	_, err := (&Loader{HTTPClient: &stubHTTPClient{}}).Load(context.Background(), path)
+	if err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("error = %v, want a 404 error", err)
+	}
+}
+
+type stubSSMClient struct {
+	parameters map[string]string
+	decrypted  bool
+}
+
+func (c *stubSSMClient) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	c.decrypted = aws.ToBool(params.WithDecryption)
+	value, ok := c.parameters[aws.ToString(params.Name)]
+	if !ok {
+		return nil, errors.New("ParameterNotFound")
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Value: aws.String(value)}}, nil
+}
+
+func TestLoader_Load_SSM(t *testing.T) {
+	ssmClient := &stubSSMClient{parameters: map[string]string{
+		"/setlist/team": "extends: base\nsso-region: eu-west-1\n",
+		"/setlist/base": "sso-session: corp\nsso-region: us-east-1\n",
+		"flat-name":     "sso-session: flat\n",
+	}}
+	loader := &Loader{SSMClient: ssmClient}
+
+	cfg, err := loader.Load(context.Background(), "ssm:/setlist/team")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SSOSession != "corp" || cfg.SSORegion != "eu-west-1" {
+		t.Errorf("merged config = %+v", cfg.Values)
+	}
+	if !ssmClient.decrypted {
+		t.Error("expected SecureString decryption to be requested")
+	}
+
+	cfg, err = loader.Load(context.Background(), "ssm:flat-name")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SSOSession != "flat" {
+		t.Errorf("SSOSession = %q, want flat", cfg.SSOSession)
+	}
+
+	if _, err := loader.Load(context.Background(), "ssm:/setlist/missing"); err == nil || !strings.Contains(err.Error(), "ParameterNotFound") {
+		t.Errorf("error = %v, want ParameterNotFound", err)
+	}
+}
@@ -0,0 +1,233 @@
+package settings
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scottbrown/setlist"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFilename is the name of the config file in the user's home
+// directory.
+const DefaultFilename = ".setlist.yaml"
+
+// Version is the newest .setlist.yaml schema version this build
+// understands. Files without a version are treated as version 1.
+const Version = 1
+
+// File is the on-disk .setlist.yaml document. Top-level settings
+// act as defaults for every context.
+type File struct {
+	Version        int               `yaml:"version,omitempty"`
+	Extends        string            `yaml:"extends,omitempty"`
+	CurrentContext string            `yaml:"current-context,omitempty"`
+	Contexts       map[string]Values `yaml:"contexts,omitempty"`
+	Values         `yaml:",inline"`
+}
+
+// Values holds the options that can be set at the top level of
+// .setlist.yaml or inside a named context. Keys match flag names.
+type Values struct {
+	SSOSession            string        `yaml:"sso-session,omitempty"`
+	SSORegion             string        `yaml:"sso-region,omitempty"`
+	AllowUnknownRegion    *bool         `yaml:"allow-unknown-region,omitempty"`
+	Profile               string        `yaml:"profile,omitempty"`
+	AssumeRoleArn         string        `yaml:"assume-role-arn,omitempty"`
+	ExternalId            string        `yaml:"external-id,omitempty"`
+	RoleSessionName       string        `yaml:"role-session-name,omitempty"`
+	MFASerial             string        `yaml:"mfa-serial,omitempty"`
+	EndpointURL           string        `yaml:"endpoint-url,omitempty"`
+	OrgEndpointURL        string        `yaml:"organizations-endpoint-url,omitempty"`
+	SSOAdminEndpointURL   string        `yaml:"sso-admin-endpoint-url,omitempty"`
+	S3EndpointURL         string        `yaml:"s3-endpoint-url,omitempty"`
+	STSEndpointURL        string        `yaml:"sts-endpoint-url,omitempty"`
+	Mapping               StringMap     `yaml:"mapping,omitempty"`
+	Output                string        `yaml:"output,omitempty"`
+	Stdout                *bool         `yaml:"stdout,omitempty"`
+	SSOFriendlyName       string        `yaml:"sso-friendly-name,omitempty"`
+	SSOStartURL           string        `yaml:"sso-start-url,omitempty"`
+	Verbose               *bool         `yaml:"verbose,omitempty"`
+	LogFormat             string        `yaml:"log-format,omitempty"`
+	IncludeAccounts       StringList    `yaml:"include-accounts,omitempty"`
+	ExcludeAccounts       StringList    `yaml:"exclude-accounts,omitempty"`
+	IncludePermissionSets StringList    `yaml:"include-permission-sets,omitempty"`
+	ExcludePermissionSets StringList    `yaml:"exclude-permission-sets,omitempty"`
+	Filter                string        `yaml:"filter,omitempty"`
+	FromSSOSession        string        `yaml:"from-sso-session,omitempty"`
+	InstanceArn           string        `yaml:"instance-arn,omitempty"`
+	IdentityStoreId       string        `yaml:"identity-store-id,omitempty"`
+	NoCache               *bool         `yaml:"no-cache,omitempty"`
+	CacheTTL              time.Duration `yaml:"cache-ttl,omitempty"`
+}
+
+// merge returns a copy of s with every setting that is set in over
+// replaced by the value from over.
+func (s Values) merge(over Values) Values {
+	result := s
+	dst := reflect.ValueOf(&result).Elem()
+	src := reflect.ValueOf(over)
+	for i := 0; i < src.NumField(); i++ {
+		if !src.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	return result
+}
+
+// ContextNames returns the names of the configured contexts, sorted.
+func (c *File) ContextNames() []string {
+	names := make([]string, 0, len(c.Contexts))
+	for name := range c.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the effective settings for the named context: the
+// top-level settings overlaid with the context's own. An empty name falls
+// back to current-context, and with neither set the top-level settings are
+// returned as-is.
+func (c *File) Resolve(name string) (Values, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return c.Values, nil
+	}
+
+	ctxSettings, ok := c.Contexts[name]
+	if !ok {
+		if len(c.Contexts) == 0 {
+			return Values{}, fmt.Errorf("context %q not found: no contexts are defined", name)
+		}
+		return Values{}, fmt.Errorf("context %q not found (available: %s)", name, strings.Join(c.ContextNames(), ", "))
+	}
+
+	return c.Values.merge(ctxSettings), nil
+}
+
+// StringList is a list written either as a native YAML sequence or as a
+// comma-delimited string. Either way it is flattened into the
+// comma-delimited form the matching flag accepts.
+type StringList string
+
+// UnmarshalYAML accepts a scalar or a sequence of scalars.
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*l = StringList(value.Value)
+		return nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(value.Content))
+		for _, item := range value.Content {
+			if item.Kind != yaml.ScalarNode {
+				return typeError(item.Line, "list entries must be strings")
+			}
+			items = append(items, item.Value)
+		}
+		*l = StringList(setlist.JoinPatterns(items))
+		return nil
+	default:
+		return typeError(value.Line, "expected a list or a comma-delimited string")
+	}
+}
+
+// StringMap is a key=value mapping written either as a native YAML map or
+// as a comma-delimited string of key=value pairs. Either way it is
+// flattened into the comma-delimited form the matching flag accepts, with
+// keys sorted so the result is stable.
+type StringMap string
+
+// UnmarshalYAML accepts a scalar or a mapping of scalars.
+func (m *StringMap) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*m = StringMap(value.Value)
+		return nil
+	case yaml.MappingNode:
+		entries := make(map[string]string, len(value.Content)/2)
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			k, v := value.Content[i], value.Content[i+1]
+			if k.Kind != yaml.ScalarNode || v.Kind != yaml.ScalarNode {
+				return typeError(k.Line, "map keys and values must be strings")
+			}
+			if strings.ContainsAny(k.Value, ",=") || strings.ContainsAny(v.Value, ",=") {
+				return typeError(k.Line, "map entry %q must not contain ',' or '='", k.Value)
+			}
+			entries[k.Value] = v.Value
+		}
+
+		keys := make([]string, 0, len(entries))
+		for k := range entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, k+"="+entries[k])
+		}
+		*m = StringMap(strings.Join(pairs, ","))
+		return nil
+	default:
+		return typeError(value.Line, "expected a map or a comma-delimited string")
+	}
+}
+
+// Parse decodes a .setlist.yaml document and checks its version.
+func Parse(data []byte) (*File, error) {
+	var cfg File
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Version < 0 || cfg.Version > Version {
+		return nil, fmt.Errorf("unsupported config version %d (this build supports up to %d)", cfg.Version, Version)
+	}
+
+	return &cfg, nil
+}
+
+// DefaultPath returns the path of the config file in the user's home
+// directory.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine home directory: %w", err)
+	}
+	return filepath.Join(home, DefaultFilename), nil
+}
+
+// Read reads and parses the config file at path, following extends:
+// references with a Loader that has no S3 client.
+func Read(path string) (*File, error) {
+	return (&Loader{}).Load(context.Background(), path)
+}
+
+// Keys returns the option keys that can be set in the config file, in
+// declaration order.
+func Keys() []string {
+	t := reflect.TypeOf(Values{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// typeError reports a problem with a value in the document. Returning a
+// *yaml.TypeError lets the decoder carry on and report every problem in
+// the file rather than stopping at the first.
+func typeError(line int, format string, args ...any) error {
+	return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: ", line) + fmt.Sprintf(format, args...)}}
+}
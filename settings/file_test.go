@@ -0,0 +1,210 @@
+package settings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scottbrown/setlist"
+)
+
+func TestParse_NativeForms(t *testing.T) {
+	cfg, err := Parse([]byte(`version: 1
+mapping:
+  "210987654321": staging
+  123456789012: prod
+include-accounts:
+  - 123456789012
+  - "2109*"
+exclude-accounts: "333333333333"
+cache-ttl: 1h
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Mapping != "123456789012=prod,210987654321=staging" {
+		t.Errorf("Mapping = %q, want sorted comma-delimited pairs", cfg.Mapping)
+	}
+	if cfg.IncludeAccounts != "123456789012,2109*" {
+		t.Errorf("IncludeAccounts = %q", cfg.IncludeAccounts)
+	}
+	if cfg.ExcludeAccounts != "333333333333" {
+		t.Errorf("ExcludeAccounts = %q", cfg.ExcludeAccounts)
+	}
+	if cfg.CacheTTL != time.Hour {
+		t.Errorf("CacheTTL = %v, want 1h", cfg.CacheTTL)
+	}
+}
+
+func TestFile_Resolve(t *testing.T) {
+	cfg, err := Parse([]byte(`sso-region: us-east-1
+verbose: true
+current-context: prod
+contexts:
+  prod:
+    sso-session: prod-org
+  sandbox:
+    sso-session: sandbox-org
+    sso-region: ca-central-1
+    verbose: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prod, err := cfg.Resolve("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prod.SSOSession != "prod-org" || prod.SSORegion != "us-east-1" || !*prod.Verbose {
+		t.Errorf("current-context resolved to %+v", prod)
+	}
+
+	sandbox, err := cfg.Resolve("sandbox")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sandbox.SSORegion != "ca-central-1" || *sandbox.Verbose {
+		t.Errorf("context values should override top-level ones, got %+v", sandbox)
+	}
+
+	if _, err := cfg.Resolve("missing"); err == nil || !strings.Contains(err.Error(), "available: prod, sandbox") {
+		t.Errorf("error = %v, want it to list the available contexts", err)
+	}
+}
+
+func TestParse_ListEntriesWithCommas(t *testing.T) {
+	cfg, err := Parse([]byte(`include-permission-sets:
+  - "Ops,Team"
+  - "re:^Team-[0-9]{2,3}$"
+  - "re:^a,b$"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	list, err := setlist.ParsePermissionSetPatterns(string(cfg.IncludePermissionSets))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, p := range list {
+		got = append(got, p.String())
+	}
+	want := []string{"Ops,Team", "re:^Team-[0-9]{2,3}$", `re:^a\,b$`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("patterns = %q, want %q", got, want)
+	}
+	for _, name := range []string{"Ops,Team", "Team-123", "a,b"} {
+		if !list.Match(name) {
+			t.Errorf("%q did not match", name)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		errContains string
+	}{
+		{
+			name:        "unsupported version",
+			content:     "version: 99\n",
+			errContains: "unsupported config version 99",
+		},
+		{
+			name:        "nested list entry",
+			content:     "include-accounts:\n  - [a]\n",
+			errContains: "list entries must be strings",
+		},
+		{
+			name:        "separator inside map value",
+			content:     "mapping:\n  \"123456789012\": a=b\n",
+			errContains: "must not contain",
+		},
+		{
+			name:        "mapping as list",
+			content:     "mapping:\n  - a\n",
+			errContains: "expected a map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+// yamlKeys returns the yaml keys of typ's fields, descending into inline
+// structs.
+func yamlKeys(typ reflect.Type) map[string]bool {
+	keys := map[string]bool{}
+	for i := 0; i < typ.NumField(); i++ {
+		key, opts, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+		if strings.Contains(opts, "inline") {
+			for k := range yamlKeys(typ.Field(i).Type) {
+				keys[k] = true
+			}
+			continue
+		}
+		if key != "" && key != "-" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+func TestConfigSchemaCoversAllKeys(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "setlist.schema.json"))
+	if err != nil {
+		t.Fatalf("unable to read schema: %v", err)
+	}
+
+	type object struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	var schema struct {
+		object
+		Definitions struct {
+			Settings object `json:"settings"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		typ        reflect.Type
+		properties map[string]json.RawMessage
+	}{
+		{name: "top level", typ: reflect.TypeOf(File{}), properties: schema.Properties},
+		{name: "context", typ: reflect.TypeOf(Values{}), properties: schema.Definitions.Settings.Properties},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := yamlKeys(tt.typ)
+			for key := range keys {
+				if _, ok := tt.properties[key]; !ok {
+					t.Errorf("schema is missing property %q", key)
+				}
+			}
+			for key := range tt.properties {
+				if !keys[key] {
+					t.Errorf("schema property %q is not a config key", key)
+				}
+			}
+		})
+	}
+}
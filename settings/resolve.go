@@ -0,0 +1,151 @@
+package settings
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to a key to form its environment variable name.
+const EnvPrefix = "SETLIST_"
+
+// Source names used when reporting where a value came from.
+const (
+	SourceFlag       = "flag"
+	SourceEnv        = "env"
+	SourceConfigFile = "config file"
+	SourceDefault    = "default"
+)
+
+// Layer is one source of settings. Values holds flag-style strings keyed
+// by option name.
+type Layer struct {
+	Source string
+	Values map[string]string
+
+	// Vars optionally names the variable each value was read from, which
+	// is appended to the reported source.
+	Vars map[string]string
+}
+
+// Setting is a resolved option value along with the source it came from.
+type Setting struct {
+	Value  string
+	Source string
+}
+
+// Resolved maps option keys to their effective settings.
+type Resolved map[string]Setting
+
+// Resolve returns the effective value of every key found in layers, given
+// in order of precedence: the first layer holding a key wins.
+func Resolve(layers ...Layer) Resolved {
+	result := Resolved{}
+	for _, l := range layers {
+		for k, v := range l.Values {
+			if _, ok := result[k]; ok {
+				continue
+			}
+			source := l.Source
+			if name, ok := l.Vars[k]; ok {
+				source += " " + name
+			}
+			result[k] = Setting{Value: v, Source: source}
+		}
+	}
+	return result
+}
+
+// Get returns the effective value of key, or "" if it is not set.
+func (r Resolved) Get(key string) string {
+	return r[key].Value
+}
+
+// Keys returns the resolved keys, sorted.
+func (r Resolved) Keys() []string {
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EnvName returns the environment variable that sets key, e.g.
+// "sso-region" becomes "SETLIST_SSO_REGION".
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// EnvLayer reads the SETLIST_* variable of each key using lookup, which is
+// usually os.LookupEnv. Variables that are set but empty are ignored.
+func EnvLayer(keys []string, lookup func(string) (string, bool)) Layer {
+	names := make(map[string]string, len(keys))
+	for _, k := range keys {
+		names[k] = EnvName(k)
+	}
+	return NamedEnvLayer(names, lookup)
+}
+
+// NamedEnvLayer reads environment variables whose names do not follow the
+// SETLIST_* convention. names maps each key to its variable.
+func NamedEnvLayer(names map[string]string, lookup func(string) (string, bool)) Layer {
+	values := map[string]string{}
+	for k, name := range names {
+		if v, ok := lookup(name); ok && v != "" {
+			values[k] = v
+		}
+	}
+	return Layer{Source: SourceEnv, Values: values, Vars: names}
+}
+
+// Map returns the options that are set, keyed by option name, as the
+// strings the matching flag accepts.
+func (s Values) Map() map[string]string {
+	result := map[string]string{}
+
+	v := reflect.ValueOf(s)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.IsZero() {
+			continue
+		}
+
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+
+		switch value := field.Interface().(type) {
+		case *bool:
+			result[key] = strconv.FormatBool(*value)
+		case time.Duration:
+			result[key] = value.String()
+		default:
+			result[key] = fmt.Sprint(field.Interface())
+		}
+	}
+
+	return result
+}
+
+// Layers returns the config file's contributions in order of precedence:
+// the selected context, if any, followed by the top-level values. An empty
+// name falls back to current-context.
+func (c *File) Layers(name string) ([]Layer, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+
+	top := Layer{Source: SourceConfigFile, Values: c.Values.Map()}
+	if name == "" {
+		return []Layer{top}, nil
+	}
+
+	if _, err := c.Resolve(name); err != nil {
+		return nil, err
+	}
+
+	return []Layer{{Source: "context " + name, Values: c.Contexts[name].Map()}, top}, nil
+}
@@ -0,0 +1,124 @@
+package settings
+
+import (
+	"testing"
+)
+
+func TestResolve_Precedence(t *testing.T) {
+	cfg, err := Parse([]byte(`sso-session: top-level
+sso-region: us-east-1
+profile: top-level
+output: top-level.config
+current-context: prod
+contexts:
+  prod:
+    sso-region: ca-central-1
+    profile: context
+    output: context.config
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileLayers, err := cfg.Layers("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"SETLIST_PROFILE": "env",
+		"SETLIST_OUTPUT":  "env.config",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	layers := []Layer{
+		{Source: SourceFlag, Values: map[string]string{"output": "flag.config"}},
+		EnvLayer([]string{"profile", "output", "sso-region"}, lookup),
+	}
+	layers = append(layers, fileLayers...)
+	layers = append(layers, Layer{Source: SourceDefault, Values: map[string]string{"output": "aws.config", "log-format": "plain"}})
+
+	resolved := Resolve(layers...)
+
+	tests := []struct {
+		key        string
+		wantValue  string
+		wantSource string
+	}{
+		{key: "output", wantValue: "flag.config", wantSource: "flag"},
+		{key: "profile", wantValue: "env", wantSource: "env SETLIST_PROFILE"},
+		{key: "sso-region", wantValue: "ca-central-1", wantSource: "context prod"},
+		{key: "sso-session", wantValue: "top-level", wantSource: "config file"},
+		{key: "log-format", wantValue: "plain", wantSource: "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got := resolved[tt.key]
+			if got.Value != tt.wantValue || got.Source != tt.wantSource {
+				t.Errorf("%s = %+v, want %q from %q", tt.key, got, tt.wantValue, tt.wantSource)
+			}
+		})
+	}
+
+	if got := resolved.Get("filter"); got != "" {
+		t.Errorf("unset key resolved to %q", got)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"sso-region":              "SETLIST_SSO_REGION",
+		"include-permission-sets": "SETLIST_INCLUDE_PERMISSION_SETS",
+		"stdout":                  "SETLIST_STDOUT",
+	}
+	for key, want := range tests {
+		if got := EnvName(key); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestNamedEnvLayer_IgnoresEmpty(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		return map[string]string{"SSO_SESSION": "", "SSO_REGION": "us-east-1"}[name], true
+	}
+
+	layer := NamedEnvLayer(map[string]string{"sso-session": "SSO_SESSION", "sso-region": "SSO_REGION"}, lookup)
+	resolved := Resolve(layer)
+
+	if _, ok := resolved["sso-session"]; ok {
+		t.Error("empty variable should not set a value")
+	}
+	if got := resolved["sso-region"]; got.Value != "us-east-1" || got.Source != "env SSO_REGION" {
+		t.Errorf("sso-region = %+v", got)
+	}
+}
+
+func TestValues_Map(t *testing.T) {
+	yes := true
+	values := Values{
+		SSORegion:       "us-east-1",
+		Stdout:          &yes,
+		Mapping:         "1=a",
+		IncludeAccounts: "1,2",
+	}
+
+	got := values.Map()
+	want := map[string]string{
+		"sso-region":       "us-east-1",
+		"stdout":           "true",
+		"mapping":          "1=a",
+		"include-accounts": "1,2",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Map() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Map()[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package settings
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/scottbrown/setlist"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is one issue found while validating a config file.
+type Problem struct {
+	Line    int // 0 when the problem has no single location
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	}
+	return p.Message
+}
+
+// yamlLinePrefix matches the "line N: " prefix of yaml.v3 error messages.
+var yamlLinePrefix = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// Validate checks a .setlist.yaml document and returns every problem found,
+// sorted by line, rather than stopping at the first. Unlike Parse, unknown
+// keys are reported, and each value is run through the same parser the CLI
+// uses for the matching flag.
+func Validate(data []byte) []Problem {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []Problem{yamlProblem(err.Error())}
+	}
+
+	var problems []Problem
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var cfg File
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return []Problem{yamlProblem(err.Error())}
+		}
+		// The decoder keeps going past type errors, so cfg still holds
+		// every value that could be decoded.
+		for _, msg := range typeErr.Errors {
+			problems = append(problems, yamlProblem(msg))
+		}
+	}
+
+	var top *yaml.Node
+	if len(root.Content) > 0 {
+		top = root.Content[0]
+	}
+
+	if cfg.Version < 0 || cfg.Version > Version {
+		problems = append(problems, Problem{
+			Line:    keyLine(top, "version"),
+			Message: fmt.Sprintf("unsupported config version %d (this build supports up to %d)", cfg.Version, Version),
+		})
+	}
+
+	if cfg.CurrentContext != "" {
+		if _, ok := cfg.Contexts[cfg.CurrentContext]; !ok {
+			problems = append(problems, Problem{
+				Line:    keyLine(top, "current-context"),
+				Message: fmt.Sprintf("current-context %q is not defined under contexts", cfg.CurrentContext),
+			})
+		}
+	}
+
+	allowUnknown := cfg.AllowUnknownRegion != nil && *cfg.AllowUnknownRegion
+	problems = append(problems, checkValues(cfg.Values, top, "", allowUnknown)...)
+
+	contexts := mappingValue(top, "contexts")
+	for _, name := range cfg.ContextNames() {
+		values := cfg.Contexts[name]
+		contextAllowUnknown := allowUnknown
+		if values.AllowUnknownRegion != nil {
+			contextAllowUnknown = *values.AllowUnknownRegion
+		}
+		problems = append(problems, checkValues(values, mappingValue(contexts, name), "context "+name+": ", contextAllowUnknown)...)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
+
+// checkValues runs each value set in v through the parser for its flag.
+// node is the YAML map v was decoded from, used to locate problems, and
+// prefix names the context being checked, and allowUnknown accepts regions
+// missing from the library's region table. Include and exclude lists may be
+// combined freely (exclude applies after include), so there is no mutual
+// exclusivity to check.
+func checkValues(v Values, node *yaml.Node, prefix string, allowUnknown bool) []Problem {
+	var problems []Problem
+	check := func(key string, err error) {
+		if err != nil {
+			problems = append(problems, Problem{
+				Line:    keyLine(node, key),
+				Message: fmt.Sprintf("%s%s: %v", prefix, key, err),
+			})
+		}
+	}
+
+	if v.SSORegion != "" {
+		newRegion := setlist.NewRegion
+		if allowUnknown {
+			newRegion = setlist.NewRegionAllowUnknown
+		}
+		_, err := newRegion(v.SSORegion)
+		check("sso-region", err)
+	}
+	if v.AssumeRoleArn != "" {
+		check("assume-role-arn", setlist.ValidateRoleArn(v.AssumeRoleArn))
+	}
+	for key, endpoint := range map[string]string{
+		"endpoint-url":               v.EndpointURL,
+		"organizations-endpoint-url": v.OrgEndpointURL,
+		"sso-admin-endpoint-url":     v.SSOAdminEndpointURL,
+		"s3-endpoint-url":            v.S3EndpointURL,
+		"sts-endpoint-url":           v.STSEndpointURL,
+	} {
+		if endpoint != "" {
+			check(key, setlist.ValidateEndpointURL(endpoint))
+		}
+	}
+	if v.SSOStartURL != "" {
+		check("sso-start-url", setlist.ValidateStartURL(v.SSOStartURL))
+	}
+	if v.IdentityStoreId != "" {
+		_, err := setlist.NewIdentityStoreId(v.IdentityStoreId)
+		check("identity-store-id", err)
+	}
+	if v.InstanceArn != "" && v.IdentityStoreId != "" {
+		check("identity-store-id", errors.New("cannot be combined with instance-arn"))
+	}
+	if v.Mapping != "" {
+		_, err := setlist.ParseNicknameMapping(string(v.Mapping))
+		check("mapping", err)
+	}
+	if v.IncludeAccounts != "" {
+		_, err := setlist.ParseAccountPatterns(string(v.IncludeAccounts))
+		check("include-accounts", err)
+	}
+	if v.ExcludeAccounts != "" {
+		_, err := setlist.ParseAccountPatterns(string(v.ExcludeAccounts))
+		check("exclude-accounts", err)
+	}
+	if v.IncludePermissionSets != "" {
+		_, err := setlist.ParsePermissionSetPatterns(string(v.IncludePermissionSets))
+		check("include-permission-sets", err)
+	}
+	if v.ExcludePermissionSets != "" {
+		_, err := setlist.ParsePermissionSetPatterns(string(v.ExcludePermissionSets))
+		check("exclude-permission-sets", err)
+	}
+	if v.Filter != "" {
+		_, err := setlist.NewFilter(v.Filter)
+		check("filter", err)
+	}
+	if v.LogFormat != "" && v.LogFormat != "plain" && v.LogFormat != "json" {
+		check("log-format", fmt.Errorf("must be \"plain\" or \"json\", got %q", v.LogFormat))
+	}
+
+	return problems
+}
+
+// yamlProblem turns a yaml.v3 error message into a Problem, lifting its
+// line number out of the text.
+func yamlProblem(msg string) Problem {
+	m := yamlLinePrefix.FindStringSubmatch(msg)
+	if m == nil {
+		return Problem{Message: msg}
+	}
+	line, _ := strconv.Atoi(m[1])
+	return Problem{Line: line, Message: msg[len(m[0]):]}
+}
+
+// mappingValue returns the value node of key in the YAML map m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// keyLine returns the line of key in the YAML map m, or 0 if absent.
+func keyLine(m *yaml.Node, key string) int {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return 0
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i].Line
+		}
+	}
+	return 0
+}
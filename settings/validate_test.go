@@ -0,0 +1,130 @@
+package settings
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	data := []byte(`version: 1
+sso_region: us-east-1
+sso-region: mars-1
+mapping: "123456789012"
+current-context: missing
+include-permission-sets:
+  - re:(
+contexts:
+  prod:
+    filter: "account.name =="
+    log-format: xml
+    bogus: true
+    sso-start-url: http://sso.example.com/start
+`)
+
+	problems := Validate(data)
+
+	want := []struct {
+		line     int
+		contains string
+	}{
+		{line: 2, contains: "field sso_region not found"},
+		{line: 3, contains: "sso-region: invalid region format"},
+		{line: 4, contains: "mapping:"},
+		{line: 5, contains: `current-context "missing" is not defined`},
+		{line: 6, contains: "include-permission-sets: invalid permission set pattern"},
+		{line: 10, contains: "context prod: filter:"},
+		{line: 11, contains: "context prod: log-format:"},
+		{line: 12, contains: "field bogus not found"},
+		{line: 13, contains: "context prod: sso-start-url: invalid start URL"},
+	}
+
+	if len(problems) != len(want) {
+		for _, p := range problems {
+			t.Log(p)
+		}
+		t.Fatalf("got %d problems, want %d", len(problems), len(want))
+	}
+
+	for i, w := range want {
+		if problems[i].Line != w.line || !strings.Contains(problems[i].Message, w.contains) {
+			t.Errorf("problem %d = %q, want line %d containing %q", i, problems[i], w.line, w.contains)
+		}
+	}
+}
+
+func TestValidate_ValidFile(t *testing.T) {
+	data := []byte(`version: 1
+sso-session: myorg
+sso-region: us-east-1
+mapping:
+  "123456789012": prod
+include-accounts: ["1234*"]
+current-context: prod
+contexts:
+  prod:
+    filter: account.name == "Prod"
+    sso-start-url: https://sso.example.com/start
+`)
+
+	if problems := Validate(data); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestValidate_EmptyFile(t *testing.T) {
+	if problems := Validate(nil); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestValidate_SyntaxError(t *testing.T) {
+	problems := Validate([]byte("sso-region: [unterminated\n"))
+	if len(problems) != 1 {
+		t.Fatalf("got %v, want a single syntax problem", problems)
+	}
+}
+
+func TestValidate_UnknownRegion(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "rejected", data: "sso-region: xx-central-1\n", wantErr: true},
+		{name: "allowed", data: "sso-region: xx-central-1\nallow-unknown-region: true\n"},
+		{name: "allowed by context", data: "contexts:\n  new:\n    sso-region: xx-central-1\n    allow-unknown-region: true\n"},
+		{name: "inherited by context", data: "allow-unknown-region: true\ncontexts:\n  new:\n    sso-region: xx-central-1\n"},
+		{name: "malformed even when allowed", data: "sso-region: mars-1\nallow-unknown-region: true\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Validate([]byte(tt.data))
+			if (len(problems) > 0) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", problems, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_InstanceSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "instance arn", data: "instance-arn: arn:aws:sso:::instance/ssoins-1\n"},
+		{name: "identity store id", data: "identity-store-id: d-1234567890\n"},
+		{name: "bad identity store id", data: "identity-store-id: 1234567890\n", wantErr: true},
+		{name: "both", data: "instance-arn: arn:aws:sso:::instance/ssoins-1\nidentity-store-id: d-1234567890\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Validate([]byte(tt.data))
+			if (len(problems) > 0) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", problems, tt.wantErr)
+			}
+		})
+	}
+}
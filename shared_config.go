@@ -0,0 +1,138 @@
+package setlist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ini/ini"
+)
+
+// EnvAWSConfigFile names the environment variable that overrides the
+// location of the shared AWS config file.
+const EnvAWSConfigFile = "AWS_CONFIG_FILE"
+
+// sharedProfilePrefix starts the name of every profile section other than
+// [default] in the shared AWS config file.
+const sharedProfilePrefix = "profile "
+
+// SSOSessionConfig is an [sso-session NAME] section read from a shared AWS
+// config file.
+type SSOSessionConfig struct {
+	Name               string
+	StartURL           string
+	Region             string
+	RegistrationScopes string
+}
+
+// SharedProfile is a profile section read from a shared AWS config file.
+// Only the attributes setlist cares about are kept.
+type SharedProfile struct {
+	Name         string
+	Region       string
+	SSOSession   string
+	SSOStartURL  string // legacy profiles set the start URL directly
+	SSORegion    string // legacy profiles set the SSO region directly
+	SSOAccountId string
+	SSORoleName  string
+}
+
+// SharedConfig holds the SSO sessions and profiles found in a shared AWS
+// config file, in file order.
+type SharedConfig struct {
+	Sessions []SSOSessionConfig
+	Profiles []SharedProfile
+}
+
+// DefaultSharedConfigPath returns the path of the shared AWS config file:
+// $AWS_CONFIG_FILE if set, otherwise ~/.aws/config.
+func DefaultSharedConfigPath() (string, error) {
+	if path := os.Getenv(EnvAWSConfigFile); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".aws", "config"), nil
+}
+
+// LoadSharedConfig reads the SSO sessions and profiles from the shared AWS
+// config file at path.
+func LoadSharedConfig(path string) (*SharedConfig, error) {
+	data, err := os.ReadFile(path) //#nosec: G304
+	if err != nil {
+		return nil, fmt.Errorf("unable to read AWS config file %s: %w", path, err)
+	}
+
+	cfg, err := ParseSharedConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse AWS config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseSharedConfig reads the SSO sessions and profiles from the contents
+// of a shared AWS config file.
+func ParseSharedConfig(data []byte) (*SharedConfig, error) {
+	file, err := ini.Load(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &SharedConfig{}
+	for _, section := range file.Sections() {
+		name := section.Name()
+
+		if sessionName, ok := strings.CutPrefix(name, SSOSessionSectionKey+" "); ok {
+			cfg.Sessions = append(cfg.Sessions, SSOSessionConfig{
+				Name:               strings.TrimSpace(sessionName),
+				StartURL:           section.Key(SSOStartUrlKey).String(),
+				Region:             section.Key(SSORegionKey).String(),
+				RegistrationScopes: section.Key(SSORegistrationScopesKey).String(),
+			})
+			continue
+		}
+
+		profileName, ok := strings.CutPrefix(name, sharedProfilePrefix)
+		if !ok && name != "default" {
+			continue
+		}
+		if !ok {
+			profileName = name
+		}
+
+		cfg.Profiles = append(cfg.Profiles, SharedProfile{
+			Name:         strings.TrimSpace(profileName),
+			Region:       section.Key("region").String(),
+			SSOSession:   section.Key(SSOSessionAttrKey).String(),
+			SSOStartURL:  section.Key(SSOStartUrlKey).String(),
+			SSORegion:    section.Key(SSORegionKey).String(),
+			SSOAccountId: section.Key(SSOAccountIdKey).String(),
+			SSORoleName:  section.Key(SSORoleNameKey).String(),
+		})
+	}
+
+	return cfg, nil
+}
+
+// ProfileNames returns the names of the profiles in file order.
+func (c *SharedConfig) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for _, p := range c.Profiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Session returns the [sso-session] section with the given name.
+func (c *SharedConfig) Session(name string) (SSOSessionConfig, bool) {
+	for _, s := range c.Sessions {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return SSOSessionConfig{}, false
+}
@@ -0,0 +1,105 @@
+package setlist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sharedConfigFixture = `[default]
+region = us-east-1
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = ca-central-1
+sso_registration_scopes = sso:account:access
+
+[profile corp-admin]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+
+[profile legacy]
+sso_start_url = https://d-1234567890.awsapps.com/start
+sso_region = us-west-2
+`
+
+func writeSharedConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(sharedConfigFixture), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSharedConfig(t *testing.T) {
+	cfg, err := LoadSharedConfig(writeSharedConfig(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	session, ok := cfg.Session("corp")
+	if !ok {
+		t.Fatal("expected sso-session corp")
+	}
+	if session.StartURL != "https://corp.awsapps.com/start" || session.Region != "ca-central-1" {
+		t.Errorf("session = %+v", session)
+	}
+	if _, ok := cfg.Session("missing"); ok {
+		t.Error("unexpected session found")
+	}
+
+	if len(cfg.Profiles) != 3 {
+		t.Fatalf("got %d profiles, want 3", len(cfg.Profiles))
+	}
+	if p := cfg.Profiles[0]; p.Name != "default" || p.Region != "us-east-1" {
+		t.Errorf("default profile = %+v", p)
+	}
+	if p := cfg.Profiles[1]; p.Name != "corp-admin" || p.SSOSession != "corp" || p.SSOAccountId != "123456789012" {
+		t.Errorf("corp-admin profile = %+v", p)
+	}
+	if p := cfg.Profiles[2]; p.SSOStartURL != "https://d-1234567890.awsapps.com/start" || p.SSORegion != "us-west-2" {
+		t.Errorf("legacy profile = %+v", p)
+	}
+}
+
+func TestParseSharedConfig(t *testing.T) {
+	cfg, err := ParseSharedConfig([]byte(sharedConfigFixture))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := cfg.ProfileNames()
+	want := []string{"default", "corp-admin", "legacy"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ProfileNames() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseSharedConfig([]byte("[unterminated")); err == nil {
+		t.Error("expected error for malformed config")
+	}
+}
+
+func TestLoadSharedConfig_Missing(t *testing.T) {
+	if _, err := LoadSharedConfig(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestDefaultSharedConfigPath(t *testing.T) {
+	t.Setenv(EnvAWSConfigFile, "/custom/aws/config")
+	path, err := DefaultSharedConfigPath()
+	if err != nil || path != "/custom/aws/config" {
+		t.Errorf("path = %q, err = %v, want AWS_CONFIG_FILE", path, err)
+	}
+
+	home := t.TempDir()
+	t.Setenv(EnvAWSConfigFile, "")
+	t.Setenv("HOME", home)
+	path, err = DefaultSharedConfigPath()
+	if err != nil || path != filepath.Join(home, ".aws", "config") {
+		t.Errorf("path = %q, err = %v, want ~/.aws/config", path, err)
+	}
+}
@@ -0,0 +1,172 @@
+package setlist
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SignatureExtension is appended to a config file's name to name its
+// detached signature.
+const SignatureExtension = ".sig"
+
+// ErrBadSignature means a signature does not match the config file and key
+// it was checked against.
+var ErrBadSignature = errors.New("signature does not match")
+
+// ed25519ph selects Ed25519ph, the variant of Ed25519 that signs a SHA-512
+// digest rather than the whole message. KMS only signs large messages as a
+// digest, so local keys use the same variant and either verifies with the
+// same public key.
+var ed25519ph = &ed25519.Options{Hash: crypto.SHA512}
+
+// Signer signs the SHA-512 digest of a config file's canonical content with
+// Ed25519ph.
+type Signer interface {
+	Sign(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+// Ed25519Signer is a Signer holding its private key in memory.
+type Ed25519Signer struct {
+	Key ed25519.PrivateKey
+}
+
+func (s Ed25519Signer) Sign(ctx context.Context, digest []byte) ([]byte, error) {
+	return s.Key.Sign(nil, digest, ed25519ph)
+}
+
+// signatureDigest returns what a signature over a config file covers.
+func signatureDigest(data []byte) []byte {
+	sum := sha512.Sum512(CanonicalContent(data))
+	return sum[:]
+}
+
+// SignConfig returns a detached signature of a built config file, ready to
+// be written next to it. Like ContentHash, it ignores the "Generated on"
+// comment, so a rebuild of the same profiles has the same signature.
+func SignConfig(ctx context.Context, signer Signer, data []byte) ([]byte, error) {
+	sig, err := signer.Sign(ctx, signatureDigest(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign config: %w", err)
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("failed to sign config: got a %d byte signature, want an Ed25519 signature of %d", len(sig), ed25519.SignatureSize)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), nil
+}
+
+// VerifyConfig checks a detached signature written by SignConfig against a
+// config file. Lines of the signature starting with "#" are comments, such
+// as the key a signer names, and aren't checked. It returns an error
+// wrapping ErrBadSignature when the signature is well formed but doesn't
+// match.
+func VerifyConfig(key ed25519.PublicKey, data, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(signatureValue(signature))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature: %d bytes, want %d", len(sig), ed25519.SignatureSize)
+	}
+	if err := ed25519.VerifyWithOptions(key, signatureDigest(data), sig, ed25519ph); err != nil {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// signatureValue returns the base64 signature a signature file holds,
+// without its comments and blank lines.
+func signatureValue(signature []byte) string {
+	var value strings.Builder
+	for _, line := range strings.Split(string(signature), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			value.WriteString(line)
+		}
+	}
+	return value.String()
+}
+
+// ParseEd25519PrivateKey parses a PEM-encoded PKCS #8 Ed25519 private key,
+// as written by `openssl genpkey -algorithm ed25519`.
+func ParseEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	der, err := pemBlock(data, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid private key: %T is not an Ed25519 key", key)
+	}
+	return edKey, nil
+}
+
+// ParseEd25519PublicKey parses a PEM-encoded Ed25519 public key, as written
+// by `openssl pkey -pubout` or converted from KMS's GetPublicKey.
+func ParseEd25519PublicKey(data []byte) (ed25519.PublicKey, error) {
+	der, err := pemBlock(data, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key: %T is not an Ed25519 key", key)
+	}
+	return edKey, nil
+}
+
+// LoadEd25519PrivateKey reads a private key file for ParseEd25519PrivateKey.
+func LoadEd25519PrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path) //#nosec: G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	key, err := ParseEd25519PrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// LoadEd25519PublicKey reads a public key file for ParseEd25519PublicKey.
+func LoadEd25519PublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path) //#nosec: G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	key, err := ParseEd25519PublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// pemBlock returns the contents of the first PEM block of the given type.
+func pemBlock(data []byte, blockType string) ([]byte, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no %q PEM block found", blockType)
+		}
+		if block.Type == blockType {
+			return block.Bytes, nil
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package setlist
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+type failingSigner struct{}
+
+func (failingSigner) Sign(ctx context.Context, digest []byte) ([]byte, error) {
+	return nil, errors.New("access denied")
+}
+
+func TestSignConfig_Verify(t *testing.T) {
+	pub, priv := newTestKey(t)
+	otherPub, _ := newTestKey(t)
+
+	stamped := func(ts string) []byte {
+		return []byte("# Generated on: " + ts + "\n[default]\nsso_session = corp\n\n[profile prod-ReadOnly]\nsso_role_name = ReadOnly\n")
+	}
+	config := stamped("2024-01-01T00:00:00 UTC")
+
+	sig, err := SignConfig(context.Background(), Ed25519Signer{Key: priv}, config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(string(sig), "\n") || strings.Count(string(sig), "\n") != 1 {
+		t.Errorf("signature = %q, want one line", sig)
+	}
+
+	// A comment longer than a bufio.Scanner's 64KB line limit, then a
+	// profile that runs a command for credentials.
+	injected := []byte("# " + strings.Repeat("x", 70*1024) + "\n\n[profile evil]\ncredential_process = /tmp/evil\n")
+
+	tests := []struct {
+		name    string
+		key     ed25519.PublicKey
+		data    []byte
+		sig     []byte
+		wantErr error
+		wantMsg string
+	}{
+		{name: "matches", key: pub, data: config, sig: sig},
+		{name: "timestamp is not covered", key: pub, data: stamped("2025-06-30T12:34:56 UTC"), sig: sig},
+		{name: "line endings are not covered", key: pub, data: []byte(strings.ReplaceAll(string(config), "\n", "\r\n")), sig: sig},
+		{name: "profile changed", key: pub, data: []byte(strings.Replace(string(config), "ReadOnly", "Admin", 2)), sig: sig, wantErr: ErrBadSignature},
+		{name: "other key", key: otherPub, data: config, sig: sig, wantErr: ErrBadSignature},
+		{name: "long line hides an injected profile", key: pub, data: append(append([]byte{}, config...), injected...), sig: sig, wantErr: ErrBadSignature},
+		{name: "extra timestamp comment", key: pub, data: []byte(string(config) + "# Generated on: 2025-06-30T12:34:56 UTC\n"), sig: sig, wantErr: ErrBadSignature},
+		{name: "timestamp moved", key: pub, data: []byte("[default]\n# Generated on: 2024-01-01T00:00:00 UTC\n" + strings.TrimPrefix(string(config), "# Generated on: 2024-01-01T00:00:00 UTC\n[default]\n")), sig: sig, wantErr: ErrBadSignature},
+		{name: "comments are not covered", key: pub, data: config, sig: []byte("# Key: arn:aws:kms:us-east-1:111111111111:key/1234\n" + string(sig))},
+		{name: "commented out", key: pub, data: config, sig: []byte("# " + string(sig)), wantMsg: "invalid signature: 0 bytes"},
+		{name: "not base64", key: pub, data: config, sig: []byte("not a signature!"), wantMsg: "invalid signature"},
+		{name: "truncated", key: pub, data: config, sig: []byte("c2hvcnQ=\n"), wantMsg: "invalid signature: 5 bytes"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyConfig(tc.key, tc.data, tc.sig)
+			switch {
+			case tc.wantErr != nil:
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("VerifyConfig() = %v, want %v", err, tc.wantErr)
+				}
+			case tc.wantMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tc.wantMsg) {
+					t.Errorf("VerifyConfig() = %v, want an error containing %q", err, tc.wantMsg)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSignConfig_SignerFails(t *testing.T) {
+	_, err := SignConfig(context.Background(), failingSigner{}, []byte("[default]\n"))
+	if err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("expected the signer's error, got %v", err)
+	}
+}
+
+func TestParseEd25519Keys(t *testing.T) {
+	pub, priv := newTestKey(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := func(blockType string, der []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	}
+	marshalPrivate := func(key any) []byte {
+		t.Helper()
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encode("PRIVATE KEY", der)
+	}
+	marshalPublic := func(key any) []byte {
+		t.Helper()
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encode("PUBLIC KEY", der)
+	}
+	privPEM, ecPrivPEM := marshalPrivate(priv), marshalPrivate(ecKey)
+	pubPEM, ecPubPEM := marshalPublic(pub), marshalPublic(&ecKey.PublicKey)
+
+	t.Run("private", func(t *testing.T) {
+		got, err := ParseEd25519PrivateKey(privPEM)
+		if err != nil || !got.Equal(priv) {
+			t.Errorf("ParseEd25519PrivateKey() = %v", err)
+		}
+		if _, err := ParseEd25519PrivateKey(ecPrivPEM); err == nil || !strings.Contains(err.Error(), "not an Ed25519 key") {
+			t.Errorf("expected an ECDSA key to be rejected, got %v", err)
+		}
+		if _, err := ParseEd25519PrivateKey(pubPEM); err == nil || !strings.Contains(err.Error(), `no "PRIVATE KEY" PEM block`) {
+			t.Errorf("expected a public key to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("public", func(t *testing.T) {
+		// Blocks of other types before the key are skipped.
+		got, err := ParseEd25519PublicKey(append(encode("OTHER", []byte("x")), pubPEM...))
+		if err != nil || !got.Equal(pub) {
+			t.Errorf("ParseEd25519PublicKey() = %v", err)
+		}
+		if _, err := ParseEd25519PublicKey(ecPubPEM); err == nil || !strings.Contains(err.Error(), "not an Ed25519 key") {
+			t.Errorf("expected an ECDSA key to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		dir := t.TempDir()
+		privPath := filepath.Join(dir, "signing.pem")
+		pubPath := filepath.Join(dir, "pub.pem")
+		if err := os.WriteFile(privPath, privPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(pubPath, pubPEM, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loadedPriv, err := LoadEd25519PrivateKey(privPath)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		loadedPub, err := LoadEd25519PublicKey(pubPath)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		config := []byte("[default]\nsso_session = corp\n")
+		sig, err := SignConfig(context.Background(), Ed25519Signer{Key: loadedPriv}, config)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := VerifyConfig(loadedPub, config, sig); err != nil {
+			t.Errorf("VerifyConfig() = %v", err)
+		}
+
+		if _, err := LoadEd25519PublicKey(filepath.Join(dir, "missing.pem")); err == nil {
+			t.Error("expected an error for a missing file")
+		}
+	})
+}
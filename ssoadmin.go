@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -21,22 +22,78 @@ type SSOAdminClient interface {
 	DescribePermissionSet(ctx context.Context, params *ssoadmin.DescribePermissionSetInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error)
 }
 
+// ErrMultipleInstances is returned when the caller can see more than one
+// SSO instance and no InstanceSelector narrows them down.
+var ErrMultipleInstances = errors.New("multiple SSO instances found")
+
+// InstanceSelector picks one SSO instance when the caller can see several,
+// e.g. an organization instance and account instances in member accounts.
+// Set at most one field. An empty selector matches only when a single
+// instance exists.
+type InstanceSelector struct {
+	InstanceArn     string
+	IdentityStoreId string
+}
+
+// IsEmpty reports whether no selector field is set.
+func (s InstanceSelector) IsEmpty() bool {
+	return s.InstanceArn == "" && s.IdentityStoreId == ""
+}
+
+func (s InstanceSelector) String() string {
+	if s.InstanceArn != "" {
+		return "instance ARN " + s.InstanceArn
+	}
+	return "identity store ID " + s.IdentityStoreId
+}
+
+func (s InstanceSelector) matches(instance types.InstanceMetadata) bool {
+	if s.InstanceArn != "" {
+		return aws.ToString(instance.InstanceArn) == s.InstanceArn
+	}
+	return aws.ToString(instance.IdentityStoreId) == s.IdentityStoreId
+}
+
 // SsoInstance retrieves the AWS SSO instance metadata from the AWS account.
-// AWS SSO allows only a single instance per organization, so this function
-// returns the first (and only) instance found. It validates that required
-// fields exist in the response and returns an error if the SSO service is
-// not properly configured.
+// It returns ErrMultipleInstances if more than one instance is visible; use
+// SelectSsoInstance to choose between them.
 func SsoInstance(ctx context.Context, client SSOAdminClient) (types.InstanceMetadata, error) {
-	resp, err := client.ListInstances(ctx, nil)
+	return SelectSsoInstance(ctx, client, InstanceSelector{})
+}
+
+// SelectSsoInstance lists every SSO instance visible to the caller and
+// returns the one matching selector. It validates that required fields
+// exist in the response and returns an error if the SSO service is not
+// properly configured. Errors for an ambiguous or unmatched selector list
+// the candidates.
+func SelectSsoInstance(ctx context.Context, client SSOAdminClient, selector InstanceSelector) (types.InstanceMetadata, error) {
+	instances, err := ListSsoInstances(ctx, client)
 	if err != nil {
-		return types.InstanceMetadata{}, fmt.Errorf("failed to list SSO instances: %w", err)
+		return types.InstanceMetadata{}, err
 	}
 
-	if len(resp.Instances) == 0 {
+	if len(instances) == 0 {
 		return types.InstanceMetadata{}, errors.New("SSO is not enabled. No SSO instances exist")
 	}
 
-	instance := resp.Instances[0]
+	var instance types.InstanceMetadata
+	switch {
+	case selector.IsEmpty() && len(instances) == 1:
+		instance = instances[0]
+	case selector.IsEmpty():
+		return types.InstanceMetadata{}, fmt.Errorf("%w (%d): %s", ErrMultipleInstances, len(instances), describeInstances(instances))
+	default:
+		found := false
+		for _, candidate := range instances {
+			if selector.matches(candidate) {
+				instance, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return types.InstanceMetadata{}, fmt.Errorf("no SSO instance matches %s (available: %s)", selector, describeInstances(instances))
+		}
+	}
 
 	// Validate required fields
 	if instance.InstanceArn == nil {
@@ -50,6 +107,45 @@ func SsoInstance(ctx context.Context, client SSOAdminClient) (types.InstanceMeta
 	return instance, nil
 }
 
+// ListSsoInstances retrieves every SSO instance visible to the caller,
+// following pagination.
+func ListSsoInstances(ctx context.Context, client SSOAdminClient) ([]types.InstanceMetadata, error) {
+	var instances []types.InstanceMetadata
+	var token *string
+	for {
+		resp, err := client.ListInstances(ctx, &ssoadmin.ListInstancesInput{NextToken: token})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list SSO instances: %w", err)
+		}
+
+		instances = append(instances, resp.Instances...)
+
+		if resp.NextToken == nil {
+			break
+		}
+		token = resp.NextToken
+	}
+
+	return instances, nil
+}
+
+// describeInstances lists instances as "ARN (identity store ID, name)" for
+// error messages.
+func describeInstances(instances []types.InstanceMetadata) string {
+	parts := make([]string, 0, len(instances))
+	for _, i := range instances {
+		detail := aws.ToString(i.IdentityStoreId)
+		if name := aws.ToString(i.Name); name != "" {
+			detail += ", " + name
+		}
+		if owner := aws.ToString(i.OwnerAccountId); owner != "" {
+			detail += ", account " + owner
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", aws.ToString(i.InstanceArn), detail))
+	}
+	return strings.Join(parts, "; ")
+}
+
 // PermissionSets retrieves the list of permission sets provisioned to an
 // account.
 func PermissionSets(ctx context.Context, client SSOAdminClient, instanceArn string, accountId string) ([]types.PermissionSet, error) {
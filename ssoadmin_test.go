@@ -362,3 +362,109 @@ func TestPermissionSetsErrorHandling(t *testing.T) {
 		})
 	}
 }
+
+// instancesSSOAdminClient serves ListInstances from pages, one per call.
+type instancesSSOAdminClient struct {
+	mockSSOAdminClient
+	pages [][]types.InstanceMetadata
+	calls int
+}
+
+func (c *instancesSSOAdminClient) ListInstances(ctx context.Context, params *ssoadmin.ListInstancesInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListInstancesOutput, error) {
+	page := c.pages[c.calls]
+	c.calls++
+
+	out := &ssoadmin.ListInstancesOutput{Instances: page}
+	if c.calls < len(c.pages) {
+		out.NextToken = aws.String("next")
+	}
+	return out, nil
+}
+
+func TestSelectSsoInstance(t *testing.T) {
+	org := types.InstanceMetadata{
+		InstanceArn:     aws.String("arn:aws:sso:::instance/ssoins-org"),
+		IdentityStoreId: aws.String("d-1111111111"),
+		Name:            aws.String("org"),
+	}
+	member := types.InstanceMetadata{
+		InstanceArn:     aws.String("arn:aws:sso:::instance/ssoins-member"),
+		IdentityStoreId: aws.String("d-2222222222"),
+		OwnerAccountId:  aws.String("123456789012"),
+	}
+
+	tests := []struct {
+		name        string
+		pages       [][]types.InstanceMetadata
+		selector    InstanceSelector
+		wantArn     string
+		wantErr     error
+		errContains string
+	}{
+		{
+			name:    "single instance",
+			pages:   [][]types.InstanceMetadata{{org}},
+			wantArn: "arn:aws:sso:::instance/ssoins-org",
+		},
+		{
+			name:        "no instances",
+			pages:       [][]types.InstanceMetadata{{}},
+			errContains: "SSO is not enabled",
+		},
+		{
+			name:        "several instances without a selector",
+			pages:       [][]types.InstanceMetadata{{org}, {member}},
+			wantErr:     ErrMultipleInstances,
+			errContains: "ssoins-member (d-2222222222, account 123456789012)",
+		},
+		{
+			name:     "select by ARN across pages",
+			pages:    [][]types.InstanceMetadata{{org}, {member}},
+			selector: InstanceSelector{InstanceArn: "arn:aws:sso:::instance/ssoins-member"},
+			wantArn:  "arn:aws:sso:::instance/ssoins-member",
+		},
+		{
+			name:     "select by identity store ID",
+			pages:    [][]types.InstanceMetadata{{org, member}},
+			selector: InstanceSelector{IdentityStoreId: "d-1111111111"},
+			wantArn:  "arn:aws:sso:::instance/ssoins-org",
+		},
+		{
+			name:        "selector matches nothing",
+			pages:       [][]types.InstanceMetadata{{org, member}},
+			selector:    InstanceSelector{IdentityStoreId: "d-9999999999"},
+			errContains: "no SSO instance matches identity store ID d-9999999999 (available: arn:aws:sso:::instance/ssoins-org (d-1111111111, org)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &instancesSSOAdminClient{pages: tt.pages}
+
+			instance, err := SelectSsoInstance(context.Background(), client, tt.selector)
+
+			if tt.errContains != "" || tt.wantErr != nil {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, should contain %q", err, tt.errContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if aws.ToString(instance.InstanceArn) != tt.wantArn {
+				t.Errorf("InstanceArn = %q, want %q", aws.ToString(instance.InstanceArn), tt.wantArn)
+			}
+			if client.calls != len(tt.pages) {
+				t.Errorf("ListInstances called %d times, want %d", client.calls, len(tt.pages))
+			}
+		})
+	}
+}
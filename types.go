@@ -2,6 +2,9 @@ package setlist
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
 	"strings"
 )
 
@@ -11,14 +14,16 @@ var ErrWrongFormat = errors.New("invalid format")
 
 type IdentityStoreId string
 
-type Region string
+// identityStoreUUID matches identity store IDs that are a bare UUID rather
+// than the usual d-xxxxxxxxxx directory ID.
+var identityStoreUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 
 func NewIdentityStoreId(id string) (IdentityStoreId, error) {
 	if id == "" {
 		return IdentityStoreId(""), ErrEmptyString
 	}
 
-	if !strings.HasPrefix(id, "d-") {
+	if !strings.HasPrefix(id, "d-") && !identityStoreUUID.MatchString(id) {
 		return IdentityStoreId(""), ErrWrongFormat
 	}
 
@@ -29,14 +34,19 @@ func (i IdentityStoreId) String() string {
 	return string(i)
 }
 
-func NewRegion(region string) (Region, error) {
-	if region == "" {
-		return Region(""), ErrEmptyString
+// ValidateStartURL checks that startURL is an absolute https:// URL.
+func ValidateStartURL(startURL string) error {
+	if startURL == "" {
+		return ErrEmptyString
 	}
 
-	return Region(region), nil
-}
+	u, err := url.Parse(startURL)
+	if err != nil {
+		return fmt.Errorf("invalid start URL %q: %w", startURL, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid start URL %q: must be an https:// URL", startURL)
+	}
 
-func (r Region) String() string {
-	return string(r)
+	return nil
 }
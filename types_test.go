@@ -18,6 +18,12 @@ func TestNewIdentityStoreId(t *testing.T) {
 			want:    "d-1234567890",
 			wantErr: nil,
 		},
+		{
+			name:    "uuid identity store id",
+			input:   "9a4b2c1d-3e5f-4a6b-8c7d-0e1f2a3b4c5d",
+			want:    "9a4b2c1d-3e5f-4a6b-8c7d-0e1f2a3b4c5d",
+			wantErr: nil,
+		},
 		{
 			name:    "empty string",
 			input:   "",
@@ -53,37 +59,26 @@ func TestNewIdentityStoreId(t *testing.T) {
 	}
 }
 
-func TestNewRegion(t *testing.T) {
+func TestValidateStartURL(t *testing.T) {
 	tt := []struct {
 		name    string
 		input   string
-		want    string
-		wantErr error
+		wantErr bool
 	}{
-		{
-			name:    "valid region",
-			input:   "ca-central-1",
-			want:    "ca-central-1",
-			wantErr: nil,
-		},
-		{
-			name:    "empty string",
-			input:   "",
-			want:    "",
-			wantErr: ErrEmptyString,
-		},
+		{"awsapps", "https://corp.awsapps.com/start", false},
+		{"custom domain", "https://sso.example.com/start", false},
+		{"govcloud", "https://start.us-gov-home.awsapps.com/directory/corp", false},
+		{"empty", "", true},
+		{"http", "http://corp.awsapps.com/start", true},
+		{"no scheme", "corp.awsapps.com/start", true},
+		{"no host", "https:///start", true},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := NewRegion(tc.input)
-
-			if !errors.Is(err, tc.wantErr) {
-				t.Errorf("NewRegion(%q) error = %v, want %v", tc.input, err, tc.wantErr)
-			}
-
-			if got.String() != tc.want {
-				t.Errorf("NewRegion(%q) = %q, want %q", tc.input, got.String(), tc.want)
+			err := ValidateStartURL(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidateStartURL(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
 			}
 		})
 	}